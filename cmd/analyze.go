@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"image-analyzer-go/pkg/analyze"
 	"image-analyzer-go/pkg/imageutil"
@@ -15,14 +16,49 @@ import (
 )
 
 var (
-	outputFile          string
-	imageRef            string
-	format              string
-	checkOSInfo         bool
-	checkPythonPackages bool
-	checkCommonTools    bool
-	specificCommands    []string
-	unpackDir           string
+	outputFile             string
+	imageRef               string
+	format                 string
+	checkOSInfo            bool
+	checkPythonPackages    bool
+	checkPythonEnvs        bool
+	checkPythonDeps        bool
+	checkConda             bool
+	verifyPythonRecords    bool
+	verifyOSPackages       bool
+	checkNode              bool
+	checkGoBinaries        bool
+	checkJava              bool
+	checkPackageEcosystems bool
+	checkELF               bool
+	checkCUDA              bool
+	checkModels            bool
+	checkRuntimes          bool
+	checkSecrets           bool
+	secretRuleFiles        []string
+	secretAllowPaths       []string
+	secretAllowRegexes     []string
+	checkMalware           bool
+	malwareRuleFiles       []string
+	malwareHashLists       []string
+	malwareMaxFileSize     int64
+	checkLicenses          bool
+	licenseAllow           []string
+	licenseDeny            []string
+	checkCertificates      bool
+	requiredCAs            []string
+	checkAccounts          bool
+	checkPrivileges        bool
+	checkStartup           bool
+	checkHardening         bool
+	vulnDB                 string
+	vulnDBMaxAge           time.Duration
+	allowStaleDB           bool
+	integrityWorkers       int
+	checkCommonTools       bool
+	commonTools            []string
+	specificCommands       []string
+	unpackDir              string
 )
 
 var analyzeCmd = &cobra.Command{
@@ -32,6 +68,13 @@ var analyzeCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		imageRef = args[0]
+		// 未指定 --tools 时使用配置文件中的 analyze.common_tools
+		if !cmd.Flags().Changed("tools") {
+			commonTools = GetConfig(cmd.Context()).Analyze.CommonTools
+			if len(commonTools) == 0 {
+				commonTools = analyze.DefaultCommonTools
+			}
+		}
 		return runAnalysis()
 	},
 }
@@ -42,7 +85,42 @@ func init() {
 	analyzeCmd.Flags().StringVarP(&format, "format", "f", "json", "输出格式 (json 或 yaml)")
 	analyzeCmd.Flags().BoolVar(&checkOSInfo, "check-os", true, "是否检查系统信息")
 	analyzeCmd.Flags().BoolVar(&checkPythonPackages, "check-python", true, "是否检查 Python 包")
+	analyzeCmd.Flags().BoolVar(&checkPythonEnvs, "check-python-envs", true, "是否检查 Python 解释器和虚拟环境")
+	analyzeCmd.Flags().BoolVar(&checkPythonDeps, "check-python-deps", true, "是否检查 Python 包依赖一致性")
+	analyzeCmd.Flags().BoolVar(&checkConda, "check-conda", true, "是否检查 conda 环境的包")
+	analyzeCmd.Flags().BoolVar(&verifyPythonRecords, "verify-python-records", false, "是否按 RECORD 校验 Python 包文件的完整性")
+	analyzeCmd.Flags().BoolVar(&verifyOSPackages, "verify-os-packages", false, "是否按 dpkg/rpm 摘要校验系统文件的完整性")
+	analyzeCmd.Flags().BoolVar(&checkNode, "check-node", true, "是否检查 Node.js 运行时和 npm 包")
+	analyzeCmd.Flags().BoolVar(&checkGoBinaries, "check-go-binaries", true, "是否提取 Go 二进制的构建信息")
+	analyzeCmd.Flags().BoolVar(&checkJava, "check-java", true, "是否检查 Java 运行时和 jar/war/ear 包")
+	analyzeCmd.Flags().BoolVar(&checkPackageEcosystems, "check-package-ecosystems", true, "是否检查 Ruby、PHP、Rust 和 .NET 的第三方包")
+	analyzeCmd.Flags().BoolVar(&checkELF, "check-elf", false, "是否列出 ELF 文件的依赖库和加固信息")
+	analyzeCmd.Flags().BoolVar(&checkCUDA, "check-cuda", true, "是否检查 CUDA、cuDNN、NCCL、TensorRT 及框架构建版本")
+	analyzeCmd.Flags().BoolVar(&checkModels, "check-models", true, "是否检查镜像中的模型文件和 Hugging Face 缓存")
+	analyzeCmd.Flags().BoolVar(&checkRuntimes, "check-runtimes", true, "是否静态检测各语言运行时和工具链的版本")
+	analyzeCmd.Flags().BoolVar(&checkSecrets, "check-secrets", true, "是否扫描文件、环境变量、标签和构建历史中泄露的凭据")
+	analyzeCmd.Flags().StringSliceVar(&secretRuleFiles, "secret-rules", []string{}, "额外的密钥规则文件")
+	analyzeCmd.Flags().StringSliceVar(&secretAllowPaths, "secret-allow-paths", []string{}, "密钥扫描时跳过的文件路径正则")
+	analyzeCmd.Flags().StringSliceVar(&secretAllowRegexes, "secret-allow-regexes", []string{}, "密钥扫描时忽略的值的正则")
+	analyzeCmd.Flags().BoolVar(&checkMalware, "check-malware", true, "是否检测挖矿程序等恶意和可疑内容")
+	analyzeCmd.Flags().StringSliceVar(&malwareRuleFiles, "malware-rules", []string{}, "YARA 子集语法的规则文件")
+	analyzeCmd.Flags().StringSliceVar(&malwareHashLists, "malware-hashes", []string{}, "已知恶意文件的 SHA-256 列表文件")
+	analyzeCmd.Flags().Int64Var(&malwareMaxFileSize, "malware-max-file-size", 0, "内容规则扫描的最大文件大小（字节），0 表示使用默认值")
+	analyzeCmd.Flags().BoolVar(&checkLicenses, "check-licenses", true, "是否收集包的许可证并按策略检查")
+	analyzeCmd.Flags().StringSliceVar(&licenseAllow, "license-allow", []string{}, "允许的许可证（SPDX 标识符，支持 * 通配符），为空时不限制")
+	analyzeCmd.Flags().StringSliceVar(&licenseDeny, "license-deny", []string{}, "禁止的许可证（SPDX 标识符，支持 * 通配符），例如 AGPL-*")
+	analyzeCmd.Flags().BoolVar(&checkCertificates, "check-certs", true, "是否列出 CA 证书、密钥库和私钥，检查过期证书")
+	analyzeCmd.Flags().StringSliceVar(&requiredCAs, "required-ca", []string{}, "镜像必须信任的 CA（SHA-256 指纹、CN 或主题的一部分），缺失时报告")
+	analyzeCmd.Flags().BoolVar(&checkAccounts, "check-accounts", true, "是否列出用户和组，并检查 USER 能否解析、家目录是否存在")
+	analyzeCmd.Flags().BoolVar(&checkPrivileges, "check-privileges", true, "是否列出带文件能力或 setuid/setgid 位的文件及其授予的权限")
+	analyzeCmd.Flags().BoolVar(&checkStartup, "check-startup", true, "是否静态检查 Entrypoint 和 Cmd 能否启动：可执行文件、解释器、动态链接器、依赖库和工作目录")
+	analyzeCmd.Flags().BoolVar(&checkHardening, "check-hardening", true, "是否检查以 root 运行、setuid 文件、sudo 免密等安全配置问题")
+	analyzeCmd.Flags().StringVar(&vulnDB, "vuln-db", "", "本地 OSV 漏洞数据源（目录、zip 或 JSON 文件），设置后匹配镜像中的包")
+	analyzeCmd.Flags().DurationVar(&vulnDBMaxAge, "max-db-age", 7*24*time.Hour, "漏洞库允许的最长未更新时间，超过时拒绝扫描，0 表示不检查")
+	analyzeCmd.Flags().BoolVar(&allowStaleDB, "allow-stale", false, "漏洞库过期时仍然扫描，只记录警告")
+	analyzeCmd.Flags().IntVar(&integrityWorkers, "integrity-workers", 0, "完整性校验和二进制扫描的并发数，0 表示使用默认值")
 	analyzeCmd.Flags().BoolVar(&checkCommonTools, "check-tools", true, "是否检查常用工具")
+	analyzeCmd.Flags().StringSliceVar(&commonTools, "tools", nil, "按镜像 PATH 检查的常用工具列表，默认使用配置文件中的 analyze.common_tools")
 	analyzeCmd.Flags().StringSliceVar(&specificCommands, "commands", []string{}, "要检查的特定命令列表")
 	analyzeCmd.Flags().StringVarP(&unpackDir, "unpack-dir", "d", "images", "解压缩镜像的临时目录")
 }
@@ -50,7 +128,7 @@ func init() {
 func runAnalysis() error {
 	ctx := context.Background()
 
-	imageDir, imgCfg, err := imageutil.PullAndExtract(ctx, imageRef, unpackDir)
+	imageDir, imgCfg, extract, err := imageutil.PullAndExtract(ctx, imageRef, unpackDir)
 	if err != nil {
 		return utils.WrapError(err, "提取镜像失败")
 	}
@@ -64,21 +142,47 @@ func runAnalysis() error {
 		}
 	}(imageDir)
 
-	summary := analyze.Summary{
-		Architecture: imgCfg.Architecture,
-		OS:           imgCfg.OS,
-		Env:          imgCfg.Config.Env,
-	}
-
-	if checkOSInfo {
-		summary.OSInfo = analyze.CheckOSInfo(imageDir)
-	}
-	if checkPythonPackages {
-		summary.PythonPackages = analyze.ListPythonPackages(imageDir)
-	}
-	if checkCommonTools {
-		summary.Tools = analyze.CheckCommonTools(imageDir)
-	}
+	summary := analyze.Analyze(imageDir, imgCfg, extract, &analyze.AnalyzeOptions{
+		CheckOSInfo:            checkOSInfo,
+		CheckPythonPackages:    checkPythonPackages,
+		CheckPythonEnvs:        checkPythonEnvs,
+		CheckPythonDeps:        checkPythonDeps,
+		CheckConda:             checkConda,
+		VerifyPythonRecords:    verifyPythonRecords,
+		VerifyOSPackages:       verifyOSPackages,
+		CheckNode:              checkNode,
+		CheckGoBinaries:        checkGoBinaries,
+		CheckJava:              checkJava,
+		CheckPackageEcosystems: checkPackageEcosystems,
+		CheckELF:               checkELF,
+		CheckCUDA:              checkCUDA,
+		CheckModels:            checkModels,
+		CheckRuntimes:          checkRuntimes,
+		CheckSecrets:           checkSecrets,
+		SecretRuleFiles:        secretRuleFiles,
+		SecretAllowPaths:       secretAllowPaths,
+		SecretAllowRegexes:     secretAllowRegexes,
+		CheckMalware:           checkMalware,
+		MalwareRuleFiles:       malwareRuleFiles,
+		MalwareHashLists:       malwareHashLists,
+		MalwareMaxFileSize:     malwareMaxFileSize,
+		CheckLicenses:          checkLicenses,
+		LicenseAllow:           licenseAllow,
+		LicenseDeny:            licenseDeny,
+		CheckCertificates:      checkCertificates,
+		RequiredCAs:            requiredCAs,
+		CheckAccounts:          checkAccounts,
+		CheckPrivileges:        checkPrivileges,
+		CheckStartup:           checkStartup,
+		CheckHardening:         checkHardening,
+		VulnDB:                 vulnDB,
+		VulnDBMaxAge:           vulnDBMaxAge,
+		AllowStaleDB:           allowStaleDB,
+		IntegrityWorkers:       integrityWorkers,
+		CheckCommonTools:       checkCommonTools,
+		CommonTools:            commonTools,
+		SpecificCommands:       specificCommands,
+	})
 
 	var output []byte
 	var marshalErr error
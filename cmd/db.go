@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"image-analyzer-go/pkg/analyze"
+	"image-analyzer-go/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+var dbDir string
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "管理本地漏洞库",
+	Long:  `导入、查看和导出按生态和包名索引的本地漏洞库，供 scan 和 analyze --vuln-db 离线使用。`,
+}
+
+var dbImportCmd = &cobra.Command{
+	Use:   "import <osv.zip|dir|bundle.tar.gz>...",
+	Short: "从 OSV 数据或离线包导入漏洞库",
+	Long: `将 OSV 目录、zip 包或 JSON 文件导入为索引库，多个数据源会合并导入，并替换已有的库。
+也可以导入 db export 生成的离线包。`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		meta, err := analyze.ImportVulnDB(dbDir, args)
+		if err != nil {
+			return err
+		}
+		logger.Info("漏洞库导入完成",
+			logger.WithString("dir", dbDir),
+			logger.WithInt("records", meta.Records),
+			logger.WithInt("packages", meta.Packages),
+			logger.WithString("elapsed", time.Since(start).Round(time.Millisecond).String()))
+		return nil
+	},
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "查看漏洞库的来源和更新时间",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := analyze.OpenVulnDB(dbDir)
+		if err != nil {
+			return err
+		}
+		if db.Meta == nil {
+			return fmt.Errorf("%s 不是 db import 生成的漏洞库", dbDir)
+		}
+		meta := db.Meta
+		age, _ := db.Age(time.Now())
+		ecosystems := make([]string, 0, len(meta.Ecosystems))
+		for name, n := range meta.Ecosystems {
+			ecosystems = append(ecosystems, fmt.Sprintf("%s=%d", name, n))
+		}
+		sort.Strings(ecosystems)
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "目录:       %s\n", db.Path)
+		fmt.Fprintf(out, "格式版本:   %d\n", meta.SchemaVersion)
+		fmt.Fprintf(out, "数据源:     %s\n", strings.Join(meta.Sources, ", "))
+		fmt.Fprintf(out, "导入时间:   %s\n", meta.ImportedAt.Local().Format(time.RFC3339))
+		if !meta.UpdatedAt.IsZero() {
+			fmt.Fprintf(out, "数据时间:   %s\n", meta.UpdatedAt.Local().Format(time.RFC3339))
+		}
+		fmt.Fprintf(out, "已有:       %s没有更新\n", analyze.FormatAge(age))
+		fmt.Fprintf(out, "记录数:     %d\n", meta.Records)
+		fmt.Fprintf(out, "包数:       %d\n", meta.Packages)
+		fmt.Fprintf(out, "生态:       %s\n", strings.Join(ecosystems, ", "))
+		fmt.Fprintf(out, "校验:       通过 (data sha256 %s)\n", meta.DataSHA256)
+		return nil
+	},
+}
+
+var dbExportCmd = &cobra.Command{
+	Use:   "export <bundle.tar.gz>",
+	Short: "将漏洞库导出为离线包",
+	Long:  `校验漏洞库后将其打包为 .tar.gz 离线包，复制到无法联网的机器上后用 db import 导入。`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sum, err := analyze.ExportVulnDB(dbDir, args[0])
+		if err != nil {
+			return err
+		}
+		logger.Info("离线包已导出", logger.WithString("file", args[0]), logger.WithString("sha256", sum))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbImportCmd, dbStatusCmd, dbExportCmd)
+	dbCmd.PersistentFlags().StringVar(&dbDir, "dir", analyze.DefaultVulnDBDir(), "漏洞库目录")
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"image-analyzer-go/pkg/analyze"
+	"image-analyzer-go/pkg/imageutil"
+	"image-analyzer-go/pkg/logger"
+	"image-analyzer-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	scanDB         string
+	scanOutput     string
+	scanFormat     string
+	scanUnpackDir  string
+	scanWorkers    int
+	scanMaxAge     time.Duration
+	scanAllowStale bool
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [image-reference]",
+	Short: "使用本地漏洞数据源扫描容器镜像",
+	Long: `提取镜像中的系统包和各语言的包，与本地漏洞库离线匹配并生成漏洞报告。
+漏洞库可以是 db import 生成的索引库，也可以是原始的 OSV 目录、zip 包或 JSON 文件。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runScan(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+	scanCmd.Flags().StringVar(&scanDB, "db", analyze.DefaultVulnDBDir(), "漏洞库：db import 生成的索引库，或者 OSV 目录、zip 包和 JSON 文件")
+	scanCmd.Flags().StringVarP(&scanOutput, "output", "o", "vulnerabilities.json", "输出报告的文件路径")
+	scanCmd.Flags().StringVarP(&scanFormat, "format", "f", "json", "输出格式 (json 或 yaml)")
+	scanCmd.Flags().StringVarP(&scanUnpackDir, "unpack-dir", "d", "images", "解压缩镜像的临时目录")
+	scanCmd.Flags().IntVar(&scanWorkers, "workers", 0, "扫描二进制文件的并发数，0 表示使用默认值")
+	scanCmd.Flags().DurationVar(&scanMaxAge, "max-db-age", 7*24*time.Hour, "漏洞库允许的最长未更新时间，超过时拒绝扫描，0 表示不检查")
+	scanCmd.Flags().BoolVar(&scanAllowStale, "allow-stale", false, "漏洞库过期时仍然扫描，只记录警告")
+}
+
+func runScan(ref string) error {
+	ctx := context.Background()
+
+	// 先检查漏洞库，避免拉取镜像后才发现无法扫描
+	db, err := analyze.OpenVulnDB(scanDB)
+	if err != nil {
+		return err
+	}
+	if err := db.CheckAge(scanMaxAge, scanAllowStale); err != nil {
+		return err
+	}
+
+	imageDir, _, _, err := imageutil.PullAndExtract(ctx, ref, scanUnpackDir)
+	if err != nil {
+		return utils.WrapError(err, "提取镜像失败")
+	}
+	defer func(dir string) {
+		if dir == "" {
+			return
+		}
+		if cleanupErr := utils.CleanupTempDir(dir); cleanupErr != nil {
+			logger.Warn("清理临时目录失败", logger.WithString("dir", dir), logger.WithError(cleanupErr))
+		}
+	}(imageDir)
+
+	report := analyze.ScanVulnerabilities(imageDir, db, scanWorkers)
+
+	var output []byte
+	var marshalErr error
+
+	switch scanFormat {
+	case "yaml":
+		output, marshalErr = yaml.Marshal(report)
+	case "json":
+		output, marshalErr = json.MarshalIndent(report, "", "  ")
+	default:
+		return errors.New("不支持的输出格式: " + scanFormat)
+	}
+
+	if marshalErr != nil {
+		return utils.WrapError(marshalErr, "生成报告失败")
+	}
+
+	if err := utils.WriteFile(scanOutput, output, 0644); err != nil {
+		return utils.WrapError(err, "写入报告文件失败")
+	}
+
+	logger.Info("漏洞报告已保存",
+		logger.WithString("file", scanOutput),
+		logger.WithInt("vulnerabilities", len(report.Vulnerabilities)))
+	return nil
+}
@@ -0,0 +1,312 @@
+package analyze
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// passwdEntry 是 /etc/passwd 中的一行
+type passwdEntry struct {
+	Name string
+	// Password 为 passwd 中的密码字段，x 表示密码保存在 /etc/shadow 中
+	Password string
+	UID      int
+	GID      int
+	Home     string
+	Shell    string
+}
+
+// readPasswd 读取镜像中的 /etc/passwd，格式不正确的行被跳过
+func readPasswd(root string) []passwdEntry {
+	var entries []passwdEntry
+	readColonFile(rootPath(root, "etc/passwd"), 7, func(fields []string) {
+		uid, err1 := strconv.Atoi(fields[2])
+		gid, err2 := strconv.Atoi(fields[3])
+		if err1 != nil || err2 != nil {
+			return
+		}
+		entries = append(entries, passwdEntry{Name: fields[0], Password: fields[1], UID: uid, GID: gid, Home: fields[5], Shell: fields[6]})
+	})
+	return entries
+}
+
+// readShadow 读取镜像中的 /etc/shadow，返回用户名到密码字段的映射
+func readShadow(root string) map[string]string {
+	shadow := make(map[string]string)
+	readColonFile(rootPath(root, "etc/shadow"), 2, func(fields []string) {
+		shadow[fields[0]] = fields[1]
+	})
+	return shadow
+}
+
+// groupEntry 是 /etc/group 中的一行
+type groupEntry struct {
+	Name    string
+	GID     int
+	Members []string
+}
+
+// readGroup 读取镜像中的 /etc/group，格式不正确的行被跳过
+func readGroup(root string) []groupEntry {
+	var entries []groupEntry
+	readColonFile(rootPath(root, "etc/group"), 4, func(fields []string) {
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return
+		}
+		var members []string
+		for _, m := range strings.Split(fields[3], ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				members = append(members, m)
+			}
+		}
+		entries = append(entries, groupEntry{Name: fields[0], GID: gid, Members: members})
+	})
+	return entries
+}
+
+// readColonFile 逐行读取以冒号分隔的账户文件，字段数少于 minFields 的行以及注释被跳过
+func readColonFile(path string, minFields int, fn func(fields []string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < minFields {
+			continue
+		}
+		fn(fields)
+	}
+}
+
+// AccountReport 是镜像中的用户和组，以及镜像配置中 User 的检查结果
+type AccountReport struct {
+	Users  []Account `json:"users"`
+	Groups []Group   `json:"groups"`
+	// ConfigUser 为镜像配置中 User 的解析结果
+	ConfigUser ConfigUser `json:"config_user"`
+	Findings   []Finding  `json:"findings"`
+}
+
+// Account 是 /etc/passwd 中的一个用户，并合并了 /etc/shadow 和 /etc/group 中的信息
+type Account struct {
+	Name  string `json:"name"`
+	UID   int    `json:"uid"`
+	GID   int    `json:"gid"`
+	Group string `json:"group,omitempty"`
+	// Groups 为 /etc/group 中将该用户列为成员的附加组
+	Groups []string `json:"groups,omitempty"`
+	Home   string   `json:"home"`
+	Shell  string   `json:"shell"`
+	// Login 表示登录 shell 存在且不是 nologin、false 等禁止登录的程序
+	Login bool `json:"login"`
+	// PasswordSet 表示设置了可用的密码，密码为空、被锁定（! 开头）或为 * 时为 false
+	PasswordSet bool `json:"password_set"`
+}
+
+// Group 是 /etc/group 中的一个组
+type Group struct {
+	Name    string   `json:"name"`
+	GID     int      `json:"gid"`
+	Members []string `json:"members,omitempty"`
+}
+
+// ConfigUser 是镜像配置中 User（user[:group]，可以是名称或数字）的解析结果
+type ConfigUser struct {
+	User string `json:"user"`
+	// Name、UID、GID 为解析后的用户，User 为空时与运行时一样按 root 处理
+	Name string `json:"name,omitempty"`
+	UID  *int   `json:"uid,omitempty"`
+	GID  *int   `json:"gid,omitempty"`
+	// Resolved 表示用户和组都能在 /etc/passwd、/etc/group 中找到
+	Resolved bool `json:"resolved"`
+	// Home 为 /etc/passwd 中的家目录，运行时据此设置 HOME
+	Home       string `json:"home,omitempty"`
+	HomeExists bool   `json:"home_exists"`
+	// HomeOwner 为家目录在镜像层中记录的属主，无法确定时省略
+	HomeOwner *utils.Owner `json:"home_owner,omitempty"`
+	// WouldStart 为 false 表示用户或组无法解析，容器运行时会拒绝启动
+	WouldStart bool `json:"would_start"`
+}
+
+// noLoginShells 是禁止交互式登录的 shell
+var noLoginShells = []string{"nologin", "false", "sync", "shutdown", "halt"}
+
+// ListAccounts 解析 /etc/passwd、/etc/group 和 /etc/shadow，并检查镜像配置中的 User
+// 能否解析为已有账户，以及该账户的家目录是否存在、属主是否正确
+func ListAccounts(root string, imgCfg *v1.Image, extract *utils.ExtractInfo) *AccountReport {
+	report := &AccountReport{Users: []Account{}, Groups: []Group{}, Findings: []Finding{}}
+	passwd := readPasswd(root)
+	groups := readGroup(root)
+	shadow := readShadow(root)
+
+	groupNames := make(map[int]string)
+	for _, g := range groups {
+		if _, ok := groupNames[g.GID]; !ok {
+			groupNames[g.GID] = g.Name
+		}
+		report.Groups = append(report.Groups, Group{Name: g.Name, GID: g.GID, Members: g.Members})
+	}
+	for _, e := range passwd {
+		a := Account{Name: e.Name, UID: e.UID, GID: e.GID, Group: groupNames[e.GID], Home: e.Home, Shell: e.Shell}
+		for _, g := range groups {
+			if containsString(g.Members, e.Name) && g.GID != e.GID {
+				a.Groups = append(a.Groups, g.Name)
+			}
+		}
+		a.Login = loginShell(root, e.Shell)
+		password := e.Password
+		if password == "x" {
+			password = shadow[e.Name]
+		}
+		a.PasswordSet = password != "" && !strings.HasPrefix(password, "!") && !strings.HasPrefix(password, "*")
+		report.Users = append(report.Users, a)
+	}
+
+	var user string
+	if imgCfg != nil {
+		user = imgCfg.Config.User
+	}
+	report.ConfigUser, report.Findings = checkConfigUser(root, user, passwd, groups, extract)
+	return report
+}
+
+// loginShell 判断 shell 是否允许交互式登录：不是 nologin、false 等程序，且在镜像中存在
+func loginShell(root, shell string) bool {
+	if shell == "" {
+		return false
+	}
+	if containsString(noLoginShells, path.Base(shell)) {
+		return false
+	}
+	p, err := utils.SecureJoin(root, shell)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// checkConfigUser 按容器运行时的规则解析 User：用户名和组名必须存在，否则容器无法启动；
+// 数字 UID 不要求存在，但没有对应账户时 HOME 为 /，部分程序会因此出错
+func checkConfigUser(root, user string, passwd []passwdEntry, groups []groupEntry, extract *utils.ExtractInfo) (ConfigUser, []Finding) {
+	cu := ConfigUser{User: user, WouldStart: true}
+	findings := []Finding{}
+	userPart, groupPart, hasGroup := strings.Cut(user, ":")
+	if userPart == "" {
+		userPart = "0"
+	}
+
+	var entry *passwdEntry
+	uid, err := strconv.Atoi(userPart)
+	numeric := err == nil
+	for i := range passwd {
+		if (numeric && passwd[i].UID == uid) || (!numeric && passwd[i].Name == userPart) {
+			entry = &passwd[i]
+			break
+		}
+	}
+	cu.Resolved = entry != nil
+	switch {
+	case entry != nil:
+		cu.Name, cu.Home = entry.Name, entry.Home
+		cu.UID, cu.GID = intPtr(entry.UID), intPtr(entry.GID)
+	case numeric && user == "":
+		// 没有设置 USER 且没有 /etc/passwd（例如 scratch 镜像）时以 0:0 运行
+		cu.UID, cu.GID = intPtr(0), intPtr(0)
+	case numeric:
+		cu.UID, cu.GID = intPtr(uid), intPtr(0)
+		findings = append(findings, Finding{
+			ID:          "config-user-no-account",
+			Severity:    SeverityLow,
+			Description: fmt.Sprintf("镜像的 USER 为 UID %d，/etc/passwd 中没有对应的账户，运行时 HOME 为 /，依赖用户名或家目录的程序可能出错", uid),
+			Remediation: "在镜像中用 useradd 创建该 UID 的账户，或改用已有的用户",
+			Files:       []string{"/etc/passwd"},
+		})
+	default:
+		cu.WouldStart = false
+		findings = append(findings, Finding{
+			ID:          "config-user-not-found",
+			Severity:    SeverityHigh,
+			Description: fmt.Sprintf("镜像的 USER 为 %s，但 /etc/passwd 中没有该用户，容器启动时会报 no matching entries in passwd file", userPart),
+			Remediation: "在 Dockerfile 中先用 useradd 创建该用户，或将 USER 改为数字 UID",
+			Files:       []string{"/etc/passwd"},
+		})
+	}
+
+	if hasGroup && groupPart != "" {
+		gid, err := strconv.Atoi(groupPart)
+		found := err == nil
+		for _, g := range groups {
+			if !found && g.Name == groupPart {
+				gid, found = g.GID, true
+			}
+		}
+		if found {
+			cu.GID = intPtr(gid)
+		} else {
+			cu.Resolved, cu.WouldStart = false, false
+			findings = append(findings, Finding{
+				ID:          "config-group-not-found",
+				Severity:    SeverityHigh,
+				Description: fmt.Sprintf("镜像的 USER 指定的组 %s 在 /etc/group 中不存在，容器启动时会报 no matching entries in group file", groupPart),
+				Remediation: "在 Dockerfile 中先用 groupadd 创建该组，或改用数字 GID",
+				Files:       []string{"/etc/group"},
+			})
+		}
+	}
+
+	if entry == nil || entry.Home == "" {
+		return cu, findings
+	}
+	home := path.Clean(entry.Home)
+	p, err := utils.SecureJoin(root, home)
+	if err == nil {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			cu.HomeExists = true
+		}
+	}
+	if !cu.HomeExists {
+		findings = append(findings, Finding{
+			ID:          "config-user-home-missing",
+			Severity:    SeverityMedium,
+			Description: fmt.Sprintf("用户 %s 的家目录 %s 在镜像中不存在，写入 HOME 下缓存或配置的程序会失败", entry.Name, home),
+			Remediation: "创建用户时使用 useradd -m，或在 Dockerfile 中创建该目录并 chown 给该用户",
+			Files:       []string{home},
+		})
+		return cu, findings
+	}
+	if owner, ok := extract.FileOwner(home); ok {
+		cu.HomeOwner = &owner
+		// 根目录等共享目录作为家目录时不要求属主
+		if home != "/" && owner.UID != entry.UID {
+			findings = append(findings, Finding{
+				ID:          "config-user-home-owner",
+				Severity:    SeverityMedium,
+				Description: fmt.Sprintf("用户 %s（UID %d）的家目录 %s 属于 UID %d，该用户可能无法在其中写入", entry.Name, entry.UID, home, owner.UID),
+				Remediation: fmt.Sprintf("在 Dockerfile 中执行 chown %d:%d %s，或使用 COPY --chown", entry.UID, entry.GID, home),
+				Files:       []string{home},
+			})
+		}
+	}
+	return cu, findings
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,127 @@
+package analyze
+
+import (
+	"image-analyzer-go/pkg/utils"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Analyze 按照选项对已解压的镜像文件系统执行各项分析并生成报告。
+// extract 为解压时记录的文件来源，可以为 nil，此时报告中不包含层信息
+func Analyze(root string, imgCfg *v1.Image, extract *utils.ExtractInfo, opts *AnalyzeOptions) Summary {
+	summary := Summary{
+		Architecture: imgCfg.Architecture,
+		OS:           imgCfg.OS,
+		Env:          imgCfg.Config.Env,
+	}
+
+	if opts.CheckOSInfo {
+		summary.OSInfo = CheckOSInfo(root)
+	}
+	if opts.CheckOSInfo || opts.CheckPythonDeps {
+		summary.Libc = DetectLibc(root)
+	}
+	if opts.CheckPythonPackages {
+		summary.PythonPackages = ListPythonPackages(root)
+	}
+	if opts.CheckPythonEnvs || opts.CheckPythonDeps {
+		envs := DiscoverPythonEnvironments(root)
+		if opts.CheckPythonEnvs {
+			summary.PythonEnvironments = envs
+			summary.PythonCommands = ResolvePythonCommands(root, imgCfg.Config.Env, envs)
+		}
+		if opts.CheckPythonDeps {
+			summary.PythonDependencyCheck = CheckPythonDependencies(root, envs, imgCfg.Architecture, summary.Libc)
+		}
+	}
+	if opts.CheckConda {
+		summary.CondaEnvironments = ListCondaEnvironments(root)
+	}
+	if opts.VerifyPythonRecords {
+		summary.PythonIntegrity = VerifyPythonRecords(root, opts.IntegrityWorkers)
+	}
+	if opts.VerifyOSPackages {
+		summary.OSIntegrity = VerifyOSPackages(root, opts.IntegrityWorkers)
+	}
+	if opts.CheckNode {
+		summary.Node = ListNodePackages(root)
+	}
+	if opts.CheckGoBinaries {
+		summary.GoBinaries = ListGoBinaries(root, opts.IntegrityWorkers)
+	}
+	if opts.CheckJava {
+		summary.Java = ListJavaPackages(root, opts.IntegrityWorkers)
+	}
+	if opts.CheckPackageEcosystems {
+		summary.Packages = ListPackages(root, opts.IntegrityWorkers)
+	}
+	if opts.CheckELF {
+		summary.ELFFiles = ListELFFiles(root, imgCfg.Config.Env, opts.IntegrityWorkers)
+	}
+	if opts.CheckCUDA {
+		summary.CUDA = ListCUDAStack(root, opts.IntegrityWorkers)
+	}
+	if opts.CheckModels {
+		summary.Models = ListModelArtifacts(root, opts.IntegrityWorkers)
+	}
+	if opts.CheckRuntimes {
+		summary.Runtimes = ListRuntimes(root)
+	}
+	if opts.CheckSecrets {
+		summary.Secrets = ScanSecrets(root, imgCfg, extract, SecretScanOptions{
+			RuleFiles:    opts.SecretRuleFiles,
+			AllowPaths:   opts.SecretAllowPaths,
+			AllowRegexes: opts.SecretAllowRegexes,
+		}, opts.IntegrityWorkers)
+	}
+	if opts.CheckMalware {
+		summary.Malware = ScanMalware(root, imgCfg, extract, MalwareScanOptions{
+			RuleFiles:   opts.MalwareRuleFiles,
+			HashLists:   opts.MalwareHashLists,
+			MaxFileSize: opts.MalwareMaxFileSize,
+		}, opts.IntegrityWorkers)
+	}
+	if opts.CheckLicenses {
+		summary.Licenses = CheckLicenses(root, LicensePolicy{Allow: opts.LicenseAllow, Deny: opts.LicenseDeny}, opts.IntegrityWorkers)
+	}
+	if opts.CheckCertificates {
+		summary.Certificates = ListCertificates(root, opts.RequiredCAs, opts.IntegrityWorkers)
+	}
+	if opts.CheckAccounts {
+		summary.Accounts = ListAccounts(root, imgCfg, extract)
+	}
+	if opts.CheckPrivileges {
+		summary.Privileges = ListPrivilegedFiles(root, extract)
+	}
+	if opts.CheckStartup {
+		summary.Startup = CheckStartup(root, imgCfg)
+	}
+	if opts.CheckHardening {
+		summary.Findings = CheckHardening(root, imgCfg, extract)
+	}
+	if opts.VulnDB != "" {
+		db, err := OpenVulnDB(opts.VulnDB)
+		if err == nil {
+			err = db.CheckAge(opts.VulnDBMaxAge, opts.AllowStaleDB)
+		}
+		if err != nil {
+			summary.Vulnerabilities = newVulnerabilityReport(opts.VulnDB)
+			summary.Vulnerabilities.Errors = []string{err.Error()}
+		} else {
+			summary.Vulnerabilities = ScanVulnerabilities(root, db, opts.IntegrityWorkers)
+		}
+	}
+	var commands []string
+	if opts.CheckCommonTools {
+		summary.Tools = CheckCommonTools(root, imgCfg.Config.Env, opts.CommonTools)
+		commands = append(commands, opts.CommonTools...)
+		if len(opts.CommonTools) == 0 {
+			commands = append(commands, DefaultCommonTools...)
+		}
+	}
+	commands = append(commands, opts.SpecificCommands...)
+	if len(commands) > 0 {
+		summary.Commands = ResolveCommands(root, imgCfg.Config.Env, uniqueStrings(commands))
+	}
+	return summary
+}
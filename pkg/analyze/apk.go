@@ -0,0 +1,66 @@
+package analyze
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// apkPackage 是 /lib/apk/db/installed 中的一个已安装包
+type apkPackage struct {
+	Name    string
+	Version string
+	// Origin 为构建该包的源包（APKBUILD）名称，安全公告按源包发布
+	Origin string
+	Arch   string
+	// License 为 L: 字段声明的许可证，通常是 SPDX 表达式
+	License string
+}
+
+// readAPKPackages 读取 Alpine 的已安装包数据库，各包之间以空行分隔，每行是一个单字母字段
+func readAPKPackages(root string) []apkPackage {
+	f, err := os.Open(rootPath(root, "lib/apk/db/installed"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var pkgs []apkPackage
+	var cur apkPackage
+	flush := func() {
+		if cur.Name != "" {
+			if cur.Origin == "" {
+				cur.Origin = cur.Name
+			}
+			pkgs = append(pkgs, cur)
+		}
+		cur = apkPackage{}
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "P":
+			cur.Name = value
+		case "V":
+			cur.Version = value
+		case "o":
+			cur.Origin = value
+		case "A":
+			cur.Arch = value
+		case "L":
+			cur.License = value
+		}
+	}
+	flush()
+	return pkgs
+}
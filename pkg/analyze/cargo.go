@@ -0,0 +1,83 @@
+package analyze
+
+import (
+	"compress/zlib"
+	"debug/elf"
+	"encoding/json"
+	"io"
+	"os"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// cargoAuditableAnalyzer 解析 cargo-auditable 构建的 Rust 二进制中 .dep-v0 段记录的依赖
+type cargoAuditableAnalyzer struct{}
+
+// cargoAuditableSection 是 cargo-auditable 写入依赖信息的 ELF 段
+const cargoAuditableSection = ".dep-v0"
+
+// maxCargoAuditableSize 是解压后依赖信息的最大大小
+const maxCargoAuditableSize = 8 << 20
+
+// cargoAuditableInfo 是 .dep-v0 段中 zlib 压缩的 JSON
+type cargoAuditableInfo struct {
+	Packages []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Source  string `json:"source"`
+		Kind    string `json:"kind"`
+		Root    bool   `json:"root"`
+	} `json:"packages"`
+}
+
+func (cargoAuditableAnalyzer) match(path string, info os.FileInfo) bool {
+	// 解压时不一定保留了可执行位，这里只按大小粗筛，在 analyze 中再判断是否为 ELF
+	return info.Size() >= 1024
+}
+
+func (cargoAuditableAnalyzer) analyze(root, path string) []Package {
+	if !isELF(path) {
+		return nil
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	section := f.Section(cargoAuditableSection)
+	if section == nil {
+		return nil
+	}
+
+	zr, err := zlib.NewReader(section.Open())
+	if err != nil {
+		logger.Debug("解压 cargo-auditable 依赖信息失败", logger.WithString("path", imagePath(root, path)), logger.WithError(err))
+		return nil
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxCargoAuditableSize))
+	if err != nil {
+		return nil
+	}
+	var info cargoAuditableInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		logger.Debug("解析 cargo-auditable 依赖信息失败", logger.WithString("path", imagePath(root, path)), logger.WithError(err))
+		return nil
+	}
+
+	location := imagePath(root, path)
+	var pkgs []Package
+	for _, p := range info.Packages {
+		// root 为二进制自身所属的 crate；只在构建时使用的依赖不会进入二进制
+		if p.Root || p.Kind == "build" {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Ecosystem: EcosystemCargo,
+			Name:      p.Name,
+			Version:   p.Version,
+			Location:  location,
+		})
+	}
+	return pkgs
+}
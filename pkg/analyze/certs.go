@@ -0,0 +1,453 @@
+package analyze
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CertificateReport 是镜像中的证书、信任库和私钥清单
+type CertificateReport struct {
+	// Certificates 按 SHA-256 指纹去重，同一证书出现在多个文件中时合并位置
+	Certificates []CertificateInfo `json:"certificates"`
+	PrivateKeys  []PrivateKeyInfo  `json:"private_keys"`
+	RequiredCAs  []RequiredCA      `json:"required_cas,omitempty"`
+	Expired      int               `json:"expired"`
+	// Findings 为过期证书、镜像中的私钥和缺失的内部 CA
+	Findings []Finding `json:"findings"`
+	// Errors 为无法完整读取的密钥库
+	Errors []string `json:"errors,omitempty"`
+}
+
+// CertificateInfo 是一个 X.509 证书
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Expired      bool      `json:"expired"`
+	IsCA         bool      `json:"is_ca"`
+	SelfSigned   bool      `json:"self_signed"`
+	// SHA256 为证书 DER 编码的 SHA-256 指纹
+	SHA256    string                `json:"sha256"`
+	DNSNames  []string              `json:"dns_names,omitempty"`
+	Locations []CertificateLocation `json:"locations"`
+	// PrivateKeys 为镜像中与证书公钥匹配的私钥所在文件
+	PrivateKeys []string `json:"private_keys,omitempty"`
+}
+
+// CertificateLocation 是证书所在的文件
+type CertificateLocation struct {
+	Path string `json:"path"`
+	// Format 为 pem、der、jks 或 pkcs12
+	Format string `json:"format"`
+	// Alias 为密钥库中的条目别名
+	Alias string `json:"alias,omitempty"`
+}
+
+// PrivateKeyInfo 是镜像中的一个私钥
+type PrivateKeyInfo struct {
+	Path  string `json:"path"`
+	Alias string `json:"alias,omitempty"`
+	// Type 为 RSA、ECDSA、Ed25519、OpenSSH 等，加密或无法解析时为 unknown
+	Type      string `json:"type"`
+	Encrypted bool   `json:"encrypted"`
+	// Certificates 为与该私钥匹配的证书指纹
+	Certificates []string `json:"certificates,omitempty"`
+}
+
+// RequiredCA 是配置中要求镜像信任的 CA 及其检查结果
+type RequiredCA struct {
+	Name  string   `json:"name"`
+	Found bool     `json:"found"`
+	Paths []string `json:"paths,omitempty"`
+}
+
+const (
+	CertFormatPEM    = "pem"
+	CertFormatDER    = "der"
+	CertFormatJKS    = "jks"
+	CertFormatPKCS12 = "pkcs12"
+)
+
+// certFileMaxSize 是读取证书和密钥库文件的最大大小，系统 CA 包一般在 300KB 以内
+const certFileMaxSize = 4 << 20
+
+// certFileExts 是证书、私钥和密钥库的常见扩展名
+var certFileExts = []string{".pem", ".crt", ".cer", ".cert", ".der", ".key", ".jks", ".keystore", ".truststore", ".p12", ".pfx"}
+
+// certDirs 是系统信任库所在目录，其中的文件不论扩展名都会检查
+var certDirs = []string{"/etc/ssl/", "/etc/pki/", "/usr/share/ca-certificates/", "/usr/local/share/ca-certificates/"}
+
+// certFileResult 是从一个文件中解析出的证书和私钥
+type certFileResult struct {
+	path  string
+	certs []parsedCert
+	keys  []parsedKey
+	err   error
+}
+
+type parsedCert struct {
+	cert   *x509.Certificate
+	format string
+	alias  string
+}
+
+type parsedKey struct {
+	alias     string
+	typ       string
+	encrypted bool
+	public    crypto.PublicKey
+	// chain 为密钥库中与私钥保存在同一条目的证书
+	chain []*x509.Certificate
+}
+
+// ListCertificates 查找系统 CA 包、/etc/pki、Java cacerts 以及 PEM/DER 证书和私钥，
+// 检查证书是否过期、是否有匹配的私钥，以及 requiredCAs 中的 CA 是否存在。
+// requiredCAs 的每一项可以是证书的 SHA-256 指纹、CN 或主题中的一部分
+func ListCertificates(root string, requiredCAs []string, workers int) *CertificateReport {
+	report := &CertificateReport{Certificates: []CertificateInfo{}, PrivateKeys: []PrivateKeyInfo{}, Findings: []Finding{}}
+
+	var files []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() && info.Size() > 0 && info.Size() <= certFileMaxSize && isCertCandidate(imagePath(root, path)) {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	results := make([]certFileResult, len(files))
+	runParallel(len(files), workers, func(i int) {
+		results[i] = parseCertFile(files[i])
+		results[i].path = imagePath(root, files[i])
+	})
+
+	now := time.Now()
+	index := make(map[string]int)
+	byPublicKey := make(map[string][]int)
+	for _, r := range results {
+		if r.err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", r.path, r.err))
+		}
+		for _, c := range r.certs {
+			sum := sha256.Sum256(c.cert.Raw)
+			fp := hex.EncodeToString(sum[:])
+			i, ok := index[fp]
+			if !ok {
+				i = len(report.Certificates)
+				index[fp] = i
+				report.Certificates = append(report.Certificates, newCertificateInfo(c.cert, fp, now))
+				if pub, err := x509.MarshalPKIXPublicKey(c.cert.PublicKey); err == nil {
+					byPublicKey[string(pub)] = append(byPublicKey[string(pub)], i)
+				}
+			}
+			report.Certificates[i].Locations = append(report.Certificates[i].Locations, CertificateLocation{Path: r.path, Format: c.format, Alias: c.alias})
+		}
+	}
+
+	for _, r := range results {
+		for _, k := range r.keys {
+			info := PrivateKeyInfo{Path: r.path, Alias: k.alias, Type: k.typ, Encrypted: k.encrypted}
+			var matched []int
+			if k.public != nil {
+				if pub, err := x509.MarshalPKIXPublicKey(k.public); err == nil {
+					matched = append(matched, byPublicKey[string(pub)]...)
+				}
+			}
+			for _, c := range k.chain {
+				sum := sha256.Sum256(c.Raw)
+				if i, ok := index[hex.EncodeToString(sum[:])]; ok {
+					matched = append(matched, i)
+					break
+				}
+			}
+			for _, i := range matched {
+				cert := &report.Certificates[i]
+				if !containsString(info.Certificates, cert.SHA256) {
+					info.Certificates = append(info.Certificates, cert.SHA256)
+				}
+				if !containsString(cert.PrivateKeys, r.path) {
+					cert.PrivateKeys = append(cert.PrivateKeys, r.path)
+				}
+			}
+			report.PrivateKeys = append(report.PrivateKeys, info)
+		}
+	}
+
+	sort.SliceStable(report.Certificates, func(i, j int) bool {
+		a, b := report.Certificates[i], report.Certificates[j]
+		if a.Locations[0].Path != b.Locations[0].Path {
+			return a.Locations[0].Path < b.Locations[0].Path
+		}
+		return a.Subject < b.Subject
+	})
+	var expired []string
+	for _, c := range report.Certificates {
+		if !c.Expired {
+			continue
+		}
+		report.Expired++
+		for _, l := range c.Locations {
+			if !containsString(expired, l.Path) {
+				expired = append(expired, l.Path)
+			}
+		}
+	}
+
+	for _, name := range requiredCAs {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		req := RequiredCA{Name: name}
+		for _, c := range report.Certificates {
+			if c.IsCA && matchRequiredCA(c, name) {
+				req.Found = true
+				for _, l := range c.Locations {
+					if !containsString(req.Paths, l.Path) {
+						req.Paths = append(req.Paths, l.Path)
+					}
+				}
+			}
+		}
+		report.RequiredCAs = append(report.RequiredCAs, req)
+	}
+
+	report.Findings = certificateFindings(report, expired)
+	return report
+}
+
+// isCertCandidate 根据路径判断文件是否可能是证书、私钥或密钥库
+func isCertCandidate(p string) bool {
+	for _, dir := range certDirs {
+		if strings.HasPrefix(p, dir) {
+			return true
+		}
+	}
+	name := strings.ToLower(filepath.Base(p))
+	return name == "cacerts" || containsString(certFileExts, filepath.Ext(name))
+}
+
+// parseCertFile 按内容识别文件格式：JKS/JCEKS 魔数、PEM 块，其余的按 DER 证书、PKCS#12 和 DER 私钥依次尝试
+func parseCertFile(path string) certFileResult {
+	var result certFileResult
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result
+	}
+	switch {
+	case len(data) >= 4 && (bytes.HasPrefix(data, []byte{0xFE, 0xED, 0xFE, 0xED}) || bytes.HasPrefix(data, []byte{0xCE, 0xCE, 0xCE, 0xCE})):
+		entries, err := parseJKS(data)
+		result.addEntries(entries, CertFormatJKS)
+		result.err = err
+	case bytes.Contains(data, []byte("-----BEGIN ")):
+		result.parsePEM(data)
+	case len(data) > 0 && data[0] == 0x30:
+		if cert, err := x509.ParseCertificate(data); err == nil {
+			result.certs = append(result.certs, parsedCert{cert: cert, format: CertFormatDER})
+		} else if entries, err := parsePKCS12(data); err != errNotPKCS12 {
+			result.addEntries(entries, CertFormatPKCS12)
+			result.err = err
+		} else if k := parseDERPrivateKey(data); k != nil {
+			result.keys = append(result.keys, *k)
+		}
+	}
+	return result
+}
+
+func (r *certFileResult) addEntries(entries []keystoreEntry, format string) {
+	for _, e := range entries {
+		for _, c := range e.certs {
+			r.certs = append(r.certs, parsedCert{cert: c, format: format, alias: e.alias})
+		}
+		if e.privateKey {
+			k := parsedKey{alias: e.alias, typ: "unknown", encrypted: e.key == nil, chain: e.certs}
+			if e.key != nil {
+				k.typ, k.public = privateKeyType(e.key)
+			}
+			r.keys = append(r.keys, k)
+		}
+	}
+}
+
+// parsePEM 读取文件中的全部 PEM 块，忽略证书请求、参数等其它类型
+func (r *certFileResult) parsePEM(data []byte) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return
+		}
+		switch block.Type {
+		case "CERTIFICATE", "TRUSTED CERTIFICATE", "X509 CERTIFICATE":
+			// OpenSSL 的 TRUSTED CERTIFICATE 在证书后附加了信任设置，只取第一个 DER 元素
+			if cert, err := x509.ParseCertificate(trimDERElement(block.Bytes)); err == nil {
+				r.certs = append(r.certs, parsedCert{cert: cert, format: CertFormatPEM})
+			}
+		case "ENCRYPTED PRIVATE KEY":
+			r.keys = append(r.keys, parsedKey{typ: "unknown", encrypted: true})
+		case "OPENSSH PRIVATE KEY":
+			r.keys = append(r.keys, parsedKey{typ: "OpenSSH", encrypted: bytes.Contains(block.Bytes, []byte("bcrypt"))})
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			// 旧格式的加密 PEM（Proc-Type 头）只识别，不解密
+			if x509.IsEncryptedPEMBlock(block) {
+				r.keys = append(r.keys, parsedKey{typ: strings.TrimSuffix(block.Type, " PRIVATE KEY"), encrypted: true})
+			} else if k := parseDERPrivateKey(block.Bytes); k != nil {
+				r.keys = append(r.keys, *k)
+			} else {
+				r.keys = append(r.keys, parsedKey{typ: "unknown"})
+			}
+		}
+	}
+}
+
+// trimDERElement 返回数据开头的第一个 DER 元素
+func trimDERElement(der []byte) []byte {
+	if len(der) < 2 || der[0] != 0x30 {
+		return der
+	}
+	length, header := int(der[1]), 2
+	if der[1]&0x80 != 0 {
+		n := int(der[1] & 0x7f)
+		if n == 0 || n > 4 || len(der) < 2+n {
+			return der
+		}
+		length = 0
+		for _, b := range der[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		header += n
+	}
+	if header+length > len(der) {
+		return der
+	}
+	return der[:header+length]
+}
+
+// parseDERPrivateKey 依次按 PKCS#8、PKCS#1 和 SEC 1 格式解析私钥
+func parseDERPrivateKey(der []byte) *parsedKey {
+	var key any
+	var err error
+	if key, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(der); err != nil {
+			if key, err = x509.ParseECPrivateKey(der); err != nil {
+				return nil
+			}
+		}
+	}
+	k := &parsedKey{}
+	k.typ, k.public = privateKeyType(key)
+	return k
+}
+
+// privateKeyType 返回私钥的算法名称和对应的公钥
+func privateKeyType(key any) (string, crypto.PublicKey) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "unknown", nil
+	}
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return "RSA", pub
+	case *ecdsa.PublicKey:
+		return "ECDSA", pub
+	case ed25519.PublicKey:
+		return "Ed25519", pub
+	default:
+		return "unknown", pub
+	}
+}
+
+func newCertificateInfo(cert *x509.Certificate, fingerprint string, now time.Time) CertificateInfo {
+	return CertificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.Text(16),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		Expired:      now.After(cert.NotAfter),
+		IsCA:         cert.IsCA,
+		SelfSigned:   bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil,
+		SHA256:       fingerprint,
+		DNSNames:     cert.DNSNames,
+	}
+}
+
+// matchRequiredCA 按指纹（可以带冒号）、CN 或主题的一部分匹配证书，不区分大小写
+func matchRequiredCA(c CertificateInfo, name string) bool {
+	fp := strings.ToLower(strings.ReplaceAll(name, ":", ""))
+	if fp == c.SHA256 {
+		return true
+	}
+	if strings.EqualFold(certCommonName(c.Subject), name) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(c.Subject), strings.ToLower(name))
+}
+
+// certCommonName 从 RFC 2253 格式的主题中取出 CN
+func certCommonName(subject string) string {
+	for _, part := range strings.Split(subject, ",") {
+		if cn, ok := strings.CutPrefix(part, "CN="); ok {
+			return cn
+		}
+	}
+	return ""
+}
+
+func certificateFindings(report *CertificateReport, expired []string) []Finding {
+	findings := []Finding{}
+	var missing []string
+	for _, r := range report.RequiredCAs {
+		if !r.Found {
+			missing = append(missing, r.Name)
+		}
+	}
+	if len(missing) > 0 {
+		findings = append(findings, Finding{
+			ID:          "required-ca-missing",
+			Severity:    SeverityHigh,
+			Description: fmt.Sprintf("镜像中没有要求信任的 CA 证书：%s", strings.Join(missing, ", ")),
+			Remediation: "将内部 CA 证书复制到 /usr/local/share/ca-certificates/ 并运行 update-ca-certificates（RHEL 系为 /etc/pki/ca-trust/source/anchors/ 和 update-ca-trust），Java 应用还需要导入 cacerts",
+		})
+	}
+
+	var keys []string
+	for _, k := range report.PrivateKeys {
+		if !containsString(keys, k.Path) {
+			keys = append(keys, k.Path)
+		}
+	}
+	if len(keys) > 0 {
+		findings = append(findings, Finding{
+			ID:          "private-keys",
+			Severity:    SeverityHigh,
+			Description: fmt.Sprintf("镜像中有 %d 个包含私钥的文件，拉取镜像的人都可以读取", len(keys)),
+			Remediation: "不要把私钥打包进镜像，运行时通过 Secret 或卷挂载提供",
+			Files:       truncateFiles(keys),
+		})
+	}
+
+	if len(expired) > 0 {
+		findings = append(findings, Finding{
+			ID:          "expired-certificates",
+			Severity:    SeverityMedium,
+			Description: fmt.Sprintf("镜像中有 %d 个已过期的证书，分布在 %d 个文件中", report.Expired, len(expired)),
+			Remediation: "更新 ca-certificates 包或替换过期的证书，并移除不再使用的证书",
+			Files:       truncateFiles(expired),
+		})
+	}
+	return findings
+}
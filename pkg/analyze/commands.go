@@ -0,0 +1,126 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// CommandResolution 是按镜像 PATH 解析一个命令的结果，与 shell 查找命令的方式一致
+type CommandResolution struct {
+	Command string `json:"command"`
+	Found   bool   `json:"found"`
+	// Path 为 PATH 中命中的路径，Links 为解析过程中经过的符号链接，Resolved 为最终的文件
+	Path     string   `json:"path,omitempty"`
+	Links    []string `json:"links,omitempty"`
+	Resolved string   `json:"resolved,omitempty"`
+	// FileType 为 elf、script 或 other，脚本的解释器取自 shebang 行
+	FileType    string `json:"file_type,omitempty"`
+	Interpreter string `json:"interpreter,omitempty"`
+	// Shadowed 表示 PATH 中靠后的目录里还有同名的其他可执行文件，它们被命中的文件遮蔽
+	Shadowed      bool     `json:"shadowed"`
+	ShadowedPaths []string `json:"shadowed_paths,omitempty"`
+	// NotExecutable 是 PATH 中存在但没有可执行权限、因而被跳过的同名文件
+	NotExecutable []string `json:"not_executable,omitempty"`
+}
+
+const (
+	CommandFileELF    = "elf"
+	CommandFileScript = "script"
+	CommandFileOther  = "other"
+)
+
+// DefaultCommonTools 是配置中没有指定时默认检查的常用工具
+var DefaultCommonTools = []string{"sshd", "python3", "curl", "wget", "nvcc"}
+
+// ResolveCommands 按镜像配置中的 PATH 依次查找每个命令，在镜像根目录内跟随符号链接，
+// 只接受有可执行权限的普通文件。包含 / 的命令名直接按镜像内的路径解析，不查找 PATH
+func ResolveCommands(root string, env []string, names []string) []CommandResolution {
+	dirs := imagePATH(env)
+	results := make([]CommandResolution, 0, len(names))
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		candidates := []string{}
+		if strings.Contains(name, "/") {
+			candidates = append(candidates, filepath.Join("/", name))
+		} else {
+			for _, dir := range dirs {
+				candidates = append(candidates, filepath.Join("/", dir, name))
+			}
+		}
+		results = append(results, resolveCommand(root, name, candidates))
+	}
+	return results
+}
+
+// resolveCommand 依次检查候选路径，第一个可执行文件为命中结果。
+// 多个 PATH 目录解析到同一个文件（例如 /bin 是 /usr/bin 的链接）时不算作遮蔽
+func resolveCommand(root, name string, candidates []string) CommandResolution {
+	res := CommandResolution{Command: name}
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		resolved, links, err := utils.ResolveInRoot(root, candidate)
+		if err != nil || seen[resolved] {
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(root, resolved))
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		seen[resolved] = true
+		if fi.Mode()&0111 == 0 {
+			res.NotExecutable = append(res.NotExecutable, candidate)
+			continue
+		}
+		if res.Found {
+			res.ShadowedPaths = append(res.ShadowedPaths, candidate)
+			continue
+		}
+		res.Found = true
+		res.Path = candidate
+		res.Links = links
+		res.Resolved = resolved
+		res.FileType, res.Interpreter = commandFileType(filepath.Join(root, resolved))
+	}
+	res.Shadowed = len(res.ShadowedPaths) > 0
+	return res
+}
+
+// commandFileType 根据文件头判断是 ELF、带 shebang 的脚本还是其他文件
+func commandFileType(path string) (string, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return CommandFileOther, ""
+	}
+	defer f.Close()
+	r := bufio.NewReader(io.LimitReader(f, 256))
+	head, _ := r.Peek(4)
+	switch {
+	case bytes.Equal(head, []byte("\x7fELF")):
+		return CommandFileELF, ""
+	case bytes.HasPrefix(head, []byte("#!")):
+		line, _ := r.ReadString('\n')
+		return CommandFileScript, strings.TrimSpace(strings.TrimPrefix(line, "#!"))
+	}
+	return CommandFileOther, ""
+}
+
+// uniqueStrings 去除重复项并保持原有顺序
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package analyze
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// composerAnalyzer 解析 PHP Composer 的 vendor/composer/installed.json
+type composerAnalyzer struct{}
+
+// composerPackage 是 installed.json 中一个包用到的字段
+type composerPackage struct {
+	Name    string          `json:"name"`
+	Version string          `json:"version"`
+	License json.RawMessage `json:"license"`
+}
+
+// licenseString 兼容字符串和数组两种写法，多个许可证之间是“或”的关系
+func (p composerPackage) licenseString() string {
+	var licenses []string
+	if json.Unmarshal(p.License, &licenses) != nil {
+		var s string
+		if json.Unmarshal(p.License, &s) != nil {
+			return ""
+		}
+		return s
+	}
+	if len(licenses) > 1 {
+		return "(" + strings.Join(licenses, " OR ") + ")"
+	}
+	return strings.Join(licenses, "")
+}
+
+func (composerAnalyzer) match(path string, info os.FileInfo) bool {
+	return info.Name() == "installed.json" &&
+		filepath.Base(filepath.Dir(path)) == "composer" &&
+		filepath.Base(filepath.Dir(filepath.Dir(path))) == "vendor"
+}
+
+func (composerAnalyzer) analyze(root, path string) []Package {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	// Composer 1 为包数组，Composer 2 为 {"packages": [...]}
+	var installed []composerPackage
+	if err := json.Unmarshal(data, &installed); err != nil {
+		var v2 struct {
+			Packages []composerPackage `json:"packages"`
+		}
+		if err := json.Unmarshal(data, &v2); err != nil {
+			logger.Warn("解析 composer installed.json 失败", logger.WithString("file", imagePath(root, path)), logger.WithError(err))
+			return nil
+		}
+		installed = v2.Packages
+	}
+
+	location := imagePath(root, path)
+	var pkgs []Package
+	for _, p := range installed {
+		if p.Name == "" {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Ecosystem: EcosystemComposer,
+			Name:      p.Name,
+			Version:   strings.TrimPrefix(p.Version, "v"),
+			Location:  location,
+			License:   p.licenseString(),
+		})
+	}
+	return pkgs
+}
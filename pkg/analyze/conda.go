@@ -0,0 +1,236 @@
+package analyze
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// CondaEnvironment 是一个 conda 环境（包含 conda-meta 目录的前缀）的包清单
+type CondaEnvironment struct {
+	Prefix   string         `json:"prefix"`
+	Packages []CondaPackage `json:"packages"`
+	// PythonPackages 列出环境中每个 Python 包的安装来源（conda 或 pip）
+	PythonPackages []CondaPythonPackage `json:"python_packages"`
+	// Overlaps 列出同时被 conda 和 pip 安装、存在重复或遮盖的包
+	Overlaps []CondaPipOverlap `json:"overlaps"`
+}
+
+// CondaPackage 是 conda-meta 中记录的一个包
+type CondaPackage struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Build   string   `json:"build"`
+	Channel string   `json:"channel"`
+	Subdir  string   `json:"subdir,omitempty"`
+	License string   `json:"license,omitempty"`
+	Files   []string `json:"files"`
+}
+
+// CondaPythonPackage 是 conda 环境 site-packages 中的一个 Python 包及其安装来源
+type CondaPythonPackage struct {
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Installer    string `json:"installer"`
+	CondaPackage string `json:"conda_package,omitempty"`
+	MetaDir      string `json:"meta_dir"`
+}
+
+// Conda 与 pip 重叠的类型
+const (
+	// CondaOverlapDuplicate 表示 conda 和 pip 安装的元数据目录同时存在
+	CondaOverlapDuplicate = "duplicate"
+	// CondaOverlapShadowed 表示 conda 记录的包已被 pip 安装的版本覆盖
+	CondaOverlapShadowed = "shadowed"
+)
+
+// CondaPipOverlap 描述一个同时由 conda 和 pip 安装的 Python 包
+type CondaPipOverlap struct {
+	Name         string `json:"name"`
+	Kind         string `json:"kind"`
+	CondaPackage string `json:"conda_package"`
+	CondaVersion string `json:"conda_version"`
+	PipVersion   string `json:"pip_version"`
+	PipMetaDir   string `json:"pip_meta_dir"`
+}
+
+// condaMeta 对应 conda-meta/*.json 中用到的字段
+type condaMeta struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Build    string   `json:"build"`
+	Channel  string   `json:"channel"`
+	Subdir   string   `json:"subdir"`
+	License  string   `json:"license"`
+	Files    []string `json:"files"`
+	URL      string   `json:"url"`
+	SChannel string   `json:"schannel"`
+}
+
+// condaDistInfoPattern 匹配 conda 包文件列表中的 Python 元数据目录
+var condaDistInfoPattern = regexp.MustCompile(`^(.*/(?:site|dist)-packages/[^/]+\.(?:dist|egg)-info)(?:/|$)`)
+
+// ListCondaEnvironments 读取镜像中所有 conda 环境的 conda-meta 并区分 conda/pip 安装的 Python 包
+func ListCondaEnvironments(root string) []CondaEnvironment {
+	layout := scanPythonLayout(root)
+
+	prefixes := make([]string, 0, len(layout.condaEnvs))
+	for p := range layout.condaEnvs {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	var envs []CondaEnvironment
+	for _, prefix := range prefixes {
+		env := CondaEnvironment{
+			Prefix:         prefix,
+			Packages:       readCondaMeta(root, prefix),
+			PythonPackages: []CondaPythonPackage{},
+			Overlaps:       []CondaPipOverlap{},
+		}
+		for _, sp := range layout.sitePackages {
+			if strings.HasPrefix(sp, strings.TrimSuffix(prefix, "/")+"/lib/") {
+				classifyCondaPythonPackages(root, &env, sp)
+			}
+		}
+		envs = append(envs, env)
+	}
+	return envs
+}
+
+// readCondaMeta 解析 conda-meta 目录下每个包的 JSON 记录
+func readCondaMeta(root, prefix string) []CondaPackage {
+	pkgs := []CondaPackage{}
+	for _, m := range globInRoot(root, filepath.Join(prefix, "conda-meta", "*.json")) {
+		data, err := os.ReadFile(rootPath(root, m))
+		if err != nil {
+			continue
+		}
+		var meta condaMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			logger.Warn("解析 conda-meta 失败", logger.WithString("file", m), logger.WithError(err))
+			continue
+		}
+		if meta.Name == "" {
+			continue
+		}
+		files := meta.Files
+		if files == nil {
+			files = []string{}
+		}
+		pkgs = append(pkgs, CondaPackage{
+			Name:    meta.Name,
+			Version: meta.Version,
+			Build:   meta.Build,
+			Channel: condaChannelName(meta),
+			Subdir:  meta.Subdir,
+			License: meta.License,
+			Files:   files,
+		})
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs
+}
+
+// condaChannelName 将 channel 的 URL 转换为短名称，例如 conda-forge
+func condaChannelName(meta condaMeta) string {
+	if meta.SChannel != "" {
+		return meta.SChannel
+	}
+	channel := meta.Channel
+	if channel == "" {
+		channel = meta.URL
+	}
+	u, err := url.Parse(channel)
+	if err != nil || u.Host == "" {
+		return channel
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	// 去掉末尾的 subdir（linux-64、noarch）和包文件名
+	for len(parts) > 0 {
+		last := parts[len(parts)-1]
+		if last == meta.Subdir || last == "noarch" || strings.HasSuffix(last, ".conda") || strings.HasSuffix(last, ".tar.bz2") {
+			parts = parts[:len(parts)-1]
+			continue
+		}
+		break
+	}
+	if len(parts) == 0 {
+		return u.Host
+	}
+	return strings.Join(parts, "/")
+}
+
+// classifyCondaPythonPackages 根据 conda 包的文件清单判断 site-packages 中每个 Python 包是否由 conda 安装
+func classifyCondaPythonPackages(root string, env *CondaEnvironment, sitePackages string) {
+	// 元数据目录（相对前缀）-> 拥有它的 conda 包
+	owners := make(map[string]CondaPackage)
+	// 规范化的 Python 包名 -> conda 记录的元数据目录
+	condaDists := make(map[string]string)
+	relSite := strings.TrimPrefix(sitePackages, strings.TrimSuffix(env.Prefix, "/")+"/")
+	for _, pkg := range env.Packages {
+		for _, f := range pkg.Files {
+			m := condaDistInfoPattern.FindStringSubmatch(f)
+			if m == nil || filepath.Dir(m[1]) != relSite {
+				continue
+			}
+			if _, ok := owners[m[1]]; !ok {
+				owners[m[1]] = pkg
+				condaDists[NormalizePythonName(distNameFromMetaDir(m[1]))] = m[1]
+			}
+		}
+	}
+
+	start := len(env.PythonPackages)
+	present := make(map[string]bool)
+	for _, d := range listPythonDists(root, sitePackages) {
+		rel := strings.TrimPrefix(d.MetaDir, strings.TrimSuffix(env.Prefix, "/")+"/")
+		present[rel] = true
+		pp := CondaPythonPackage{Name: d.Name, Version: d.Version, MetaDir: d.MetaDir}
+		if owner, ok := owners[rel]; ok {
+			pp.Installer, pp.CondaPackage = "conda", owner.Name
+		} else if pp.Installer = d.Installer; pp.Installer == "" {
+			pp.Installer = "unknown"
+		}
+		env.PythonPackages = append(env.PythonPackages, pp)
+	}
+
+	for _, pp := range env.PythonPackages[start:] {
+		if pp.CondaPackage != "" || pp.Installer == "conda" {
+			continue
+		}
+		condaMetaDir, ok := condaDists[NormalizePythonName(pp.Name)]
+		if !ok {
+			continue
+		}
+		owner := owners[condaMetaDir]
+		kind := CondaOverlapShadowed
+		if present[condaMetaDir] {
+			kind = CondaOverlapDuplicate
+		}
+		env.Overlaps = append(env.Overlaps, CondaPipOverlap{
+			Name:         pp.Name,
+			Kind:         kind,
+			CondaPackage: owner.Name,
+			CondaVersion: owner.Version,
+			PipVersion:   pp.Version,
+			PipMetaDir:   pp.MetaDir,
+		})
+	}
+}
+
+// distNameFromMetaDir 从 name-version.dist-info 目录名中取出包名
+func distNameFromMetaDir(metaDir string) string {
+	base := filepath.Base(metaDir)
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".dist-info"), ".egg-info")
+	if i := strings.Index(base, "-"); i >= 0 {
+		return base[:i]
+	}
+	return base
+}
@@ -0,0 +1,439 @@
+package analyze
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CUDAInventory 是镜像中 CUDA 软件栈的清单
+type CUDAInventory struct {
+	Toolkits        []CUDAToolkit        `json:"toolkits"`
+	Libraries       []CUDALibrary        `json:"libraries"`
+	DriverLibraries []CUDADriverLibrary  `json:"driver_libraries"`
+	Fatbins         []CUDAFatbin         `json:"fatbins"`
+	Frameworks      []CUDAFrameworkBuild `json:"frameworks"`
+}
+
+// CUDAToolkit 是一个 CUDA toolkit 安装目录
+type CUDAToolkit struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	NVCC    bool   `json:"nvcc"`
+}
+
+// CUDALibrary 是 cudart、cuDNN、NCCL、TensorRT 等库的一个版本
+type CUDALibrary struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	// Source 为 filename（库文件名）或 header（版本头文件）
+	Source string `json:"source"`
+}
+
+// CUDADriverLibrary 是镜像中的 libcuda 等驱动库，
+// Compat 为 true 表示位于 cuda-compat 前向兼容包的目录中
+type CUDADriverLibrary struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Compat  bool   `json:"compat"`
+}
+
+// CUDAFatbin 是一个二进制中内嵌的 GPU 代码，SASS 为机器码的计算能力，PTX 为可 JIT 的中间代码
+type CUDAFatbin struct {
+	Path string   `json:"path"`
+	SASS []string `json:"sass"`
+	PTX  []string `json:"ptx"`
+}
+
+// CUDAFrameworkBuild 是深度学习框架构建时使用的 CUDA 版本与镜像中实际安装版本的比对结果
+type CUDAFrameworkBuild struct {
+	Framework    string `json:"framework"`
+	Version      string `json:"version"`
+	Path         string `json:"path"`
+	CUDAVersion  string `json:"cuda_version,omitempty"`
+	CuDNNVersion string `json:"cudnn_version,omitempty"`
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"`
+}
+
+const (
+	CUDABuildOK       = "ok"
+	CUDABuildWarning  = "warning"
+	CUDABuildMismatch = "mismatch"
+	CUDABuildMissing  = "missing"
+	CUDABuildCPU      = "cpu"
+)
+
+// cudaLibraryPatterns 从库文件名中提取版本，键为报告中的库名
+var cudaLibraryPatterns = map[string]*regexp.Regexp{
+	"cudart":   regexp.MustCompile(`^libcudart\.so\.(\d+(?:\.\d+)*)$`),
+	"cudnn":    regexp.MustCompile(`^libcudnn\.so\.(\d+(?:\.\d+)*)$`),
+	"nccl":     regexp.MustCompile(`^libnccl\.so\.(\d+(?:\.\d+)*)$`),
+	"tensorrt": regexp.MustCompile(`^libnvinfer\.so\.(\d+(?:\.\d+)*)$`),
+	"cublas":   regexp.MustCompile(`^libcublas\.so\.(\d+(?:\.\d+)*)$`),
+}
+
+var cudaDriverLibraryPattern = regexp.MustCompile(`^lib(?:cuda|nvidia-ptxjitcompiler|nvidia-ml)\.so\.(\d+(?:\.\d+)*)$`)
+
+// cudaVersionHeaders 是版本头文件及其中主、次、补丁版本的宏
+var cudaVersionHeaders = map[string]struct {
+	library string
+	macros  [3]string
+}{
+	"cudnn_version.h":  {"cudnn", [3]string{"CUDNN_MAJOR", "CUDNN_MINOR", "CUDNN_PATCHLEVEL"}},
+	"cudnn.h":          {"cudnn", [3]string{"CUDNN_MAJOR", "CUDNN_MINOR", "CUDNN_PATCHLEVEL"}},
+	"nccl.h":           {"nccl", [3]string{"NCCL_MAJOR", "NCCL_MINOR", "NCCL_PATCH"}},
+	"NvInferVersion.h": {"tensorrt", [3]string{"NV_TENSORRT_MAJOR", "NV_TENSORRT_MINOR", "NV_TENSORRT_PATCH"}},
+}
+
+var (
+	cudaVersionTxtPattern = regexp.MustCompile(`CUDA Version (\d+\.\d+(?:\.\d+)?)`)
+	cDefinePattern        = regexp.MustCompile(`(?m)^\s*#\s*define\s+(\w+)\s+(\d+)`)
+	torchCUDAPattern      = regexp.MustCompile(`(?m)^cuda(?:\s*:[^=\n]*)?\s*=\s*['"]([^'"]+)['"]`)
+	tfCUDAPattern         = regexp.MustCompile(`['"]cuda_version['"]\s*:\s*['"]([^'"]+)['"]`)
+	tfCuDNNPattern        = regexp.MustCompile(`['"]cudnn_version['"]\s*:\s*['"]([^'"]+)['"]`)
+	jaxPluginPattern      = regexp.MustCompile(`^jax[-_]cuda(\d+)[-_](?:plugin|pjrt)$`)
+	jaxlibLocalPattern    = regexp.MustCompile(`\+cuda(\d+)(?:\.cudnn(\d)(\d+))?`)
+)
+
+// fatbin 的魔数和条目类型
+const (
+	fatbinMagic     = 0xba55ed50
+	fatbinKindPTX   = 1
+	fatbinKindCubin = 2
+	// 小于 fatbinCandidateMinSize 的文件不值得打开检查
+	fatbinCandidateMinSize = 64 << 10
+)
+
+// ListCUDAStack 列出镜像中的 CUDA toolkit、cuDNN/NCCL/TensorRT 等库、驱动兼容库以及
+// 二进制中内嵌的计算能力，并检查 PyTorch、TensorFlow、JAX 构建时的 CUDA 版本与镜像是否一致
+func ListCUDAStack(root string, workers int) *CUDAInventory {
+	inv := &CUDAInventory{
+		Toolkits:        []CUDAToolkit{},
+		Libraries:       []CUDALibrary{},
+		DriverLibraries: []CUDADriverLibrary{},
+		Fatbins:         []CUDAFatbin{},
+		Frameworks:      []CUDAFrameworkBuild{},
+	}
+	var (
+		elfCandidates []string
+		sitePackages  []string
+	)
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if name == "site-packages" || name == "dist-packages" {
+				sitePackages = append(sitePackages, imagePath(root, path))
+			}
+			if tk := readCUDAToolkit(root, path); tk != nil {
+				inv.Toolkits = append(inv.Toolkits, *tk)
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		for lib, re := range cudaLibraryPatterns {
+			if m := re.FindStringSubmatch(name); m != nil {
+				inv.Libraries = append(inv.Libraries, CUDALibrary{Name: lib, Version: m[1], Path: imagePath(root, path), Source: "filename"})
+			}
+		}
+		if m := cudaDriverLibraryPattern.FindStringSubmatch(name); m != nil {
+			p := imagePath(root, path)
+			inv.DriverLibraries = append(inv.DriverLibraries, CUDADriverLibrary{Path: p, Version: m[1], Compat: strings.Contains(p, "/compat/")})
+		}
+		if h, ok := cudaVersionHeaders[name]; ok {
+			if v := readHeaderVersion(path, h.macros); v != "" {
+				inv.Libraries = append(inv.Libraries, CUDALibrary{Name: h.library, Version: v, Path: imagePath(root, path), Source: "header"})
+			}
+		}
+		if info.Size() >= fatbinCandidateMinSize && (strings.Contains(name, ".so") || filepath.Ext(name) == "") {
+			elfCandidates = append(elfCandidates, path)
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	runParallel(len(elfCandidates), workers, func(i int) {
+		fb := readFatbinArchs(elfCandidates[i])
+		if fb == nil {
+			return
+		}
+		fb.Path = imagePath(root, elfCandidates[i])
+		mu.Lock()
+		inv.Fatbins = append(inv.Fatbins, *fb)
+		mu.Unlock()
+	})
+
+	for _, sp := range sitePackages {
+		inv.Frameworks = append(inv.Frameworks, detectFrameworkBuilds(root, sp)...)
+	}
+	installed := inv.installedCUDAVersions()
+	for i := range inv.Frameworks {
+		checkFrameworkCUDA(&inv.Frameworks[i], installed)
+	}
+
+	sort.Slice(inv.Toolkits, func(i, j int) bool { return inv.Toolkits[i].Path < inv.Toolkits[j].Path })
+	sort.Slice(inv.Libraries, func(i, j int) bool {
+		if inv.Libraries[i].Name != inv.Libraries[j].Name {
+			return inv.Libraries[i].Name < inv.Libraries[j].Name
+		}
+		return inv.Libraries[i].Path < inv.Libraries[j].Path
+	})
+	sort.Slice(inv.DriverLibraries, func(i, j int) bool { return inv.DriverLibraries[i].Path < inv.DriverLibraries[j].Path })
+	sort.Slice(inv.Fatbins, func(i, j int) bool { return inv.Fatbins[i].Path < inv.Fatbins[j].Path })
+	return inv
+}
+
+// readCUDAToolkit 识别包含 version.json 或 version.txt 的 CUDA toolkit 目录
+func readCUDAToolkit(root, dir string) *CUDAToolkit {
+	base := filepath.Base(dir)
+	if base != "cuda" && !strings.HasPrefix(base, "cuda-") {
+		return nil
+	}
+	tk := &CUDAToolkit{Path: imagePath(root, dir)}
+	if _, err := os.Stat(rootPath(root, tk.Path, "bin", "nvcc")); err == nil {
+		tk.NVCC = true
+	}
+	if data, err := os.ReadFile(rootPath(root, tk.Path, "version.json")); err == nil {
+		var v struct {
+			CUDA struct {
+				Version string `json:"version"`
+			} `json:"cuda"`
+		}
+		if json.Unmarshal(data, &v) == nil && v.CUDA.Version != "" {
+			tk.Version, tk.Source = v.CUDA.Version, "version.json"
+			return tk
+		}
+	}
+	if data, err := os.ReadFile(rootPath(root, tk.Path, "version.txt")); err == nil {
+		if m := cudaVersionTxtPattern.FindSubmatch(data); m != nil {
+			tk.Version, tk.Source = string(m[1]), "version.txt"
+			return tk
+		}
+	}
+	return nil
+}
+
+func readHeaderVersion(path string, macros [3]string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	defs := make(map[string]string)
+	for _, m := range cDefinePattern.FindAllSubmatch(data, -1) {
+		defs[string(m[1])] = string(m[2])
+	}
+	if defs[macros[0]] == "" || defs[macros[1]] == "" {
+		return ""
+	}
+	v := defs[macros[0]] + "." + defs[macros[1]]
+	if defs[macros[2]] != "" {
+		v += "." + defs[macros[2]]
+	}
+	return v
+}
+
+// readFatbinArchs 解析 ELF 中 .nv_fatbin 段的各个条目头部，只读取头部而跳过其中的代码
+func readFatbinArchs(path string) *CUDAFatbin {
+	if !isELF(path) {
+		return nil
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	section := f.Section(".nv_fatbin")
+	if section == nil || section.Type == elf.SHT_NOBITS {
+		return nil
+	}
+
+	sass, ptx := make(map[string]bool), make(map[string]bool)
+	size := int64(section.Size)
+	header := make([]byte, 32)
+	for off := int64(0); off+16 <= size; {
+		if _, err := section.ReadAt(header[:16], off); err != nil {
+			break
+		}
+		if binary.LittleEndian.Uint32(header) != fatbinMagic {
+			// 各个 fatbin 之间按 8 字节对齐
+			off += 8
+			continue
+		}
+		headerSize := int64(binary.LittleEndian.Uint16(header[6:]))
+		fatSize := int64(binary.LittleEndian.Uint64(header[8:]))
+		entry, end := off+headerSize, off+headerSize+fatSize
+		for entry+32 <= end && entry+32 <= size {
+			if _, err := section.ReadAt(header, entry); err != nil {
+				break
+			}
+			kind := binary.LittleEndian.Uint16(header)
+			entryHeaderSize := int64(binary.LittleEndian.Uint32(header[4:]))
+			payloadSize := int64(binary.LittleEndian.Uint64(header[8:]))
+			arch := binary.LittleEndian.Uint32(header[28:])
+			// 长度来自文件内容，负数或溢出会让 entry 后退或原地不动
+			next := entry + entryHeaderSize + payloadSize
+			if entryHeaderSize == 0 || payloadSize < 0 || next <= entry {
+				break
+			}
+			switch kind {
+			case fatbinKindCubin:
+				sass["sm_"+strconv.Itoa(int(arch))] = true
+			case fatbinKindPTX:
+				ptx["compute_"+strconv.Itoa(int(arch))] = true
+			}
+			entry = next
+		}
+		if fatSize <= 0 || end <= off {
+			break
+		}
+		off = end
+	}
+	if len(sass) == 0 && len(ptx) == 0 {
+		return nil
+	}
+	return &CUDAFatbin{SASS: sortedCapabilities(sass), PTX: sortedCapabilities(ptx)}
+}
+
+// sortedCapabilities 按计算能力的数值排序，sm_90 排在 sm_100 之前
+func sortedCapabilities(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for k := range set {
+		list = append(list, k)
+	}
+	num := func(s string) int {
+		n, _ := strconv.Atoi(s[strings.IndexByte(s, '_')+1:])
+		return n
+	}
+	sort.Slice(list, func(i, j int) bool { return num(list[i]) < num(list[j]) })
+	return list
+}
+
+// detectFrameworkBuilds 读取 site-packages 中 PyTorch、TensorFlow、JAX 记录的构建时 CUDA 版本
+func detectFrameworkBuilds(root, sitePackages string) []CUDAFrameworkBuild {
+	dists := make(map[string]pythonDist)
+	for _, d := range listPythonDists(root, sitePackages) {
+		dists[NormalizePythonName(d.Name)] = d
+	}
+
+	var builds []CUDAFrameworkBuild
+	if data, err := os.ReadFile(rootPath(root, sitePackages, "torch", "version.py")); err == nil {
+		b := CUDAFrameworkBuild{Framework: "pytorch", Version: dists["torch"].Version, Path: sitePackages + "/torch"}
+		if m := torchCUDAPattern.FindSubmatch(data); m != nil {
+			b.CUDAVersion = string(m[1])
+		}
+		builds = append(builds, b)
+	}
+	if data, err := os.ReadFile(rootPath(root, sitePackages, "tensorflow", "python", "platform", "build_info.py")); err == nil {
+		b := CUDAFrameworkBuild{Framework: "tensorflow", Path: sitePackages + "/tensorflow"}
+		for _, name := range []string{"tensorflow", "tensorflow-gpu", "tensorflow-cpu", "tf-nightly"} {
+			if d, ok := dists[name]; ok {
+				b.Version = d.Version
+				break
+			}
+		}
+		if m := tfCUDAPattern.FindSubmatch(data); m != nil {
+			b.CUDAVersion = string(m[1])
+		}
+		if m := tfCuDNNPattern.FindSubmatch(data); m != nil {
+			b.CuDNNVersion = string(m[1])
+		}
+		builds = append(builds, b)
+	}
+	if jaxlib, ok := dists["jaxlib"]; ok {
+		b := CUDAFrameworkBuild{Framework: "jax", Version: jaxlib.Version, Path: sitePackages + "/jaxlib"}
+		// 新版本通过 jax-cuda12-plugin 提供 CUDA 支持，旧版本在 jaxlib 的本地版本号中记录
+		for name := range dists {
+			if m := jaxPluginPattern.FindStringSubmatch(name); m != nil {
+				b.CUDAVersion = m[1]
+			}
+		}
+		if m := jaxlibLocalPattern.FindStringSubmatch(jaxlib.Version); m != nil {
+			b.CUDAVersion = m[1]
+			if m[2] != "" {
+				b.CuDNNVersion = m[2] + "." + m[3]
+			}
+		}
+		builds = append(builds, b)
+	}
+	return builds
+}
+
+// installedCUDAVersions 返回镜像中安装的 CUDA 运行时版本，来自 toolkit 和 libcudart
+func (inv *CUDAInventory) installedCUDAVersions() []string {
+	var versions []string
+	for _, tk := range inv.Toolkits {
+		versions = append(versions, tk.Version)
+	}
+	for _, lib := range inv.Libraries {
+		if lib.Name == "cudart" {
+			versions = append(versions, lib.Version)
+		}
+	}
+	return versions
+}
+
+// checkFrameworkCUDA 比较框架构建时的 CUDA 版本与镜像中安装的版本。
+// 主版本不同无法加载；安装的次版本低于构建版本时可能缺少符号，给出警告
+func checkFrameworkCUDA(b *CUDAFrameworkBuild, installed []string) {
+	if b.CUDAVersion == "" {
+		b.Status = CUDABuildCPU
+		return
+	}
+	if len(installed) == 0 {
+		b.Status = CUDABuildMissing
+		b.Message = fmt.Sprintf("built against CUDA %s, but no CUDA runtime was found in the image", b.CUDAVersion)
+		return
+	}
+	builtMajor, builtMinor := splitMajorMinor(b.CUDAVersion)
+	var older []string
+	for _, v := range installed {
+		major, minor := splitMajorMinor(v)
+		if major != builtMajor {
+			continue
+		}
+		if minor < 0 || builtMinor < 0 || minor >= builtMinor {
+			b.Status = CUDABuildOK
+			return
+		}
+		older = append(older, v)
+	}
+	if len(older) > 0 {
+		b.Status = CUDABuildWarning
+		b.Message = fmt.Sprintf("built against CUDA %s, but the image only has %s", b.CUDAVersion, strings.Join(older, ", "))
+		return
+	}
+	b.Status = CUDABuildMismatch
+	b.Message = fmt.Sprintf("built against CUDA %s, but the image has %s", b.CUDAVersion, strings.Join(installed, ", "))
+}
+
+// splitMajorMinor 拆分版本号的主、次版本，缺少次版本时返回 -1
+func splitMajorMinor(v string) (int, int) {
+	parts := strings.SplitN(v, ".", 3)
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return -1, -1
+	}
+	if len(parts) < 2 {
+		return major, -1
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return major, -1
+	}
+	return major, minor
+}
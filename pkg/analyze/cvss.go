@@ -0,0 +1,99 @@
+package analyze
+
+import (
+	"math"
+	"strings"
+)
+
+// cvss3Weights 是 CVSS v3 基础指标各取值的权重
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore 根据 CVSS v3.0/v3.1 向量计算基础分，向量无效时返回 false
+func cvss3BaseScore(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, false
+	}
+	metrics := make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, ":"); ok {
+			metrics[k] = v
+		}
+	}
+	scope, ok := metrics["S"]
+	if !ok || (scope != "U" && scope != "C") {
+		return 0, false
+	}
+	w := make(map[string]float64)
+	for k, values := range cvss3Weights {
+		v, ok := values[metrics[k]]
+		if !ok {
+			return 0, false
+		}
+		w[k] = v
+	}
+	var pr float64
+	switch metrics["PR"] {
+	case "N":
+		pr = 0.85
+	case "L":
+		pr = 0.62
+		if scope == "C" {
+			pr = 0.68
+		}
+	case "H":
+		pr = 0.27
+		if scope == "C" {
+			pr = 0.5
+		}
+	default:
+		return 0, false
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	var impact float64
+	if scope == "U" {
+		impact = 6.42 * iss
+	} else {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * pr * w["UI"]
+	if scope == "U" {
+		return cvssRoundUp(math.Min(impact+exploitability, 10)), true
+	}
+	return cvssRoundUp(math.Min(1.08*(impact+exploitability), 10)), true
+}
+
+// cvssRoundUp 是 CVSS v3.1 规范中的 Roundup：向上取整到一位小数，避免浮点误差
+func cvssRoundUp(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
+
+// cvssSeverity 返回 CVSS 分数对应的严重程度
+func cvssSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityNone
+}
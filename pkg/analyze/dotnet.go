@@ -0,0 +1,55 @@
+package analyze
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// dotnetDepsAnalyzer 解析 .NET 应用发布目录中的 *.deps.json
+type dotnetDepsAnalyzer struct{}
+
+// dotnetDeps 是 deps.json 中用到的字段，libraries 的键为 "名称/版本"
+type dotnetDeps struct {
+	Libraries map[string]struct {
+		Type string `json:"type"`
+	} `json:"libraries"`
+}
+
+func (dotnetDepsAnalyzer) match(path string, info os.FileInfo) bool {
+	return strings.HasSuffix(info.Name(), ".deps.json")
+}
+
+func (dotnetDepsAnalyzer) analyze(root, path string) []Package {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var deps dotnetDeps
+	if err := json.Unmarshal(data, &deps); err != nil {
+		logger.Warn("解析 deps.json 失败", logger.WithString("file", imagePath(root, path)), logger.WithError(err))
+		return nil
+	}
+
+	location := imagePath(root, path)
+	var pkgs []Package
+	for key, lib := range deps.Libraries {
+		// project 为应用自身的项目，不是第三方组件
+		if lib.Type != "package" {
+			continue
+		}
+		name, version, ok := strings.Cut(key, "/")
+		if !ok {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Ecosystem: EcosystemNuGet,
+			Name:      name,
+			Version:   version,
+			Location:  location,
+		})
+	}
+	return pkgs
+}
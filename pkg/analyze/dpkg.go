@@ -0,0 +1,196 @@
+package analyze
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// dpkgPackage 是 dpkg status 数据库中的一个已安装包
+type dpkgPackage struct {
+	Name         string
+	Version      string
+	Architecture string
+	Source       string
+	// Conffiles 为配置文件路径到安装时 md5 的映射
+	Conffiles map[string]string
+	// md5sums 为该包 md5sums 文件在镜像内的路径
+	md5sums string
+}
+
+// dpkgInfoFile 返回包在 info 目录下某个控制文件的镜像内路径，带架构后缀的文件优先
+func dpkgInfoFile(root, dir string, pkg dpkgPackage, ext string) string {
+	candidates := []string{pkg.Name + "." + ext}
+	if pkg.Architecture != "" {
+		candidates = append([]string{pkg.Name + ":" + pkg.Architecture + "." + ext}, candidates...)
+	}
+	for _, name := range candidates {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(rootPath(root, p)); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// readDpkgPackages 读取 /var/lib/dpkg/status 以及 distroless 使用的 /var/lib/dpkg/status.d
+func readDpkgPackages(root string) []dpkgPackage {
+	var pkgs []dpkgPackage
+	for _, p := range parseDpkgStatus(rootPath(root, "var/lib/dpkg/status")) {
+		p.md5sums = dpkgInfoFile(root, "/var/lib/dpkg/info", p, "md5sums")
+		pkgs = append(pkgs, p)
+	}
+
+	entries, _ := os.ReadDir(rootPath(root, "var/lib/dpkg/status.d"))
+	for _, e := range entries {
+		if e.IsDir() || strings.Contains(e.Name(), ".") {
+			continue
+		}
+		for _, p := range parseDpkgStatus(rootPath(root, "var/lib/dpkg/status.d", e.Name())) {
+			p.md5sums = dpkgInfoFile(root, "/var/lib/dpkg/status.d", dpkgPackage{Name: e.Name()}, "md5sums")
+			pkgs = append(pkgs, p)
+		}
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs
+}
+
+// parseDpkgStatus 解析 dpkg status 格式的文件，只保留已安装的包
+func parseDpkgStatus(path string) []dpkgPackage {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var pkgs []dpkgPackage
+	fields := make(map[string]string)
+	var lastKey string
+	flush := func() {
+		status := fields["Status"]
+		// distroless 的 status.d 没有 Status 字段，视为已安装
+		if fields["Package"] != "" && (status == "" || strings.HasSuffix(status, " installed")) {
+			pkg := dpkgPackage{
+				Name:         fields["Package"],
+				Version:      fields["Version"],
+				Architecture: fields["Architecture"],
+				Source:       fields["Source"],
+				Conffiles:    make(map[string]string),
+			}
+			for _, line := range strings.Split(fields["Conffiles"], "\n") {
+				parts := strings.Fields(line)
+				if len(parts) >= 2 {
+					pkg.Conffiles[parts[0]] = parts[1]
+				}
+			}
+			pkgs = append(pkgs, pkg)
+		}
+		fields = make(map[string]string)
+		lastKey = ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && lastKey != "" {
+			fields[lastKey] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			lastKey = k
+			fields[k] = strings.TrimSpace(v)
+		}
+	}
+	flush()
+	return pkgs
+}
+
+// dpkgPathFilter 实现 /etc/dpkg/dpkg.cfg.d 中的 path-exclude/path-include 规则，
+// slim 镜像用它跳过文档等文件，这些文件会出现在 md5sums 中但并未安装
+type dpkgPathFilter struct {
+	rules []dpkgPathRule
+}
+
+type dpkgPathRule struct {
+	include bool
+	pattern *regexp.Regexp
+}
+
+func loadDpkgPathFilter(root string) *dpkgPathFilter {
+	filter := &dpkgPathFilter{}
+	files := []string{"/etc/dpkg/dpkg.cfg"}
+	more := globInRoot(root, "etc/dpkg/dpkg.cfg.d/*")
+	sort.Strings(more)
+	files = append(files, more...)
+	for _, file := range files {
+		data, err := os.ReadFile(rootPath(root, file))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			switch fields[0] {
+			case "path-exclude", "path-exclude=":
+				filter.rules = append(filter.rules, dpkgPathRule{include: false, pattern: fnmatchRegexp(fields[1])})
+			case "path-include", "path-include=":
+				filter.rules = append(filter.rules, dpkgPathRule{include: true, pattern: fnmatchRegexp(fields[1])})
+			}
+		}
+	}
+	return filter
+}
+
+// excluded 判断路径是否被排除安装，后出现的规则优先
+func (f *dpkgPathFilter) excluded(path string) bool {
+	excluded := false
+	for _, r := range f.rules {
+		if r.pattern.MatchString(path) {
+			excluded = !r.include
+		}
+	}
+	return excluded
+}
+
+// fnmatchRegexp 将不带 FNM_PATHNAME 的 fnmatch 模式转换为正则表达式，* 可以匹配 /
+func fnmatchRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				class := pattern[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end
+			} else {
+				b.WriteString(`\[`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+	return re
+}
@@ -0,0 +1,197 @@
+package analyze
+
+import (
+	"debug/elf"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ELFFile 是一个 ELF 可执行文件或共享库的链接和加固信息
+type ELFFile struct {
+	Path     string `json:"path"`
+	Arch     string `json:"arch"`
+	Class    string `json:"class"`
+	Type     string `json:"type"`
+	Static   bool   `json:"static"`
+	Stripped bool   `json:"stripped"`
+	// Interpreter 为 PT_INTERP 指定的动态链接器
+	Interpreter string       `json:"interpreter,omitempty"`
+	Needed      []ELFLibrary `json:"needed"`
+	RPath       []string     `json:"rpath,omitempty"`
+	RunPath     []string     `json:"runpath,omitempty"`
+	Hardening   ELFHardening `json:"hardening"`
+	// Unresolved 为在镜像中找不到的 NEEDED 库
+	Unresolved []string `json:"unresolved"`
+
+	class   elf.Class
+	machine elf.Machine
+}
+
+// ELFLibrary 是一个 NEEDED 条目及其解析结果，找不到时 Path 为空
+type ELFLibrary struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+}
+
+// ELFHardening 是常见的编译加固选项
+type ELFHardening struct {
+	PIE bool `json:"pie"`
+	// RELRO 为 none、partial 或 full
+	RELRO  string `json:"relro"`
+	NX     bool   `json:"nx"`
+	Canary bool   `json:"canary"`
+}
+
+const (
+	ELFTypeExecutable   = "executable"
+	ELFTypeSharedObject = "shared_object"
+)
+
+const (
+	RELRONone    = "none"
+	RELROPartial = "partial"
+	RELROFull    = "full"
+)
+
+// ListELFFiles 列出镜像中所有 ELF 可执行文件和共享库，并按动态链接器的规则解析其依赖的库
+func ListELFFiles(root string, env []string, workers int) []ELFFile {
+	var candidates []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || info.Size() < 64 {
+			return nil
+		}
+		if isELF(path) {
+			candidates = append(candidates, path)
+		}
+		return nil
+	})
+
+	resolver := newLibraryResolver(root, env)
+	var (
+		mu    sync.Mutex
+		files = []ELFFile{}
+	)
+	runParallel(len(candidates), workers, func(i int) {
+		file := readELFFile(root, candidates[i])
+		if file == nil {
+			return
+		}
+		for j := range file.Needed {
+			file.Needed[j].Path = resolver.resolve(file, file.Needed[j].Name)
+			if file.Needed[j].Path == "" {
+				file.Unresolved = append(file.Unresolved, file.Needed[j].Name)
+			}
+		}
+		mu.Lock()
+		files = append(files, *file)
+		mu.Unlock()
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// readELFFile 解析 ELF 头部、程序头和动态段，目标文件、core 文件等返回 nil
+func readELFFile(root, path string) *ELFFile {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN {
+		return nil
+	}
+
+	file := &ELFFile{
+		Path:       imagePath(root, path),
+		Arch:       strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_")),
+		Class:      strings.TrimPrefix(f.Class.String(), "ELFCLASS"),
+		Needed:     []ELFLibrary{},
+		Unresolved: []string{},
+		class:      f.Class,
+		machine:    f.Machine,
+		Hardening:  ELFHardening{RELRO: RELRONone},
+	}
+
+	var hasDynamic, hasRelro bool
+	for _, prog := range f.Progs {
+		switch prog.Type {
+		case elf.PT_INTERP:
+			data, err := io.ReadAll(io.LimitReader(prog.Open(), 4096))
+			if err == nil {
+				file.Interpreter = strings.TrimRight(string(data), "\x00")
+			}
+		case elf.PT_DYNAMIC:
+			hasDynamic = true
+		case elf.PT_GNU_RELRO:
+			hasRelro = true
+		case elf.PT_GNU_STACK:
+			file.Hardening.NX = prog.Flags&elf.PF_X == 0
+		}
+	}
+
+	var (
+		flags, flags1 uint64
+		soname        []string
+	)
+	if hasDynamic {
+		soname, _ = f.DynString(elf.DT_SONAME)
+		needed, _ := f.DynString(elf.DT_NEEDED)
+		for _, name := range needed {
+			file.Needed = append(file.Needed, ELFLibrary{Name: name})
+		}
+		if rpath, _ := f.DynString(elf.DT_RPATH); len(rpath) > 0 {
+			file.RPath = splitSearchPath(strings.Join(rpath, ":"))
+		}
+		if runpath, _ := f.DynString(elf.DT_RUNPATH); len(runpath) > 0 {
+			file.RunPath = splitSearchPath(strings.Join(runpath, ":"))
+		}
+		if v, _ := f.DynValue(elf.DT_FLAGS); len(v) > 0 {
+			flags = v[0]
+		}
+		if v, _ := f.DynValue(elf.DT_FLAGS_1); len(v) > 0 {
+			flags1 = v[0]
+		}
+	}
+
+	pieFlag := flags1&uint64(elf.DF_1_PIE) != 0
+	file.Type = ELFTypeSharedObject
+	// libc.so.6 等可以直接运行的共享库也带有解释器，以 SONAME 区分
+	if f.Type == elf.ET_EXEC || pieFlag || (file.Interpreter != "" && len(soname) == 0) {
+		file.Type = ELFTypeExecutable
+	}
+	file.Hardening.PIE = f.Type == elf.ET_DYN && file.Type == ELFTypeExecutable
+	// 没有动态段，或者没有解释器的 static-pie
+	file.Static = !hasDynamic || (file.Interpreter == "" && pieFlag)
+	if hasRelro {
+		file.Hardening.RELRO = RELROPartial
+		if flags&uint64(elf.DF_BIND_NOW) != 0 || flags1&uint64(elf.DF_1_NOW) != 0 {
+			file.Hardening.RELRO = RELROFull
+		}
+	}
+
+	file.Stripped = f.Section(".symtab") == nil
+	file.Hardening.Canary = hasELFSymbol(f, "__stack_chk_fail", "__stack_chk_guard", "__intel_security_cookie")
+	return file
+}
+
+func hasELFSymbol(f *elf.File, names ...string) bool {
+	for _, load := range []func() ([]elf.Symbol, error){f.DynamicSymbols, f.Symbols} {
+		syms, err := load()
+		if err != nil {
+			continue
+		}
+		for _, s := range syms {
+			for _, name := range names {
+				// 带版本的符号名形如 __stack_chk_fail@GLIBC_2.4
+				if s.Name == name || strings.HasPrefix(s.Name, name+"@") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
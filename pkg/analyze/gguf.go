@@ -0,0 +1,163 @@
+package analyze
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// GGUF 元数据的值类型
+const (
+	ggufTypeUint8 = iota
+	ggufTypeInt8
+	ggufTypeUint16
+	ggufTypeInt16
+	ggufTypeUint32
+	ggufTypeInt32
+	ggufTypeFloat32
+	ggufTypeBool
+	ggufTypeString
+	ggufTypeArray
+	ggufTypeUint64
+	ggufTypeInt64
+	ggufTypeFloat64
+)
+
+// ggufFileTypes 是 general.file_type 对应的量化类型（llama.cpp 的 llama_ftype）
+var ggufFileTypes = map[uint64]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16",
+}
+
+// maxGGUFString 是元数据中单个字符串的最大长度
+const maxGGUFString = 16 << 20
+
+// ggufReader 读取 GGUF 头部，v1 的长度字段是 32 位，之后的版本是 64 位
+type ggufReader struct {
+	r       *bufio.Reader
+	version uint32
+}
+
+// readGGUFHeader 读取 GGUF 的张量数量和元数据，提取架构、名称和量化类型
+func readGGUFHeader(f *os.File, artifact *ModelArtifact) error {
+	g := &ggufReader{r: bufio.NewReader(f)}
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(g.r, magic); err != nil {
+		return err
+	}
+	if string(magic) != "GGUF" {
+		return errors.New("不是 GGUF 文件")
+	}
+	if err := binary.Read(g.r, binary.LittleEndian, &g.version); err != nil {
+		return err
+	}
+	tensors, err := g.length()
+	if err != nil {
+		return err
+	}
+	kvCount, err := g.length()
+	if err != nil {
+		return err
+	}
+	artifact.TensorCount = int(tensors)
+	artifact.Metadata = map[string]string{"gguf_version": strconv.Itoa(int(g.version))}
+
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := g.string()
+		if err != nil {
+			return err
+		}
+		var typ uint32
+		if err := binary.Read(g.r, binary.LittleEndian, &typ); err != nil {
+			return err
+		}
+		value, err := g.value(typ)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "general.architecture":
+			artifact.Architecture = fmt.Sprint(value)
+		case "general.name", "general.quantization_version", "general.size_label":
+			artifact.Metadata[key] = fmt.Sprint(value)
+		case "general.file_type":
+			if n, ok := value.(uint64); ok {
+				artifact.Quantization = ggufFileTypes[n]
+				if artifact.Quantization == "" {
+					artifact.Quantization = "type " + strconv.FormatUint(n, 10)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *ggufReader) length() (uint64, error) {
+	if g.version == 1 {
+		var n uint32
+		err := binary.Read(g.r, binary.LittleEndian, &n)
+		return uint64(n), err
+	}
+	var n uint64
+	err := binary.Read(g.r, binary.LittleEndian, &n)
+	return n, err
+}
+
+func (g *ggufReader) string() (string, error) {
+	n, err := g.length()
+	if err != nil {
+		return "", err
+	}
+	if n > maxGGUFString {
+		return "", errors.New("GGUF 字符串过长")
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(g.r, buf)
+	return string(buf), err
+}
+
+// value 读取一个元数据值。整数统一转为 uint64 或 int64，数组只跳过而不返回内容
+func (g *ggufReader) value(typ uint32) (interface{}, error) {
+	switch typ {
+	case ggufTypeUint8, ggufTypeInt8, ggufTypeBool:
+		b, err := g.r.ReadByte()
+		return uint64(b), err
+	case ggufTypeUint16, ggufTypeInt16:
+		var v uint16
+		err := binary.Read(g.r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufTypeUint32, ggufTypeInt32, ggufTypeFloat32:
+		var v uint32
+		err := binary.Read(g.r, binary.LittleEndian, &v)
+		return uint64(v), err
+	case ggufTypeUint64, ggufTypeInt64, ggufTypeFloat64:
+		var v uint64
+		err := binary.Read(g.r, binary.LittleEndian, &v)
+		return v, err
+	case ggufTypeString:
+		return g.string()
+	case ggufTypeArray:
+		var elemType uint32
+		if err := binary.Read(g.r, binary.LittleEndian, &elemType); err != nil {
+			return nil, err
+		}
+		n, err := g.length()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < n; i++ {
+			if _, err := g.value(elemType); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("未知的 GGUF 值类型 %d", typ)
+}
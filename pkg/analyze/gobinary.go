@@ -0,0 +1,113 @@
+package analyze
+
+import (
+	"debug/buildinfo"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// GoBinary 是一个 Go 编译的可执行文件的构建信息
+type GoBinary struct {
+	Path       string           `json:"path"`
+	GoVersion  string           `json:"go_version"`
+	MainModule GoModule         `json:"main_module"`
+	Deps       []GoModule       `json:"deps"`
+	Settings   []GoBuildSetting `json:"settings"`
+	// 以下字段从 Settings 中提取，便于直接查看
+	CGOEnabled  string `json:"cgo_enabled,omitempty"`
+	GOOS        string `json:"goos,omitempty"`
+	GOARCH      string `json:"goarch,omitempty"`
+	VCSRevision string `json:"vcs_revision,omitempty"`
+	Trimpath    bool   `json:"trimpath"`
+}
+
+// GoModule 是构建信息中的一个模块
+type GoModule struct {
+	Path    string    `json:"path"`
+	Version string    `json:"version"`
+	Sum     string    `json:"sum,omitempty"`
+	Replace *GoModule `json:"replace,omitempty"`
+}
+
+// GoBuildSetting 是一个构建参数，例如 CGO_ENABLED=0
+type GoBuildSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ListGoBinaries 检查镜像中所有 ELF 可执行文件，提取 Go 二进制内嵌的构建信息
+func ListGoBinaries(root string, workers int) []GoBinary {
+	var candidates []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || info.Size() < 1024 {
+			return nil
+		}
+		if isELF(path) {
+			candidates = append(candidates, path)
+		}
+		return nil
+	})
+
+	var (
+		mu       sync.Mutex
+		binaries []GoBinary
+	)
+	runParallel(len(candidates), workers, func(i int) {
+		info, err := buildinfo.ReadFile(candidates[i])
+		if err != nil {
+			// 不是 Go 程序，或者是不带构建信息的旧版本 Go 程序
+			return
+		}
+		bin := newGoBinary(imagePath(root, candidates[i]), info)
+		mu.Lock()
+		binaries = append(binaries, bin)
+		mu.Unlock()
+	})
+	sort.Slice(binaries, func(i, j int) bool { return binaries[i].Path < binaries[j].Path })
+	return binaries
+}
+
+func newGoBinary(path string, info *buildinfo.BuildInfo) GoBinary {
+	bin := GoBinary{
+		Path:       path,
+		GoVersion:  info.GoVersion,
+		MainModule: convertGoModule(&info.Main),
+		Deps:       []GoModule{},
+		Settings:   []GoBuildSetting{},
+	}
+	// 通过 go build 构建但不在模块中的程序，主模块路径为空，此时使用包路径
+	if bin.MainModule.Path == "" {
+		bin.MainModule.Path = info.Path
+	}
+	for _, dep := range info.Deps {
+		bin.Deps = append(bin.Deps, convertGoModule(dep))
+	}
+	for _, s := range info.Settings {
+		bin.Settings = append(bin.Settings, GoBuildSetting{Key: s.Key, Value: s.Value})
+		switch s.Key {
+		case "CGO_ENABLED":
+			bin.CGOEnabled = s.Value
+		case "GOOS":
+			bin.GOOS = s.Value
+		case "GOARCH":
+			bin.GOARCH = s.Value
+		case "vcs.revision":
+			bin.VCSRevision = s.Value
+		case "-trimpath":
+			bin.Trimpath = s.Value == "true"
+		}
+	}
+	return bin
+}
+
+func convertGoModule(m *debug.Module) GoModule {
+	mod := GoModule{Path: m.Path, Version: m.Version, Sum: m.Sum}
+	if m.Replace != nil {
+		replace := convertGoModule(m.Replace)
+		mod.Replace = &replace
+	}
+	return mod
+}
@@ -0,0 +1,329 @@
+package analyze
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Finding 是一条安全配置问题
+type Finding struct {
+	ID          string   `json:"id"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description"`
+	Remediation string   `json:"remediation"`
+	Files       []string `json:"files,omitempty"`
+}
+
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+	SeverityNone     = "none"
+	SeverityUnknown  = "unknown"
+)
+
+// maxFindingFiles 是一条发现中列出的最大文件数量，超出的部分只计入描述中的总数
+const maxFindingFiles = 100
+
+// packageCacheDirs 是包管理器的缓存目录，构建结束时应当清理
+var packageCacheDirs = []struct {
+	path, manager string
+}{
+	{"/var/cache/apt/archives", "apt"},
+	{"/var/lib/apt/lists", "apt"},
+	{"/var/cache/yum", "yum"},
+	{"/var/cache/dnf", "dnf"},
+	{"/var/cache/zypp", "zypper"},
+	{"/var/cache/apk", "apk"},
+	{"/root/.cache/pip", "pip"},
+	{"/root/.npm/_cacache", "npm"},
+	{"/usr/local/share/.cache/yarn", "yarn"},
+	{"/root/.cache/yarn", "yarn"},
+}
+
+// CheckHardening 检查镜像配置和文件系统中常见的安全配置问题
+func CheckHardening(root string, imgCfg *v1.Image, extract *utils.ExtractInfo) []Finding {
+	findings := []Finding{}
+	add := func(f *Finding) {
+		if f != nil {
+			findings = append(findings, *f)
+		}
+	}
+	add(checkRootUser(root, imgCfg.Config.User))
+	add(checkHealthcheck(imgCfg, extract))
+	add(checkSSHD(root, imgCfg.Config.Env))
+	add(checkSudoNopasswd(root, imgCfg.Config.Env))
+	add(checkEmptyPasswords(root))
+	for _, f := range checkFileModes(root, extract) {
+		add(f)
+	}
+	add(checkPackageCaches(root))
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank(findings[i].Severity) < severityRank(findings[j].Severity)
+	})
+	return findings
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 0
+	case SeverityHigh:
+		return 1
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 3
+	}
+	return 4
+}
+
+// checkRootUser 检查容器是否以 root 运行：User 为空、UID 为 0，或用户名在 /etc/passwd 中的 UID 为 0
+func checkRootUser(root, user string) *Finding {
+	name, _, _ := strings.Cut(user, ":")
+	isRoot := false
+	switch {
+	case name == "" || name == "root":
+		isRoot = true
+	default:
+		if uid, err := strconv.Atoi(name); err == nil {
+			isRoot = uid == 0
+			break
+		}
+		for _, e := range readPasswd(root) {
+			if e.Name == name {
+				isRoot = e.UID == 0
+				break
+			}
+		}
+	}
+	if !isRoot {
+		return nil
+	}
+	desc := "镜像没有设置 USER，容器默认以 root 运行"
+	if user != "" {
+		desc = fmt.Sprintf("镜像的 USER 为 %s，容器以 root 运行", user)
+	}
+	return &Finding{
+		ID:          "root-user",
+		Severity:    SeverityMedium,
+		Description: desc,
+		Remediation: "创建非特权用户并在 Dockerfile 末尾使用 USER 切换到该用户",
+	}
+}
+
+// checkHealthcheck 检查是否设置了 HEALTHCHECK。OCI 配置中没有该字段，
+// 优先使用解压时从 Docker 格式配置中读取的值，否则根据构建历史判断
+func checkHealthcheck(imgCfg *v1.Image, extract *utils.ExtractInfo) *Finding {
+	if extract != nil && len(extract.Healthcheck) > 0 && extract.Healthcheck[0] != "NONE" {
+		return nil
+	}
+	for _, h := range imgCfg.History {
+		if i := strings.Index(h.CreatedBy, "HEALTHCHECK"); i >= 0 && !strings.HasPrefix(strings.TrimSpace(h.CreatedBy[i+len("HEALTHCHECK"):]), "NONE") {
+			return nil
+		}
+	}
+	return &Finding{
+		ID:          "no-healthcheck",
+		Severity:    SeverityLow,
+		Description: "镜像没有设置 HEALTHCHECK，编排系统无法感知应用是否正常",
+		Remediation: "在 Dockerfile 中添加 HEALTHCHECK 指令，或在编排系统中配置存活探针",
+	}
+}
+
+// installedCommands 返回按 PATH 或固定路径找到的命令文件，同一个文件只返回一次
+func installedCommands(root string, env []string, names []string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, res := range ResolveCommands(root, env, names) {
+		if res.Found && !seen[res.Resolved] {
+			seen[res.Resolved] = true
+			paths = append(paths, res.Path)
+		}
+	}
+	return paths
+}
+
+func checkSSHD(root string, env []string) *Finding {
+	paths := installedCommands(root, env, []string{"sshd", "/usr/sbin/sshd", "/usr/local/sbin/sshd"})
+	if len(paths) == 0 {
+		return nil
+	}
+	return &Finding{
+		ID:          "sshd-present",
+		Severity:    SeverityMedium,
+		Description: "镜像中安装了 SSH 服务端，容器通常不需要 SSH，它会增加攻击面和密钥管理负担",
+		Remediation: "移除 openssh-server，需要调试时使用 docker exec 或 kubectl exec",
+		Files:       paths,
+	}
+}
+
+// checkSudoNopasswd 检查安装了 sudo 时 sudoers 中是否有 NOPASSWD 规则
+func checkSudoNopasswd(root string, env []string) *Finding {
+	if len(installedCommands(root, env, []string{"sudo", "/usr/bin/sudo"})) == 0 {
+		return nil
+	}
+	files := []string{"/etc/sudoers"}
+	if entries, err := os.ReadDir(rootPath(root, "etc/sudoers.d")); err == nil {
+		for _, e := range entries {
+			files = append(files, "/etc/sudoers.d/"+e.Name())
+		}
+	}
+	var refs []string
+	for _, path := range files {
+		f, err := os.Open(rootPath(root, path))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "#") && strings.Contains(line, "NOPASSWD:") {
+				refs = append(refs, fmt.Sprintf("%s:%d", path, n))
+			}
+		}
+		f.Close()
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	return &Finding{
+		ID:          "sudo-nopasswd",
+		Severity:    SeverityHigh,
+		Description: "镜像中安装了 sudo，并且 sudoers 允许不输入密码提权",
+		Remediation: "移除 sudo 或 NOPASSWD 规则，需要 root 权限的步骤应在构建阶段完成",
+		Files:       refs,
+	}
+}
+
+// checkEmptyPasswords 检查 /etc/shadow 中密码字段为空、无需密码即可登录的账户
+func checkEmptyPasswords(root string) *Finding {
+	var users []string
+	for name, password := range readShadow(root) {
+		if password == "" {
+			users = append(users, name)
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	sort.Strings(users)
+	return &Finding{
+		ID:          "shadow-empty-password",
+		Severity:    SeverityHigh,
+		Description: fmt.Sprintf("/etc/shadow 中以下账户的密码为空，可以无密码登录：%s", strings.Join(users, ", ")),
+		Remediation: "使用 passwd -l 锁定这些账户，或将密码字段设置为 ! 或 *",
+		Files:       []string{"/etc/shadow"},
+	}
+}
+
+// checkFileModes 查找 setuid/setgid 文件，以及没有设置 sticky 位的全局可写文件和目录。
+// 解压时这些权限位不会写到文件系统上，按 tar 条目中记录的模式判断
+func checkFileModes(root string, extract *utils.ExtractInfo) []*Finding {
+	if extract == nil {
+		return nil
+	}
+	var setuid, writable []string
+	for path, mode := range extract.Modes {
+		// 后面的层可能通过父目录的替换删除了该路径
+		info, err := os.Lstat(rootPath(root, path))
+		if err != nil || info.IsDir() != mode.IsDir() {
+			continue
+		}
+		switch {
+		case mode.IsRegular():
+			if mode&(os.ModeSetuid|os.ModeSetgid) != 0 {
+				setuid = append(setuid, path)
+			}
+			if mode&0002 != 0 {
+				writable = append(writable, path)
+			}
+		case mode.IsDir():
+			if mode&0002 != 0 && mode&os.ModeSticky == 0 {
+				writable = append(writable, path)
+			}
+		}
+	}
+	sort.Strings(setuid)
+	sort.Strings(writable)
+
+	var findings []*Finding
+	if len(setuid) > 0 {
+		findings = append(findings, &Finding{
+			ID:          "setuid-binaries",
+			Severity:    SeverityMedium,
+			Description: fmt.Sprintf("镜像中有 %d 个设置了 setuid 或 setgid 位的文件，可能被用于提权", len(setuid)),
+			Remediation: "移除不需要的 setuid/setgid 位（chmod u-s,g-s），并以 --security-opt no-new-privileges 运行容器",
+			Files:       truncateFiles(setuid),
+		})
+	}
+	if len(writable) > 0 {
+		findings = append(findings, &Finding{
+			ID:          "world-writable",
+			Severity:    SeverityMedium,
+			Description: fmt.Sprintf("镜像中有 %d 个全局可写的文件或目录（不含设置了 sticky 位的目录）", len(writable)),
+			Remediation: "移除其他用户的写权限（chmod o-w），需要共享的目录应设置 sticky 位",
+			Files:       truncateFiles(writable),
+		})
+	}
+	return findings
+}
+
+// checkPackageCaches 检查构建结束时没有清理的包管理器缓存
+func checkPackageCaches(root string) *Finding {
+	var (
+		dirs     []string
+		managers []string
+		total    int64
+	)
+	for _, c := range packageCacheDirs {
+		dir := rootPath(root, c.path)
+		if dir == "" {
+			continue
+		}
+		var size int64
+		_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() && info.Name() != "lock" {
+				size += info.Size()
+			}
+			return nil
+		})
+		if size == 0 {
+			continue
+		}
+		dirs = append(dirs, c.path)
+		if !containsString(managers, c.manager) {
+			managers = append(managers, c.manager)
+		}
+		total += size
+	}
+	if len(dirs) == 0 {
+		return nil
+	}
+	return &Finding{
+		ID:          "package-cache",
+		Severity:    SeverityLow,
+		Description: fmt.Sprintf("镜像中残留了 %s 的缓存，共 %s", strings.Join(managers, "、"), utils.FormatBytes(total)),
+		Remediation: "在安装软件包的同一条 RUN 指令中清理缓存，例如 rm -rf /var/lib/apt/lists/*、pip install --no-cache-dir、npm cache clean --force",
+		Files:       dirs,
+	}
+}
+
+func truncateFiles(files []string) []string {
+	sort.Strings(files)
+	if len(files) > maxFindingFiles {
+		return files[:maxFindingFiles]
+	}
+	return files
+}
@@ -0,0 +1,312 @@
+package analyze
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// JavaInventory 是镜像中 Java 运行时和归档包的清单
+type JavaInventory struct {
+	Runtimes []JavaRuntime `json:"runtimes"`
+	Archives []JavaArchive `json:"archives"`
+}
+
+// JavaRuntime 是一个 JDK 或 JRE 安装目录
+type JavaRuntime struct {
+	Home           string `json:"home"`
+	Type           string `json:"type"`
+	Version        string `json:"version"`
+	RuntimeVersion string `json:"runtime_version,omitempty"`
+	Implementor    string `json:"implementor,omitempty"`
+}
+
+// JavaArchive 是一个 jar/war/ear 文件，嵌套在其他归档中的也单独列出
+type JavaArchive struct {
+	// Path 为最外层归档在镜像内的路径
+	Path string `json:"path"`
+	// NestingPath 为归档的完整位置，嵌套的各层以 "!/" 分隔，
+	// 例如 /app/app.jar!/BOOT-INF/lib/foo.jar
+	NestingPath string `json:"nesting_path"`
+	// Manifest 为 MANIFEST.MF 中与版本相关的主属性
+	Manifest  map[string]string `json:"manifest,omitempty"`
+	Artifacts []JavaArtifact    `json:"artifacts"`
+}
+
+// JavaArtifact 是一个 Maven 坐标
+type JavaArtifact struct {
+	GroupID    string `json:"group_id,omitempty"`
+	ArtifactID string `json:"artifact_id"`
+	Version    string `json:"version"`
+	// Source 为坐标的来源：pom.properties、manifest 或 filename
+	Source string `json:"source"`
+}
+
+const (
+	JavaRuntimeJDK = "JDK"
+	JavaRuntimeJRE = "JRE"
+)
+
+const (
+	// maxJavaNesting 是展开嵌套归档的最大层数
+	maxJavaNesting = 3
+	// maxNestedArchiveSize 是读入内存的嵌套归档的最大大小
+	maxNestedArchiveSize = 256 << 20
+)
+
+// javaManifestKeys 是 MANIFEST.MF 中需要保留的属性
+var javaManifestKeys = []string{
+	"Implementation-Title", "Implementation-Version", "Implementation-Vendor",
+	"Bundle-SymbolicName", "Bundle-Version", "Automatic-Module-Name",
+	"Main-Class", "Start-Class", "Spring-Boot-Version", "Created-By", "Build-Jdk-Spec",
+}
+
+// javaFilenamePattern 从 name-1.2.3.jar 形式的文件名中拆出名称和版本
+var javaFilenamePattern = regexp.MustCompile(`^(.+?)-(\d[\w.\-+]*)$`)
+
+// ListJavaPackages 列出镜像中的 JDK/JRE 和 Java 归档，
+// Spring Boot fat-jar、war 和 ear 中嵌套的 jar 会逐层展开
+func ListJavaPackages(root string, workers int) *JavaInventory {
+	inv := &JavaInventory{
+		Runtimes: []JavaRuntime{},
+		Archives: []JavaArchive{},
+	}
+	var archives []string
+	_ = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		switch {
+		case isJavaArchiveName(info.Name()):
+			archives = append(archives, p)
+		case info.Name() == "release":
+			if rt := readJavaRelease(root, filepath.Dir(p)); rt != nil {
+				inv.Runtimes = append(inv.Runtimes, *rt)
+			}
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	runParallel(len(archives), workers, func(i int) {
+		f, err := zip.OpenReader(archives[i])
+		if err != nil {
+			logger.Debug("打开 Java 归档失败", logger.WithString("path", archives[i]), logger.WithError(err))
+			return
+		}
+		defer f.Close()
+		outer := imagePath(root, archives[i])
+		found := walkJavaArchive(&f.Reader, outer, outer, 0)
+		mu.Lock()
+		inv.Archives = append(inv.Archives, found...)
+		mu.Unlock()
+	})
+
+	sort.Slice(inv.Runtimes, func(i, j int) bool { return inv.Runtimes[i].Home < inv.Runtimes[j].Home })
+	sort.Slice(inv.Archives, func(i, j int) bool { return inv.Archives[i].NestingPath < inv.Archives[j].NestingPath })
+	return inv
+}
+
+func isJavaArchiveName(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jar", ".war", ".ear":
+		return true
+	}
+	return false
+}
+
+// readJavaRelease 解析 JDK/JRE 根目录下的 release 文件，目录中没有 bin/java 时返回 nil
+func readJavaRelease(root, home string) *JavaRuntime {
+	home = imagePath(root, home)
+	if fi, err := os.Stat(rootPath(root, home, "bin/java")); err != nil || fi.IsDir() {
+		return nil
+	}
+	data, err := os.ReadFile(rootPath(root, home, "release"))
+	if err != nil {
+		return nil
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			fields[k] = strings.Trim(strings.TrimSpace(v), `"`)
+		}
+	}
+	if fields["JAVA_VERSION"] == "" {
+		return nil
+	}
+	rt := &JavaRuntime{
+		Home:           home,
+		Type:           JavaRuntimeJRE,
+		Version:        fields["JAVA_VERSION"],
+		RuntimeVersion: fields["JAVA_RUNTIME_VERSION"],
+		Implementor:    fields["IMPLEMENTOR"],
+	}
+	if _, err := os.Stat(rootPath(root, home, "bin/javac")); err == nil {
+		rt.Type = JavaRuntimeJDK
+	}
+	return rt
+}
+
+// walkJavaArchive 读取一个归档的元数据，并递归处理其中嵌套的归档
+func walkJavaArchive(r *zip.Reader, outer, location string, depth int) []JavaArchive {
+	archive := JavaArchive{
+		Path:        outer,
+		NestingPath: location,
+		Artifacts:   []JavaArtifact{},
+	}
+	var nested []*zip.File
+	for _, f := range r.File {
+		switch {
+		case f.Name == "META-INF/MANIFEST.MF":
+			archive.Manifest = readJavaManifest(f)
+		case strings.HasPrefix(f.Name, "META-INF/maven/") && path.Base(f.Name) == "pom.properties":
+			if a := readPomProperties(f); a != nil {
+				archive.Artifacts = append(archive.Artifacts, *a)
+			}
+		case isJavaArchiveName(f.Name) && !f.FileInfo().IsDir():
+			nested = append(nested, f)
+		}
+	}
+
+	// 没有 pom.properties 时退回到 manifest 和文件名
+	if len(archive.Artifacts) == 0 {
+		if a := javaArtifactFromManifest(archive.Manifest); a != nil {
+			archive.Artifacts = append(archive.Artifacts, *a)
+		} else if a := javaArtifactFromFilename(location); a != nil {
+			archive.Artifacts = append(archive.Artifacts, *a)
+		}
+	}
+	sort.Slice(archive.Artifacts, func(i, j int) bool {
+		a, b := archive.Artifacts[i], archive.Artifacts[j]
+		if a.GroupID != b.GroupID {
+			return a.GroupID < b.GroupID
+		}
+		return a.ArtifactID < b.ArtifactID
+	})
+	result := []JavaArchive{archive}
+
+	if depth >= maxJavaNesting {
+		return result
+	}
+	for _, f := range nested {
+		if f.UncompressedSize64 > maxNestedArchiveSize {
+			logger.Warn("嵌套的 Java 归档过大，跳过", logger.WithString("path", location+"!/"+f.Name))
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			continue
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			continue
+		}
+		result = append(result, walkJavaArchive(zr, outer, location+"!/"+f.Name, depth+1)...)
+	}
+	return result
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxNestedArchiveSize))
+}
+
+// readJavaManifest 解析 MANIFEST.MF 的主属性段，处理以空格开头的续行
+func readJavaManifest(f *zip.File) map[string]string {
+	data, err := readZipFile(f)
+	if err != nil {
+		return nil
+	}
+	attrs := make(map[string]string)
+	var lastKey string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			// 空行之后是各个条目的属性段
+			break
+		}
+		if line[0] == ' ' && lastKey != "" {
+			attrs[lastKey] += line[1:]
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			lastKey = k
+			attrs[k] = strings.TrimSpace(v)
+		}
+	}
+
+	manifest := make(map[string]string)
+	for _, k := range javaManifestKeys {
+		if v := attrs[k]; v != "" {
+			manifest[k] = v
+		}
+	}
+	if len(manifest) == 0 {
+		return nil
+	}
+	return manifest
+}
+
+func readPomProperties(f *zip.File) *JavaArtifact {
+	data, err := readZipFile(f)
+	if err != nil {
+		return nil
+	}
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			props[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if props["artifactId"] == "" || props["version"] == "" {
+		return nil
+	}
+	return &JavaArtifact{
+		GroupID:    props["groupId"],
+		ArtifactID: props["artifactId"],
+		Version:    props["version"],
+		Source:     "pom.properties",
+	}
+}
+
+func javaArtifactFromManifest(manifest map[string]string) *JavaArtifact {
+	name, version := manifest["Implementation-Title"], manifest["Implementation-Version"]
+	if name == "" || version == "" {
+		// OSGi bundle 的属性也能标识组件
+		name, version = manifest["Bundle-SymbolicName"], manifest["Bundle-Version"]
+		// Bundle-SymbolicName 可能带有 ;singleton:=true 之类的指令
+		name, _, _ = strings.Cut(name, ";")
+	}
+	if name == "" || version == "" {
+		return nil
+	}
+	return &JavaArtifact{ArtifactID: strings.TrimSpace(name), Version: version, Source: "manifest"}
+}
+
+func javaArtifactFromFilename(location string) *JavaArtifact {
+	base := path.Base(location[strings.LastIndex(location, "!/")+1:])
+	m := javaFilenamePattern.FindStringSubmatch(strings.TrimSuffix(base, path.Ext(base)))
+	if m == nil {
+		return nil
+	}
+	return &JavaArtifact{ArtifactID: m[1], Version: m[2], Source: "filename"}
+}
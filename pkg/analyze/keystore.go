@@ -0,0 +1,259 @@
+package analyze
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+const (
+	jksMagic   = 0xFEEDFEED
+	jceksMagic = 0xCECECECE
+
+	jksPrivateKeyEntry  = 1
+	jksTrustedCertEntry = 2
+	jksSecretKeyEntry   = 3
+)
+
+// errNotPKCS12 表示文件不是 PKCS#12 格式
+var errNotPKCS12 = errors.New("不是 PKCS#12 文件")
+
+// keystoreEntry 是密钥库中的一个条目：可信证书，或私钥及其证书链
+type keystoreEntry struct {
+	alias string
+	certs []*x509.Certificate
+	// privateKey 表示条目中包含私钥；key 为未加密时解析出的私钥，加密的私钥为 nil
+	privateKey bool
+	key        any
+}
+
+// parseJKS 解析 Java 的 JKS/JCEKS 密钥库。证书不加密，不需要口令即可读取；
+// 私钥条目只记录其证书链，末尾基于口令的完整性校验被忽略
+func parseJKS(data []byte) ([]keystoreEntry, error) {
+	r := bytes.NewReader(data)
+	var header struct{ Magic, Version, Count uint32 }
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != jksMagic && header.Magic != jceksMagic {
+		return nil, errors.New("不是 JKS 密钥库")
+	}
+	if header.Version != 1 && header.Version != 2 {
+		return nil, fmt.Errorf("不支持的 JKS 版本 %d", header.Version)
+	}
+
+	readUint32 := func() (uint32, error) {
+		var v uint32
+		err := binary.Read(r, binary.BigEndian, &v)
+		return v, err
+	}
+	readUTF := func() (string, error) {
+		var n uint16
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return "", err
+		}
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return string(buf), err
+	}
+	readBytes := func() ([]byte, error) {
+		n, err := readUint32()
+		if err != nil {
+			return nil, err
+		}
+		if int64(n) > int64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		buf := make([]byte, n)
+		_, err = io.ReadFull(r, buf)
+		return buf, err
+	}
+	readCert := func() (*x509.Certificate, error) {
+		if header.Version == 2 {
+			if typ, err := readUTF(); err != nil {
+				return nil, err
+			} else if typ != "X.509" {
+				return nil, fmt.Errorf("不支持的证书类型 %s", typ)
+			}
+		}
+		der, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return x509.ParseCertificate(der)
+	}
+
+	var entries []keystoreEntry
+	for i := uint32(0); i < header.Count; i++ {
+		tag, err := readUint32()
+		if err != nil {
+			return entries, err
+		}
+		alias, err := readUTF()
+		if err != nil {
+			return entries, err
+		}
+		if _, err := r.Seek(8, io.SeekCurrent); err != nil { // 创建时间
+			return entries, err
+		}
+		entry := keystoreEntry{alias: alias}
+		switch tag {
+		case jksTrustedCertEntry:
+			cert, err := readCert()
+			if err != nil {
+				return entries, fmt.Errorf("条目 %s: %w", alias, err)
+			}
+			entry.certs = []*x509.Certificate{cert}
+		case jksPrivateKeyEntry:
+			if _, err := readBytes(); err != nil {
+				return entries, err
+			}
+			n, err := readUint32()
+			if err != nil {
+				return entries, err
+			}
+			entry.privateKey = true
+			for j := uint32(0); j < n; j++ {
+				cert, err := readCert()
+				if err != nil {
+					return entries, fmt.Errorf("条目 %s: %w", alias, err)
+				}
+				entry.certs = append(entry.certs, cert)
+			}
+		case jksSecretKeyEntry:
+			// JCEKS 的对称密钥是 Java 序列化对象，无法确定长度，后续条目不再读取
+			return entries, fmt.Errorf("条目 %s 是对称密钥，后续条目未读取", alias)
+		default:
+			return entries, fmt.Errorf("未知的条目类型 %d", tag)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+var (
+	oidPKCS7Data          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7EncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+)
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  asn1.RawValue `asn1:"optional"`
+}
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type pkcs12CertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// parsePKCS12 读取 PKCS#12 文件中未加密的证书和私钥，例如 JDK 18 起无口令的 cacerts。
+// 加密的内容需要口令，只返回错误说明；加密的私钥记为私钥条目
+func parsePKCS12(data []byte) ([]keystoreEntry, error) {
+	var pfx pkcs12PFX
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil || len(rest) > 0 || pfx.Version != 3 || !pfx.AuthSafe.ContentType.Equal(oidPKCS7Data) {
+		return nil, errNotPKCS12
+	}
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, err
+	}
+	var contents []pkcs12ContentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, err
+	}
+
+	var (
+		entries   []keystoreEntry
+		encrypted int
+	)
+	for _, ci := range contents {
+		if ci.ContentType.Equal(oidPKCS7EncryptedData) {
+			encrypted++
+			continue
+		}
+		if !ci.ContentType.Equal(oidPKCS7Data) {
+			continue
+		}
+		var safe []byte
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &safe); err != nil {
+			return entries, err
+		}
+		var bags []pkcs12SafeBag
+		if _, err := asn1.Unmarshal(safe, &bags); err != nil {
+			return entries, err
+		}
+		for _, bag := range bags {
+			entry := keystoreEntry{alias: pkcs12FriendlyName(bag.Attributes)}
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb pkcs12CertBag
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil || !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return entries, err
+				}
+				entry.certs = []*x509.Certificate{cert}
+			case bag.ID.Equal(oidKeyBag):
+				entry.privateKey = true
+				entry.key, _ = x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+			case bag.ID.Equal(oidShroudedKeyBag):
+				entry.privateKey = true
+			default:
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if encrypted > 0 {
+		return entries, fmt.Errorf("%d 段内容已加密，需要口令才能读取", encrypted)
+	}
+	return entries, nil
+}
+
+// pkcs12FriendlyName 返回 friendlyName 属性（BMPString），Java 用它保存条目别名
+func pkcs12FriendlyName(attrs []pkcs12Attribute) string {
+	for _, a := range attrs {
+		if !a.ID.Equal(oidFriendlyName) {
+			continue
+		}
+		var v asn1.RawValue
+		if _, err := asn1.Unmarshal(a.Value.Bytes, &v); err != nil || v.Tag != 30 || len(v.Bytes)%2 != 0 {
+			return ""
+		}
+		u := make([]uint16, len(v.Bytes)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(v.Bytes[2*i:])
+		}
+		return string(utf16.Decode(u))
+	}
+	return ""
+}
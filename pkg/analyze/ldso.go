@@ -0,0 +1,274 @@
+package analyze
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// ldsoCacheMagic 是 glibc 2.x 新格式 ld.so.cache 的魔数
+const ldsoCacheMagic = "glibc-ld.so.cache1.1"
+
+const (
+	ldsoCacheHeaderLen = 48
+	ldsoCacheEntryLen  = 24
+)
+
+// libraryResolver 按照动态链接器的规则在镜像中查找共享库
+type libraryResolver struct {
+	root string
+	// ldLibraryPath 为镜像配置中 LD_LIBRARY_PATH 的目录
+	ldLibraryPath []string
+	// confDirs 为 ld.so.conf 中配置的目录，cache 为 ld.so.cache 中 soname 到路径的映射
+	confDirs []string
+	cache    map[string][]string
+	// muslDirs 为 musl 动态链接器的搜索路径，为空时使用 musl 的默认值
+	muslDirs map[string][]string
+
+	mu sync.Mutex
+	// candidates 缓存已检查过的库文件的 class 和 machine
+	candidates map[string]*elfIdent
+}
+
+// elfIdent 是判断库能否被加载时需要比较的 ELF 属性
+type elfIdent struct {
+	class   elf.Class
+	machine elf.Machine
+}
+
+func newLibraryResolver(root string, env []string) *libraryResolver {
+	r := &libraryResolver{
+		root:       root,
+		cache:      readLdsoCache(root),
+		muslDirs:   make(map[string][]string),
+		candidates: make(map[string]*elfIdent),
+	}
+	if v, ok := envValue(env, "LD_LIBRARY_PATH"); ok {
+		r.ldLibraryPath = splitSearchPath(v)
+	}
+	r.confDirs = readLdsoConf(root, "/etc/ld.so.conf", 0)
+
+	// musl 从 /etc/ld-musl-$ARCH.path 读取搜索路径
+	for _, p := range globInRoot(root, "etc/ld-musl-*.path") {
+		data, err := os.ReadFile(rootPath(root, p))
+		if err != nil {
+			continue
+		}
+		arch := strings.TrimSuffix(strings.TrimPrefix(path.Base(p), "ld-musl-"), ".path")
+		r.muslDirs[arch] = strings.FieldsFunc(string(data), func(c rune) bool {
+			return c == ':' || c == '\n' || c == ' ' || c == '\t'
+		})
+	}
+	return r
+}
+
+// splitSearchPath 拆分以 : 或 ; 分隔的搜索路径
+func splitSearchPath(s string) []string {
+	return strings.FieldsFunc(s, func(c rune) bool { return c == ':' || c == ';' })
+}
+
+// readLdsoConf 解析 ld.so.conf，展开其中的 include
+func readLdsoConf(root, file string, depth int) []string {
+	if depth > 8 {
+		return nil
+	}
+	hostPath, err := utils.SecureJoin(root, file)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(hostPath)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "include":
+			for _, pattern := range fields[1:] {
+				// include 的相对路径相对于当前配置文件所在目录
+				if !path.IsAbs(pattern) {
+					pattern = path.Join(path.Dir(file), pattern)
+				}
+				for _, m := range globInRoot(root, pattern) {
+					dirs = append(dirs, readLdsoConf(root, m, depth+1)...)
+				}
+			}
+		case "hwcap":
+			// 旧版本的 hwcap 子目录配置，不影响查找顺序
+		default:
+			for _, f := range fields {
+				dirs = append(dirs, splitSearchPath(f)...)
+			}
+		}
+	}
+	return dirs
+}
+
+// readLdsoCache 解析 /etc/ld.so.cache，兼容旧格式后附加新格式的文件
+func readLdsoCache(root string) map[string][]string {
+	cache := make(map[string][]string)
+	data, err := os.ReadFile(rootPath(root, "etc/ld.so.cache"))
+	if err != nil {
+		return cache
+	}
+	start := bytes.Index(data, []byte(ldsoCacheMagic))
+	if start < 0 || len(data) < start+ldsoCacheHeaderLen {
+		return cache
+	}
+	// 新格式中字符串偏移相对于新格式头部
+	body := data[start:]
+	order := binary.ByteOrder(binary.LittleEndian)
+	nlibs := int(order.Uint32(body[20:]))
+	if nlibs > len(body)/ldsoCacheEntryLen {
+		order = binary.BigEndian
+		nlibs = int(order.Uint32(body[20:]))
+	}
+	cstring := func(off uint32) string {
+		if int(off) >= len(body) {
+			return ""
+		}
+		s := body[off:]
+		if end := bytes.IndexByte(s, 0); end >= 0 {
+			return string(s[:end])
+		}
+		return ""
+	}
+	for i := 0; i < nlibs; i++ {
+		e := ldsoCacheHeaderLen + i*ldsoCacheEntryLen
+		if e+ldsoCacheEntryLen > len(body) {
+			break
+		}
+		key, value := cstring(order.Uint32(body[e+4:])), cstring(order.Uint32(body[e+8:]))
+		if key != "" && value != "" {
+			cache[key] = append(cache[key], value)
+		}
+	}
+	return cache
+}
+
+// resolve 按照 ld.so 的顺序查找 NEEDED 中的库：DT_RPATH（没有 DT_RUNPATH 时）、
+// LD_LIBRARY_PATH、DT_RUNPATH、ld.so.cache、默认目录。musl 不使用缓存，按配置文件或默认目录查找。
+// 只考虑文件自身的 RPATH，不继承加载链上其他对象的 RPATH。返回镜像内路径，找不到时返回空字符串
+func (r *libraryResolver) resolve(obj *ELFFile, needed string) string {
+	want := elfIdent{class: obj.class, machine: obj.machine}
+	origin := path.Dir(obj.Path)
+
+	if strings.Contains(needed, "/") {
+		p := needed
+		if !path.IsAbs(p) {
+			p = path.Join(origin, p)
+		}
+		return r.tryLibrary(p, want)
+	}
+
+	var dirs []string
+	if strings.Contains(obj.Interpreter, "ld-musl-") {
+		arch := strings.TrimSuffix(strings.TrimPrefix(path.Base(obj.Interpreter), "ld-musl-"), ".so.1")
+		dirs = append(dirs, r.ldLibraryPath...)
+		if musl, ok := r.muslDirs[arch]; ok {
+			dirs = append(dirs, musl...)
+		} else {
+			dirs = append(dirs, "/lib", "/usr/local/lib", "/usr/lib")
+		}
+		return r.searchDirs(dirs, obj, needed, want)
+	}
+
+	if len(obj.RunPath) == 0 {
+		dirs = append(dirs, obj.RPath...)
+	}
+	dirs = append(dirs, r.ldLibraryPath...)
+	dirs = append(dirs, obj.RunPath...)
+	if p := r.searchDirs(dirs, obj, needed, want); p != "" {
+		return p
+	}
+	for _, p := range r.cache[needed] {
+		if found := r.tryLibrary(p, want); found != "" {
+			return found
+		}
+	}
+	// 缓存缺失或过期时 ldconfig 配置的目录仍然有效
+	dirs = append([]string{}, r.confDirs...)
+	if obj.class == elf.ELFCLASS64 {
+		dirs = append(dirs, "/lib64", "/usr/lib64")
+	}
+	dirs = append(dirs, "/lib", "/usr/lib")
+	return r.searchDirs(dirs, obj, needed, want)
+}
+
+func (r *libraryResolver) searchDirs(dirs []string, obj *ELFFile, needed string, want elfIdent) string {
+	for _, dir := range dirs {
+		dir = expandDynamicTokens(dir, obj)
+		if dir == "" {
+			continue
+		}
+		if p := r.tryLibrary(path.Join(dir, needed), want); p != "" {
+			return p
+		}
+	}
+	return ""
+}
+
+// expandDynamicTokens 展开搜索路径中的 $ORIGIN 和 $LIB，不支持的 token 返回空字符串
+func expandDynamicTokens(dir string, obj *ELFFile) string {
+	lib := "lib"
+	if obj.class == elf.ELFCLASS64 {
+		lib = "lib64"
+	}
+	for _, token := range []struct{ name, value string }{
+		{"ORIGIN", path.Dir(obj.Path)},
+		{"LIB", lib},
+	} {
+		dir = strings.ReplaceAll(dir, "${"+token.name+"}", token.value)
+		dir = strings.ReplaceAll(dir, "$"+token.name, token.value)
+	}
+	if strings.Contains(dir, "$") || !path.IsAbs(dir) {
+		return ""
+	}
+	return dir
+}
+
+// tryLibrary 检查镜像内的路径是否为 class 和 machine 相同的 ELF 文件，是则返回该路径
+func (r *libraryResolver) tryLibrary(p string, want elfIdent) string {
+	r.mu.Lock()
+	ident, ok := r.candidates[p]
+	r.mu.Unlock()
+	if !ok {
+		ident = readELFIdent(r.root, p)
+		r.mu.Lock()
+		r.candidates[p] = ident
+		r.mu.Unlock()
+	}
+	if ident == nil || *ident != want {
+		return ""
+	}
+	return p
+}
+
+func readELFIdent(root, p string) *elfIdent {
+	hostPath, err := utils.SecureJoin(root, p)
+	if err != nil {
+		return nil
+	}
+	if fi, err := os.Stat(hostPath); err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := elf.Open(hostPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return &elfIdent{class: f.Class, machine: f.Machine}
+}
@@ -0,0 +1,114 @@
+package analyze
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// LibcInfo 是镜像使用的 C 库
+type LibcInfo struct {
+	Family  string `json:"family"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	// Source 为版本号的来源
+	Source string `json:"source"`
+}
+
+const (
+	LibcGlibc = "glibc"
+	LibcMusl  = "musl"
+)
+
+// libcSearchPatterns 是 libc.so.6 可能所在的位置，包括 Debian 系的 multiarch 目录
+var libcSearchPatterns = []string{
+	"/lib/libc.so.6", "/lib/*/libc.so.6", "/lib64/libc.so.6",
+	"/usr/lib/libc.so.6", "/usr/lib/*/libc.so.6", "/usr/lib64/libc.so.6",
+}
+
+var (
+	// 例如 GNU C Library (Debian GLIBC 2.36-9+deb12u4) stable release version 2.36.
+	glibcReleasePattern  = regexp.MustCompile(`GNU C Library[^\x00]*? release version (\d+\.\d+)`)
+	glibcFilenamePattern = regexp.MustCompile(`^libc-(\d+\.\d+)\.so$`)
+	muslVersionPattern   = regexp.MustCompile(`\x00(1\.\d+\.\d+)\x00`)
+)
+
+// DetectLibc 检测镜像使用 glibc 还是 musl 及其版本，都找不到时返回 nil
+func DetectLibc(root string) *LibcInfo {
+	for _, pattern := range libcSearchPatterns {
+		for _, m := range globInRoot(root, pattern) {
+			if info := detectGlibc(root, m); info != nil {
+				return info
+			}
+		}
+	}
+
+	for _, loader := range globInRoot(root, "lib/ld-musl-*.so.1") {
+		info := &LibcInfo{Family: LibcMusl, Path: loader}
+		if v := apkPackageVersion(root, "musl"); v != "" {
+			// 去掉 -r2 之类的修订号
+			info.Version, _, _ = strings.Cut(v, "-")
+			info.Source = "apk database"
+			return info
+		}
+		if hostPath, err := utils.SecureJoin(root, info.Path); err == nil {
+			if m := findInFile(hostPath, muslVersionPattern, 32); m != nil {
+				info.Version, info.Source = m[1], "loader"
+			}
+		}
+		return info
+	}
+	return nil
+}
+
+func detectGlibc(root, path string) *LibcInfo {
+	resolved, _, err := utils.ResolveInRoot(root, path)
+	if err != nil {
+		return nil
+	}
+	hostPath := filepath.Join(root, resolved)
+	if !isELF(hostPath) {
+		return nil
+	}
+	info := &LibcInfo{Family: LibcGlibc, Path: path}
+	if m := findInFile(hostPath, glibcReleasePattern, 256); m != nil {
+		info.Version, info.Source = m[1], "version string"
+		return info
+	}
+	// 旧版本的 libc.so.6 是指向 libc-X.Y.so 的符号链接
+	if m := glibcFilenamePattern.FindStringSubmatch(filepath.Base(resolved)); m != nil {
+		info.Version, info.Source = m[1], "filename"
+	}
+	return info
+}
+
+// apkPackageVersion 从 /lib/apk/db/installed 中读取包的版本
+func apkPackageVersion(root, name string) string {
+	for _, p := range readAPKPackages(root) {
+		if p.Name == name {
+			return p.Version
+		}
+	}
+	return ""
+}
+
+// compareLibcVersion 比较 X.Y 形式的版本号
+func compareLibcVersion(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if c := compareInt(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,415 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// LicenseReport 是镜像内各包的许可证清单和策略检查结果
+type LicenseReport struct {
+	Packages []LicensedPackage `json:"packages"`
+	// Summary 为每个许可证出现在多少个包中，无法确定许可证的包计入 NOASSERTION
+	Summary    map[string]int     `json:"summary"`
+	Violations []LicenseViolation `json:"violations"`
+}
+
+// LicensedPackage 是一个包及其许可证
+type LicensedPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+	// Declared 为包元数据中的原始声明，由全文识别得到时为空
+	Declared string `json:"declared,omitempty"`
+	// Expression 为规范化后的 SPDX 表达式，无法确定时为 NOASSERTION
+	Expression string   `json:"expression"`
+	Licenses   []string `json:"licenses,omitempty"`
+	// Source 为 metadata（包元数据）、copyright（Debian copyright 文件）或 classifier（许可证全文识别）
+	Source string `json:"source,omitempty"`
+	// Path 为记录许可证的文件或包目录在镜像内的路径
+	Path string `json:"path,omitempty"`
+}
+
+// LicenseViolation 是一个不符合许可证策略的包
+type LicenseViolation struct {
+	Ecosystem  string   `json:"ecosystem"`
+	Name       string   `json:"name"`
+	Version    string   `json:"version,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	Expression string   `json:"expression"`
+	Licenses   []string `json:"licenses,omitempty"`
+	// Reason 为 denied（命中禁止列表）、not-allowed（不在允许列表中）或 unknown（配置了允许列表但无法确定许可证）
+	Reason string `json:"reason"`
+}
+
+// LicensePolicy 是许可证策略，列表项为 SPDX 标识符，支持 * 通配符且不区分大小写，例如 "AGPL-*"。
+// 禁止列表优先；允许列表为空时不限制，否则表达式中必须有一种可选的组合全部在允许列表中
+type LicensePolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+const (
+	LicenseSourceMetadata   = "metadata"
+	LicenseSourceCopyright  = "copyright"
+	LicenseSourceClassifier = "classifier"
+
+	LicenseReasonDenied     = "denied"
+	LicenseReasonNotAllowed = "not-allowed"
+	LicenseReasonUnknown    = "unknown"
+
+	// licenseNoAssertion 是 SPDX 中表示未能确定许可证的值
+	licenseNoAssertion = "NOASSERTION"
+)
+
+// licenseFileMaxSize 是读取许可证全文的最大大小，超出的部分不参与识别
+const licenseFileMaxSize = 256 << 10
+
+// licenseFilePrefixes 是常见的许可证文件名前缀，比较时不区分大小写
+var licenseFilePrefixes = []string{"license", "licence", "copying", "unlicense", "notice"}
+
+// CheckLicenses 收集系统包和各语言包的许可证，规范化为 SPDX 表达式，并按策略检查
+func CheckLicenses(root string, policy LicensePolicy, workers int) *LicenseReport {
+	report := &LicenseReport{Packages: []LicensedPackage{}, Summary: map[string]int{}, Violations: []LicenseViolation{}}
+	seen := make(map[string]bool)
+	add := func(p LicensedPackage) {
+		key := strings.Join([]string{p.Ecosystem, p.Name, p.Version, p.Path}, "\x00")
+		if p.Name == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		report.Packages = append(report.Packages, p)
+	}
+
+	for _, p := range readDpkgPackages(root) {
+		add(dpkgLicense(root, p))
+	}
+	for _, p := range readAPKPackages(root) {
+		add(declaredLicense("apk", p.Name, p.Version, p.License, "/lib/apk/db/installed"))
+	}
+	if rpms, err := readRPMPackages(root); err == nil {
+		for _, p := range rpms {
+			add(declaredLicense("rpm", p.Name, p.EVR(), p.License, "rpmdb"))
+		}
+	}
+	for _, sp := range findSitePackages(root) {
+		for _, d := range listPythonDists(root, sp) {
+			add(pythonLicense(root, d))
+		}
+	}
+	if inv := ListNodePackages(root); inv != nil {
+		for _, p := range inv.Packages {
+			lp := declaredLicense("npm", p.Name, p.Version, p.License, p.Path)
+			if p.License == "" {
+				lp = classifyLicenseDir(root, lp)
+			}
+			add(lp)
+		}
+	}
+	for _, env := range ListCondaEnvironments(root) {
+		for _, p := range env.Packages {
+			add(declaredLicense("conda", p.Name, p.Version, p.License, env.Prefix))
+		}
+	}
+	for _, p := range ListPackages(root, workers) {
+		add(declaredLicense(p.Ecosystem, p.Name, p.Version, p.License, p.Location))
+	}
+
+	sort.SliceStable(report.Packages, func(i, j int) bool {
+		a, b := report.Packages[i], report.Packages[j]
+		if a.Ecosystem != b.Ecosystem {
+			return a.Ecosystem < b.Ecosystem
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	for _, p := range report.Packages {
+		if len(p.Licenses) == 0 {
+			report.Summary[licenseNoAssertion]++
+		}
+		for _, id := range p.Licenses {
+			report.Summary[id]++
+		}
+		if reason := policy.check(p); reason != "" {
+			report.Violations = append(report.Violations, LicenseViolation{
+				Ecosystem:  p.Ecosystem,
+				Name:       p.Name,
+				Version:    p.Version,
+				Path:       p.Path,
+				Expression: p.Expression,
+				Licenses:   p.Licenses,
+				Reason:     reason,
+			})
+		}
+	}
+	return report
+}
+
+// declaredLicense 由包元数据中声明的许可证生成记录
+func declaredLicense(ecosystem, name, version, declared, location string) LicensedPackage {
+	p := LicensedPackage{Ecosystem: ecosystem, Name: name, Version: version, Path: location}
+	p.setExpression(parseLicenseExpression(declared), LicenseSourceMetadata)
+	if p.Source != "" {
+		p.Declared = strings.TrimSpace(declared)
+	}
+	return p
+}
+
+// setExpression 记录规范化后的表达式，n 为 nil 时记为 NOASSERTION
+func (p *LicensedPackage) setExpression(n *licenseNode, source string) {
+	if n == nil {
+		p.Expression, p.Licenses, p.Source = licenseNoAssertion, nil, ""
+		return
+	}
+	p.Expression, p.Licenses, p.Source = n.String(), n.ids(), source
+}
+
+// dpkgLicense 读取 /usr/share/doc/<包名>/copyright：机器可读格式（DEP-5）时合并各 Files 段的 License 字段，
+// 否则对全文进行识别
+func dpkgLicense(root string, pkg dpkgPackage) LicensedPackage {
+	location := "/usr/share/doc/" + pkg.Name + "/copyright"
+	p := LicensedPackage{Ecosystem: "deb", Name: pkg.Name, Version: pkg.Version, Path: location}
+	p.setExpression(nil, "")
+	data, err := readLicenseFile(root, location)
+	if err != nil {
+		return p
+	}
+	if declared := parseDEP5Licenses(data); len(declared) > 0 {
+		var nodes []*licenseNode
+		for _, d := range declared {
+			if n := parseLicenseExpression(d); n != nil {
+				nodes = append(nodes, n)
+			}
+		}
+		// 不同文件使用不同许可证时，整个包需要同时满足全部许可证
+		p.setExpression(combineLicenses("AND", nodes), LicenseSourceCopyright)
+		p.Declared = strings.Join(declared, "; ")
+		return p
+	}
+	if ids := classifyLicenseText(string(data)); len(ids) > 0 {
+		p.setExpression(licenseIDsNode("AND", ids), LicenseSourceClassifier)
+	}
+	return p
+}
+
+// parseDEP5Licenses 返回 DEP-5 格式 copyright 文件中各 Files 段的 License 字段（只取第一行的简称），
+// 不是 DEP-5 格式时返回 nil
+func parseDEP5Licenses(data []byte) []string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() || !strings.HasPrefix(strings.ToLower(scanner.Text()), "format:") {
+		return nil
+	}
+	var licenses []string
+	inFiles := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			inFiles = false
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		key, value, _ := strings.Cut(line, ":")
+		switch strings.ToLower(key) {
+		case "files":
+			inFiles = true
+		case "license":
+			// 独立的 License 段是许可证全文，只统计 Files 段引用的许可证
+			if value = strings.TrimSpace(value); inFiles && value != "" && !containsString(licenses, value) {
+				licenses = append(licenses, value)
+			}
+		}
+	}
+	return licenses
+}
+
+// pythonLicense 依次使用 License-Expression、较短的 License 字段、License 分类器和 dist-info 中的许可证文件
+func pythonLicense(root string, d pythonDist) LicensedPackage {
+	if d.LicenseExpression != "" {
+		return declaredLicense("pypi", d.Name, d.Version, d.LicenseExpression, d.MetaDir)
+	}
+	// 不少包在 License 字段中放了许可证全文，这种情况交给全文识别；
+	// 较短的值无法识别为 SPDX 许可证时，优先使用分类器和许可证文件
+	var short *LicensedPackage
+	if l := strings.TrimSpace(d.License); l != "" && !strings.Contains(l, "\n") && len(l) <= 64 {
+		p := declaredLicense("pypi", d.Name, d.Version, l, d.MetaDir)
+		if p.Source != "" && !allLicenseRefs(p.Licenses) {
+			return p
+		}
+		short = &p
+	}
+	var classifiers []string
+	for _, c := range d.Classifiers {
+		if rest, ok := strings.CutPrefix(c, "License :: OSI Approved :: "); ok {
+			classifiers = append(classifiers, rest)
+		} else if rest, ok := strings.CutPrefix(c, "License :: "); ok && !strings.Contains(rest, "::") {
+			classifiers = append(classifiers, rest)
+		}
+	}
+	p := LicensedPackage{Ecosystem: "pypi", Name: d.Name, Version: d.Version, Path: d.MetaDir}
+	if len(classifiers) > 0 {
+		var nodes []*licenseNode
+		for _, c := range classifiers {
+			nodes = append(nodes, &licenseNode{id: normalizeLicenseID(c)})
+		}
+		// 多个许可证分类器表示可以任选其一
+		p.setExpression(combineLicenses("OR", nodes), LicenseSourceMetadata)
+		p.Declared = strings.Join(classifiers, "; ")
+		return p
+	}
+	if text := strings.TrimSpace(d.License); strings.Contains(text, "\n") {
+		if ids := classifyLicenseText(text); len(ids) > 0 {
+			p.setExpression(licenseIDsNode("AND", ids), LicenseSourceClassifier)
+			return p
+		}
+	}
+	if p = classifyLicenseDir(root, p); p.Source == "" && short != nil && short.Source != "" {
+		return *short
+	}
+	return p
+}
+
+// allLicenseRefs 判断许可证是否全部为无法识别的 LicenseRef- 引用
+func allLicenseRefs(ids []string) bool {
+	for _, id := range ids {
+		if !strings.HasPrefix(id, "LicenseRef-") {
+			return false
+		}
+	}
+	return true
+}
+
+// classifyLicenseDir 在包目录（及 dist-info 的 licenses 子目录）中查找许可证文件并识别全文
+func classifyLicenseDir(root string, p LicensedPackage) LicensedPackage {
+	p.setExpression(nil, "")
+	if p.Path == "" {
+		return p
+	}
+	var ids []string
+	for _, dir := range []string{p.Path, path.Join(p.Path, "licenses")} {
+		entries, err := os.ReadDir(rootPath(root, dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() || !isLicenseFileName(e.Name()) {
+				continue
+			}
+			data, err := readLicenseFile(root, path.Join(dir, e.Name()))
+			if err != nil {
+				continue
+			}
+			for _, id := range classifyLicenseText(string(data)) {
+				if !containsString(ids, id) {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	if len(ids) > 0 {
+		sort.Strings(ids)
+		p.setExpression(licenseIDsNode("AND", ids), LicenseSourceClassifier)
+	}
+	return p
+}
+
+func isLicenseFileName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, prefix := range licenseFilePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// readLicenseFile 读取镜像内的文件，最多读取 licenseFileMaxSize 字节，不跟随指向镜像外的符号链接
+func readLicenseFile(root, p string) ([]byte, error) {
+	full, err := utils.SecureJoin(root, p)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, licenseFileMaxSize))
+}
+
+func licenseIDsNode(op string, ids []string) *licenseNode {
+	nodes := make([]*licenseNode, len(ids))
+	for i, id := range ids {
+		nodes[i] = &licenseNode{id: id}
+	}
+	return combineLicenses(op, nodes)
+}
+
+// check 返回包违反策略的原因，符合策略时返回空字符串
+func (policy LicensePolicy) check(p LicensedPackage) string {
+	if len(policy.Allow) == 0 && len(policy.Deny) == 0 {
+		return ""
+	}
+	if len(p.Licenses) == 0 {
+		if len(policy.Allow) > 0 {
+			return LicenseReasonUnknown
+		}
+		return ""
+	}
+	n := parseLicenseExpression(p.Expression)
+	if n == nil {
+		return LicenseReasonUnknown
+	}
+	// 只有当所有可选组合都用到被禁止的许可证时才算违规，例如 "MIT OR GPL-2.0-only" 可以选择 MIT
+	if !policy.satisfiable(n, false) {
+		return LicenseReasonDenied
+	}
+	if len(policy.Allow) > 0 && !policy.satisfiable(n, true) {
+		return LicenseReasonNotAllowed
+	}
+	return ""
+}
+
+// satisfiable 判断表达式是否存在符合策略的选择，checkAllow 为 false 时只检查禁止列表
+func (policy LicensePolicy) satisfiable(n *licenseNode, checkAllow bool) bool {
+	switch n.op {
+	case "OR":
+		for _, k := range n.kids {
+			if policy.satisfiable(k, checkAllow) {
+				return true
+			}
+		}
+		return false
+	case "AND":
+		for _, k := range n.kids {
+			if !policy.satisfiable(k, checkAllow) {
+				return false
+			}
+		}
+		return true
+	}
+	if matchLicensePatterns(policy.Deny, n.id) {
+		return false
+	}
+	return !checkAllow || matchLicensePatterns(policy.Allow, n.id)
+}
+
+func matchLicensePatterns(patterns []string, id string) bool {
+	id = strings.ToLower(id)
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimSpace(p)), id); ok {
+			return true
+		}
+	}
+	return false
+}
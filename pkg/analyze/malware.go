@@ -0,0 +1,374 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-analyzer-go/pkg/utils"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// MalwareScanResult 是恶意和可疑内容检测的结果
+type MalwareScanResult struct {
+	Rules           int          `json:"rules"`
+	HashListEntries int          `json:"hash_list_entries"`
+	FilesScanned    int          `json:"files_scanned"`
+	Hits            []MalwareHit `json:"hits"`
+	// Errors 为加载规则文件和摘要列表时的错误，出错的文件被跳过
+	Errors []string `json:"errors,omitempty"`
+}
+
+// MalwareHit 是一个文件命中的一条规则
+type MalwareHit struct {
+	// RuleID 为规则名称，命中摘要列表时为 known-bad-hash
+	RuleID string `json:"rule_id"`
+	// Source 为 builtin（内置的挖矿特征）、rule（规则文件）或 hash（摘要列表）
+	Source      string   `json:"source"`
+	Description string   `json:"description,omitempty"`
+	Severity    string   `json:"severity"`
+	Tags        []string `json:"tags,omitempty"`
+	Path        string   `json:"path"`
+	// Layer 为引入该文件的层序号，从 0 开始，无法确定时省略
+	Layer       *int   `json:"layer,omitempty"`
+	LayerDigest string `json:"layer_digest,omitempty"`
+	// Strings 为命中的字符串标识符
+	Strings []string `json:"strings,omitempty"`
+	SHA256  string   `json:"sha256,omitempty"`
+}
+
+// MalwareScanOptions 是恶意内容检测的配置
+type MalwareScanOptions struct {
+	// RuleFiles 为 YARA 子集语法的规则文件，支持的语法见 yara.go
+	RuleFiles []string
+	// HashLists 为已知恶意文件的 SHA-256 列表，每行一个摘要，摘要后可以跟文件名或说明，# 开头的行为注释
+	HashLists []string
+	// MaxFileSize 为内容规则扫描的最大文件大小，0 表示使用默认值，摘要比较不受限制
+	MaxFileSize int64
+}
+
+const (
+	MalwareSourceBuiltin = "builtin"
+	MalwareSourceRule    = "rule"
+	MalwareSourceHash    = "hash"
+)
+
+// defaultMalwareMaxFileSize 是内容规则默认扫描的最大文件大小
+const defaultMalwareMaxFileSize = 32 << 20
+
+// builtinMinerRules 是内置的挖矿程序特征，使用与规则文件相同的语法
+const builtinMinerRules = `
+rule xmrig_miner : miner {
+    meta:
+        description = "XMRig 门罗币挖矿程序"
+        severity = "critical"
+    strings:
+        $name = "xmrig" nocase
+        $opt_donate = "donate-level"
+        $opt_randomx = "randomx" nocase
+        $opt_cryptonight = "cryptonight" nocase
+        $opt_pool = "--url="
+    condition:
+        $name and 2 of ($opt*)
+}
+
+rule stratum_url : miner {
+    meta:
+        description = "矿池 stratum 协议地址"
+        severity = "high"
+    strings:
+        $url = /stratum[0-9]?\+(tcp|ssl|tls):\/\/[A-Za-z0-9.\-]+(:[0-9]+)?/ nocase
+    condition:
+        $url
+}
+
+rule mining_pool_domain : miner {
+    meta:
+        description = "知名矿池域名"
+        severity = "high"
+    strings:
+        $minexmr = "minexmr.com" nocase
+        $supportxmr = "supportxmr.com" nocase
+        $moneroocean = "moneroocean.stream" nocase
+        $nanopool = "nanopool.org" nocase
+        $twominers = "2miners.com" nocase
+        $f2pool = "f2pool.com" nocase
+        $hashvault = "hashvault.pro" nocase
+        $c3pool = "c3pool.com" nocase
+        $herominers = "herominers.com" nocase
+        $unmineable = "unmineable.com" nocase
+    condition:
+        any of them
+}
+
+rule miner_config : miner {
+    meta:
+        description = "挖矿程序的矿池配置"
+        severity = "high"
+        filetype = "text"
+    strings:
+        $pools = "\"pools\""
+        $url = "\"url\""
+        $user = "\"user\""
+        $algo = "\"algo\""
+        $coin = "\"coin\""
+        $rig = "\"rig-id\""
+    condition:
+        $pools and $url and $user and any of ($algo, $coin, $rig)
+}
+
+rule miner_binary : miner {
+    meta:
+        description = "常见挖矿程序的可执行文件"
+        severity = "critical"
+        filetype = "elf"
+    strings:
+        $xmrstak = "xmr-stak" nocase
+        $cpuminer = "cpuminer" nocase
+        $ethminer = "ethminer" nocase
+        $phoenix = "PhoenixMiner"
+        $lolminer = "lolMiner"
+        $nbminer = "NBMiner"
+        $ccminer = "ccminer" nocase
+    condition:
+        any of them
+}
+`
+
+// malwareRuleSet 是一组规则和它们的来源
+type malwareRuleSet struct {
+	source string
+	rules  []*yaraRule
+}
+
+type malwareScanner struct {
+	sets        []malwareRuleSet
+	hashes      map[string]string
+	maxFileSize int64
+	errors      []string
+}
+
+func newMalwareScanner(opts MalwareScanOptions) *malwareScanner {
+	s := &malwareScanner{hashes: make(map[string]string), maxFileSize: opts.MaxFileSize}
+	if s.maxFileSize <= 0 {
+		s.maxFileSize = defaultMalwareMaxFileSize
+	}
+	builtin, err := parseYARARules("builtin", builtinMinerRules)
+	if err != nil {
+		panic(err)
+	}
+	s.sets = append(s.sets, malwareRuleSet{source: MalwareSourceBuiltin, rules: builtin})
+	for _, path := range opts.RuleFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.errors = append(s.errors, fmt.Sprintf("读取规则文件失败: %v", err))
+			continue
+		}
+		rules, err := parseYARARules(path, string(data))
+		if err != nil {
+			s.errors = append(s.errors, err.Error())
+			continue
+		}
+		s.sets = append(s.sets, malwareRuleSet{source: MalwareSourceRule, rules: rules})
+	}
+	for _, path := range opts.HashLists {
+		if err := s.loadHashList(path); err != nil {
+			s.errors = append(s.errors, fmt.Sprintf("读取摘要列表失败: %v", err))
+		}
+	}
+	return s
+}
+
+// loadHashList 读取摘要列表，兼容 sha256sum 的输出格式
+func (s *malwareScanner) loadHashList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, note, _ := strings.Cut(line, " ")
+		sum = strings.ToLower(sum)
+		if len(sum) != 64 {
+			return fmt.Errorf("%s 第 %d 行不是 SHA-256 摘要", path, n)
+		}
+		if _, err := hex.DecodeString(sum); err != nil {
+			return fmt.Errorf("%s 第 %d 行不是 SHA-256 摘要", path, n)
+		}
+		s.hashes[sum] = strings.TrimPrefix(strings.TrimSpace(note), "*")
+	}
+	return scanner.Err()
+}
+
+func (s *malwareScanner) ruleCount() int {
+	n := 0
+	for _, set := range s.sets {
+		n += len(set.rules)
+	}
+	return n
+}
+
+// ScanMalware 对文件系统中的文件应用内置的挖矿特征和规则文件，并与已知恶意文件的摘要列表比较
+func ScanMalware(root string, imgCfg *v1.Image, extract *utils.ExtractInfo, opts MalwareScanOptions, workers int) *MalwareScanResult {
+	s := newMalwareScanner(opts)
+	result := &MalwareScanResult{Rules: s.ruleCount(), HashListEntries: len(s.hashes), Hits: []MalwareHit{}, Errors: s.errors}
+	diffIDs := imgCfg.RootFS.DiffIDs
+
+	var files []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() && info.Size() > 0 && (info.Size() <= s.maxFileSize || len(s.hashes) > 0) {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	runParallel(len(files), workers, func(i int) {
+		p := imagePath(root, files[i])
+		hits := s.scanFile(files[i])
+		if len(hits) == 0 {
+			return
+		}
+		layer, ok := extract.Layer(p)
+		for j := range hits {
+			hits[j].Path = p
+			if ok {
+				l := layer
+				hits[j].Layer = &l
+				if layer < len(diffIDs) {
+					hits[j].LayerDigest = diffIDs[layer].String()
+				}
+			}
+		}
+		mu.Lock()
+		result.Hits = append(result.Hits, hits...)
+		mu.Unlock()
+	})
+	result.FilesScanned = len(files)
+
+	sort.SliceStable(result.Hits, func(i, j int) bool {
+		a, b := result.Hits[i], result.Hits[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra < rb
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.RuleID < b.RuleID
+	})
+	return result
+}
+
+// scanFile 读取不超过大小限制的文件并应用规则，超过限制的文件只计算摘要
+func (s *malwareScanner) scanFile(path string) []MalwareHit {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+
+	var hits []MalwareHit
+	// 只有不超过大小限制的文件才读入内存匹配内容规则
+	if fi.Size() <= s.maxFileSize {
+		data, err := io.ReadAll(io.LimitReader(f, s.maxFileSize))
+		if err != nil {
+			return nil
+		}
+		file := &yaraFile{data: data}
+		types := malwareFileTypes(data)
+		for _, set := range s.sets {
+			for _, r := range set.rules {
+				if len(r.FileTypes) > 0 && !intersects(r.FileTypes, types) {
+					continue
+				}
+				ok, ids := r.match(file)
+				if !ok {
+					continue
+				}
+				severity := normalizeSeverity(r.Meta["severity"])
+				if severity == "" {
+					severity = SeverityHigh
+				}
+				hits = append(hits, MalwareHit{
+					RuleID:      r.ID,
+					Source:      set.source,
+					Description: r.Meta["description"],
+					Severity:    severity,
+					Tags:        r.Tags,
+					Strings:     ids,
+				})
+			}
+		}
+	}
+
+	// 摘要单独从文件开头流式计算，不受大小限制影响
+	if len(s.hashes) > 0 {
+		h := sha256.New()
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return hits
+		}
+		if _, err := io.Copy(h, f); err != nil {
+			return hits
+		}
+		sum := hex.EncodeToString(h.Sum(nil))
+		if note, ok := s.hashes[sum]; ok {
+			desc := "与已知恶意文件的 SHA-256 相同"
+			if note != "" {
+				desc += "：" + note
+			}
+			hits = append(hits, MalwareHit{
+				RuleID:      "known-bad-hash",
+				Source:      MalwareSourceHash,
+				Description: desc,
+				Severity:    SeverityCritical,
+				SHA256:      sum,
+			})
+		}
+	}
+	return hits
+}
+
+// malwareFileTypes 返回文件内容对应的类型，用于规则的 filetype 过滤
+func malwareFileTypes(data []byte) []string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x7fELF")):
+		return []string{"elf", "binary"}
+	case bytes.HasPrefix(data, []byte("#!")):
+		return []string{"script", "text"}
+	}
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return []string{"binary"}
+	}
+	return []string{"text"}
+}
+
+func intersects(a, b []string) bool {
+	for _, s := range a {
+		if containsString(b, s) {
+			return true
+		}
+	}
+	return false
+}
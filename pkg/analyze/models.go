@@ -0,0 +1,312 @@
+package analyze
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// ModelInventory 是镜像中的模型文件和 Hugging Face 缓存
+type ModelInventory struct {
+	Artifacts          []ModelArtifact `json:"artifacts"`
+	HuggingFaceCaches  []HFCacheRepo   `json:"huggingface_caches"`
+	PickleArtifacts    int             `json:"pickle_artifacts"`
+	TotalArtifactBytes int64           `json:"total_artifact_bytes"`
+}
+
+// ModelArtifact 是一个模型文件及其头部元数据，不同格式只填写各自有的字段
+type ModelArtifact struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+	// Pickle 为 true 表示加载时会执行 pickle 反序列化，可能运行任意代码
+	Pickle       bool              `json:"pickle"`
+	TensorCount  int               `json:"tensor_count,omitempty"`
+	Parameters   int64             `json:"parameters,omitempty"`
+	DTypes       []string          `json:"dtypes,omitempty"`
+	IRVersion    int64             `json:"ir_version,omitempty"`
+	Opset        int64             `json:"opset,omitempty"`
+	Producer     string            `json:"producer,omitempty"`
+	Architecture string            `json:"architecture,omitempty"`
+	Quantization string            `json:"quantization,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// HFCacheRepo 是 Hugging Face hub 缓存中的一个仓库
+type HFCacheRepo struct {
+	Path      string   `json:"path"`
+	RepoType  string   `json:"repo_type"`
+	RepoID    string   `json:"repo_id"`
+	Revisions []string `json:"revisions"`
+	Size      int64    `json:"size"`
+}
+
+const (
+	ModelFormatSafetensors = "safetensors"
+	ModelFormatPyTorch     = "pytorch"
+	ModelFormatPickle      = "pickle"
+	ModelFormatONNX        = "onnx"
+	ModelFormatGGUF        = "gguf"
+	ModelFormatSavedModel  = "tensorflow-savedmodel"
+	ModelFormatGraphDef    = "tensorflow-graphdef"
+	ModelFormatHDF5        = "hdf5"
+)
+
+// maxSafetensorsHeader 是 safetensors JSON 头部的最大长度，与官方实现的限制一致
+const maxSafetensorsHeader = 100 << 20
+
+var hfCacheRepoPattern = regexp.MustCompile(`^(models|datasets|spaces)--(.+)$`)
+
+// modelFormatForFile 根据文件名判断模型格式，不是模型文件时返回空字符串
+func modelFormatForFile(name string) string {
+	lower := strings.ToLower(name)
+	switch filepath.Ext(lower) {
+	case ".safetensors":
+		return ModelFormatSafetensors
+	case ".pt", ".pth":
+		return ModelFormatPyTorch
+	case ".onnx":
+		return ModelFormatONNX
+	case ".gguf":
+		return ModelFormatGGUF
+	case ".h5", ".hdf5":
+		return ModelFormatHDF5
+	case ".pkl", ".pickle", ".joblib":
+		return ModelFormatPickle
+	case ".pb":
+		if lower == "saved_model.pb" {
+			return ModelFormatSavedModel
+		}
+		return ModelFormatGraphDef
+	case ".bin":
+		// transformers 旧格式的权重文件 pytorch_model.bin 实际是 torch.save 的输出
+		if strings.HasPrefix(lower, "pytorch_model") {
+			return ModelFormatPyTorch
+		}
+	}
+	return ""
+}
+
+// ListModelArtifacts 查找镜像中的模型文件并读取头部元数据，同时列出 Hugging Face 缓存中的仓库。
+// Hugging Face 缓存的 snapshots 目录中是指向 blobs 的符号链接，按链接路径报告
+func ListModelArtifacts(root string, workers int) *ModelInventory {
+	inv := &ModelInventory{
+		Artifacts:         []ModelArtifact{},
+		HuggingFaceCaches: []HFCacheRepo{},
+	}
+	type candidate struct {
+		path, hostPath, format string
+	}
+	var candidates []candidate
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if repo := readHFCacheRepo(root, path); repo != nil {
+				inv.HuggingFaceCaches = append(inv.HuggingFaceCaches, *repo)
+			}
+			return nil
+		}
+		format := modelFormatForFile(info.Name())
+		if format == "" {
+			return nil
+		}
+		hostPath := path
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := utils.SecureJoin(root, imagePath(root, path))
+			if err != nil {
+				return nil
+			}
+			hostPath = target
+		}
+		if fi, err := os.Stat(hostPath); err != nil || !fi.Mode().IsRegular() {
+			return nil
+		}
+		candidates = append(candidates, candidate{path: imagePath(root, path), hostPath: hostPath, format: format})
+		return nil
+	})
+
+	var mu sync.Mutex
+	runParallel(len(candidates), workers, func(i int) {
+		c := candidates[i]
+		artifact := readModelArtifact(c.hostPath, c.format)
+		artifact.Path = c.path
+		mu.Lock()
+		inv.Artifacts = append(inv.Artifacts, artifact)
+		mu.Unlock()
+	})
+
+	for _, a := range inv.Artifacts {
+		if a.Pickle {
+			inv.PickleArtifacts++
+		}
+		inv.TotalArtifactBytes += a.Size
+	}
+	sort.Slice(inv.Artifacts, func(i, j int) bool { return inv.Artifacts[i].Path < inv.Artifacts[j].Path })
+	sort.Slice(inv.HuggingFaceCaches, func(i, j int) bool { return inv.HuggingFaceCaches[i].Path < inv.HuggingFaceCaches[j].Path })
+	return inv
+}
+
+// readHFCacheRepo 识别 hub 缓存中形如 models--org--name 的仓库目录
+func readHFCacheRepo(root, dir string) *HFCacheRepo {
+	m := hfCacheRepoPattern.FindStringSubmatch(filepath.Base(dir))
+	if m == nil {
+		return nil
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "snapshots")); err != nil || !fi.IsDir() {
+		return nil
+	}
+	repo := &HFCacheRepo{
+		Path:      imagePath(root, dir),
+		RepoType:  strings.TrimSuffix(m[1], "s"),
+		RepoID:    strings.ReplaceAll(m[2], "--", "/"),
+		Revisions: []string{},
+	}
+	snapshots, _ := os.ReadDir(filepath.Join(dir, "snapshots"))
+	for _, s := range snapshots {
+		repo.Revisions = append(repo.Revisions, s.Name())
+	}
+	blobs, _ := os.ReadDir(filepath.Join(dir, "blobs"))
+	for _, b := range blobs {
+		if info, err := b.Info(); err == nil && info.Mode().IsRegular() {
+			repo.Size += info.Size()
+		}
+	}
+	return repo
+}
+
+// readModelArtifact 读取模型文件的头部，解析失败时在 Error 中记录原因
+func readModelArtifact(path, format string) ModelArtifact {
+	artifact := ModelArtifact{Format: format}
+	f, err := os.Open(path)
+	if err != nil {
+		artifact.Error = err.Error()
+		return artifact
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil {
+		artifact.Size = fi.Size()
+	}
+
+	switch format {
+	case ModelFormatSafetensors:
+		err = readSafetensorsHeader(f, &artifact)
+	case ModelFormatPyTorch:
+		err = readPyTorchFormat(f, artifact.Size, &artifact)
+	case ModelFormatPickle:
+		artifact.Pickle = true
+	case ModelFormatONNX:
+		err = readONNXHeader(f, &artifact)
+	case ModelFormatGGUF:
+		err = readGGUFHeader(f, &artifact)
+	case ModelFormatHDF5:
+		magic := make([]byte, 8)
+		if _, err = io.ReadFull(f, magic); err == nil && !bytes.Equal(magic, []byte("\x89HDF\r\n\x1a\n")) {
+			err = errors.New("不是 HDF5 文件")
+		}
+	}
+	if err != nil {
+		artifact.Error = err.Error()
+	}
+	return artifact
+}
+
+func readSafetensorsHeader(f *os.File, artifact *ModelArtifact) error {
+	var n uint64
+	if err := binary.Read(f, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	if n > maxSafetensorsHeader {
+		return errors.New("safetensors 头部过大")
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return err
+	}
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+
+	dtypes := make(map[string]bool)
+	for name, raw := range header {
+		if name == "__metadata__" {
+			var meta map[string]string
+			if json.Unmarshal(raw, &meta) == nil && len(meta) > 0 {
+				artifact.Metadata = meta
+			}
+			continue
+		}
+		var tensor struct {
+			DType string  `json:"dtype"`
+			Shape []int64 `json:"shape"`
+		}
+		if err := json.Unmarshal(raw, &tensor); err != nil {
+			continue
+		}
+		artifact.TensorCount++
+		dtypes[tensor.DType] = true
+		count := int64(1)
+		for _, d := range tensor.Shape {
+			count *= d
+		}
+		artifact.Parameters += count
+	}
+	artifact.DTypes = sortedKeys(dtypes)
+	return nil
+}
+
+// readPyTorchFormat 区分 torch.save 的 zip 格式（包括 TorchScript）和旧的纯 pickle 格式，两者加载时都会反序列化 pickle
+func readPyTorchFormat(f *os.File, size int64, artifact *ModelArtifact) error {
+	artifact.Pickle = true
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return err
+	}
+	switch {
+	case bytes.Equal(magic, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return err
+		}
+		artifact.Metadata = map[string]string{"container": "zip"}
+		for _, zf := range zr.File {
+			switch {
+			case strings.HasSuffix(zf.Name, "/constants.pkl"):
+				artifact.Metadata["torchscript"] = "true"
+			case strings.HasSuffix(zf.Name, "/version"):
+				if data, err := readZipFile(zf); err == nil {
+					artifact.Metadata["version"] = strings.TrimSpace(string(data))
+				}
+			}
+		}
+	case magic[0] == 0x80:
+		// pickle 协议 2 及以上以 PROTO 操作码开头
+		artifact.Metadata = map[string]string{"container": "pickle"}
+	default:
+		return errors.New("无法识别的 PyTorch 文件格式")
+	}
+	return nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for k := range set {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,479 @@
+package analyze
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"image-analyzer-go/pkg/logger"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NodeInventory 是镜像中 Node.js 运行时和包的清单
+type NodeInventory struct {
+	Runtimes  []NodeRuntime  `json:"runtimes"`
+	Packages  []NodePackage  `json:"packages"`
+	Lockfiles []NodeLockfile `json:"lockfiles"`
+}
+
+// NodeRuntime 是一个 node 可执行文件及其版本
+type NodeRuntime struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+}
+
+// NodePackage 是一个 npm 包
+type NodePackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	License string `json:"license,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Dev     bool   `json:"dev"`
+	Global  bool   `json:"global,omitempty"`
+}
+
+// NodeLockfile 是一个锁文件中记录的包
+type NodeLockfile struct {
+	Path     string        `json:"path"`
+	Type     string        `json:"type"`
+	Packages []NodePackage `json:"packages"`
+}
+
+var (
+	// node 二进制中内嵌的 process.release.headersUrl
+	nodeHeadersURLPattern = regexp.MustCompile(`nodejs\.org/download/release/v(\d+\.\d+\.\d+)/`)
+	nodeVersionHeaderDefs = regexp.MustCompile(`#define\s+NODE_(MAJOR|MINOR|PATCH)_VERSION\s+(\d+)`)
+)
+
+// packageJSON 是 package.json 中用到的字段
+type packageJSON struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	License         json.RawMessage   `json:"license"`
+	Licenses        json.RawMessage   `json:"licenses"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+func readPackageJSON(path string) (*packageJSON, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pkg packageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+// licenseString 兼容 "MIT"、{"type": "MIT"} 以及旧式的 licenses 数组
+func (p *packageJSON) licenseString() string {
+	for _, raw := range []json.RawMessage{p.License, p.Licenses} {
+		if len(raw) == 0 {
+			continue
+		}
+		var s string
+		if json.Unmarshal(raw, &s) == nil && s != "" {
+			return s
+		}
+		var obj struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(raw, &obj) == nil && obj.Type != "" {
+			return obj.Type
+		}
+		var arr []struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(raw, &arr) == nil {
+			var types []string
+			for _, a := range arr {
+				if a.Type != "" {
+					types = append(types, a.Type)
+				}
+			}
+			if len(types) > 0 {
+				return "(" + strings.Join(types, " OR ") + ")"
+			}
+		}
+	}
+	return ""
+}
+
+// ListNodePackages 遍历 node_modules 和锁文件，列出 Node.js 运行时和 npm 包
+func ListNodePackages(root string) *NodeInventory {
+	inv := &NodeInventory{
+		Runtimes:  []NodeRuntime{},
+		Packages:  []NodePackage{},
+		Lockfiles: []NodeLockfile{},
+	}
+	var moduleDirs []string
+
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if name == "node_modules" {
+				moduleDirs = append(moduleDirs, path)
+			}
+			return nil
+		}
+		inNodeModules := strings.Contains(path, "/node_modules/")
+		switch {
+		case (name == "node" || name == "nodejs") && filepath.Base(filepath.Dir(path)) == "bin" && info.Mode().IsRegular():
+			if rt := detectNodeRuntime(root, path); rt != nil {
+				inv.Runtimes = append(inv.Runtimes, *rt)
+			}
+		case name == "package-lock.json" && !inNodeModules:
+			inv.addLockfile(root, path, "package-lock.json", parsePackageLock)
+		case name == "yarn.lock" && !inNodeModules:
+			inv.addLockfile(root, path, "yarn.lock", parseYarnLock)
+		case name == "pnpm-lock.yaml" && !inNodeModules:
+			inv.addLockfile(root, path, "pnpm-lock.yaml", parsePnpmLock)
+		}
+		return nil
+	})
+
+	devInfo := make(map[string]*nodeProjectDevInfo)
+	for _, dir := range moduleDirs {
+		inv.Packages = append(inv.Packages, listNodeModules(root, dir, devInfo)...)
+	}
+	sort.Slice(inv.Packages, func(i, j int) bool { return inv.Packages[i].Path < inv.Packages[j].Path })
+	return inv
+}
+
+func (inv *NodeInventory) addLockfile(root, path, typ string, parse func([]byte) ([]NodePackage, error)) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	pkgs, err := parse(data)
+	if err != nil {
+		logger.Warn("解析锁文件失败", logger.WithString("file", imagePath(root, path)), logger.WithError(err))
+		return
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].Name != pkgs[j].Name {
+			return pkgs[i].Name < pkgs[j].Name
+		}
+		return pkgs[i].Version < pkgs[j].Version
+	})
+	inv.Lockfiles = append(inv.Lockfiles, NodeLockfile{Path: imagePath(root, path), Type: typ, Packages: pkgs})
+}
+
+// detectNodeRuntime 不执行 node，而是从二进制内嵌的下载地址或 node_version.h 得到版本
+func detectNodeRuntime(root, path string) *NodeRuntime {
+	if !isELF(path) {
+		return nil
+	}
+	rt := &NodeRuntime{Path: imagePath(root, path)}
+	if m := findInFile(path, nodeHeadersURLPattern, 128); m != nil {
+		rt.Version, rt.Source = m[1], "embedded release url"
+		return rt
+	}
+	prefix := filepath.Dir(filepath.Dir(path))
+	if data, err := os.ReadFile(filepath.Join(prefix, "include", "node", "node_version.h")); err == nil {
+		parts := make(map[string]string)
+		for _, m := range nodeVersionHeaderDefs.FindAllSubmatch(data, -1) {
+			if _, ok := parts[string(m[1])]; !ok {
+				parts[string(m[1])] = string(m[2])
+			}
+		}
+		if len(parts) == 3 {
+			rt.Version = fmt.Sprintf("%s.%s.%s", parts["MAJOR"], parts["MINOR"], parts["PATCH"])
+			rt.Source = "node_version.h"
+			return rt
+		}
+	}
+	return rt
+}
+
+// listNodeModules 列出一个 node_modules 目录下的包（包含 @scope 下的包），嵌套的 node_modules 单独处理
+func listNodeModules(root, dir string, cache map[string]*nodeProjectDevInfo) []NodePackage {
+	project := nodeProjectRoot(dir)
+	// npm 的全局安装目录为 {prefix}/lib/node_modules
+	global := filepath.Base(project) == "lib"
+	topLevel := dir == filepath.Join(project, "node_modules")
+
+	dev := cache[project]
+	if dev == nil {
+		dev = &nodeProjectDevInfo{}
+		if !global {
+			dev = loadNodeDevInfo(project)
+		}
+		cache[project] = dev
+	}
+
+	var pkgDirs []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if strings.HasPrefix(name, "@") {
+			scoped, _ := os.ReadDir(filepath.Join(dir, name))
+			for _, s := range scoped {
+				pkgDirs = append(pkgDirs, filepath.Join(dir, name, s.Name()))
+			}
+			continue
+		}
+		pkgDirs = append(pkgDirs, filepath.Join(dir, name))
+	}
+
+	var pkgs []NodePackage
+	for _, pkgDir := range pkgDirs {
+		pj, err := readPackageJSON(filepath.Join(pkgDir, "package.json"))
+		if err != nil || pj.Name == "" {
+			continue
+		}
+		rel, _ := filepath.Rel(project, pkgDir)
+		pkgs = append(pkgs, NodePackage{
+			Name:    pj.Name,
+			Version: pj.Version,
+			License: pj.licenseString(),
+			Path:    imagePath(root, pkgDir),
+			Dev:     dev.paths[filepath.ToSlash(rel)] || (topLevel && dev.direct[pj.Name]),
+			Global:  global,
+		})
+	}
+	return pkgs
+}
+
+// nodeProjectRoot 返回最外层 node_modules 所在的项目目录
+func nodeProjectRoot(dir string) string {
+	if i := strings.Index(dir, "/node_modules"); i >= 0 {
+		return dir[:i]
+	}
+	return filepath.Dir(dir)
+}
+
+// nodeProjectDevInfo 记录一个项目中哪些包是开发依赖
+type nodeProjectDevInfo struct {
+	// paths 为锁文件中标记为 dev 的包路径，例如 node_modules/jest
+	paths map[string]bool
+	// direct 为 package.json 中直接声明的 devDependencies
+	direct map[string]bool
+}
+
+// loadNodeDevInfo 从项目的锁文件和 package.json 中读取开发依赖信息
+func loadNodeDevInfo(project string) *nodeProjectDevInfo {
+	devPaths := make(map[string]bool)
+	for _, lock := range []string{
+		filepath.Join(project, "node_modules", ".package-lock.json"),
+		filepath.Join(project, "package-lock.json"),
+	} {
+		data, err := os.ReadFile(lock)
+		if err != nil {
+			continue
+		}
+		var pl packageLock
+		if json.Unmarshal(data, &pl) != nil {
+			continue
+		}
+		for path, p := range pl.Packages {
+			if p.Dev {
+				devPaths[path] = true
+			}
+		}
+		break
+	}
+
+	devDirect := make(map[string]bool)
+	if pj, err := readPackageJSON(filepath.Join(project, "package.json")); err == nil {
+		for name := range pj.DevDependencies {
+			devDirect[name] = true
+		}
+	}
+	return &nodeProjectDevInfo{paths: devPaths, direct: devDirect}
+}
+
+// packageLock 是 package-lock.json 的结构，v1 使用 dependencies，v2/v3 使用 packages
+type packageLock struct {
+	LockfileVersion int                          `json:"lockfileVersion"`
+	Packages        map[string]packageLockEntry  `json:"packages"`
+	Dependencies    map[string]packageLockLegacy `json:"dependencies"`
+}
+
+type packageLockEntry struct {
+	Name    string          `json:"name"`
+	Version string          `json:"version"`
+	License json.RawMessage `json:"license"`
+	Dev     bool            `json:"dev"`
+	Link    bool            `json:"link"`
+}
+
+type packageLockLegacy struct {
+	Version      string                       `json:"version"`
+	Dev          bool                         `json:"dev"`
+	Dependencies map[string]packageLockLegacy `json:"dependencies"`
+}
+
+func parsePackageLock(data []byte) ([]NodePackage, error) {
+	var pl packageLock
+	if err := json.Unmarshal(data, &pl); err != nil {
+		return nil, err
+	}
+
+	pkgs := []NodePackage{}
+	if len(pl.Packages) > 0 {
+		for path, p := range pl.Packages {
+			// "" 是项目自身，link 指向工作区中的目录
+			if path == "" || p.Link {
+				continue
+			}
+			name := p.Name
+			if name == "" {
+				name = path[strings.LastIndex(path, "node_modules/")+len("node_modules/"):]
+			}
+			pj := packageJSON{License: p.License}
+			pkgs = append(pkgs, NodePackage{Name: name, Version: p.Version, License: pj.licenseString(), Path: path, Dev: p.Dev})
+		}
+		return pkgs, nil
+	}
+
+	var walk func(prefix string, deps map[string]packageLockLegacy)
+	walk = func(prefix string, deps map[string]packageLockLegacy) {
+		for name, d := range deps {
+			path := prefix + "node_modules/" + name
+			pkgs = append(pkgs, NodePackage{Name: name, Version: d.Version, Path: path, Dev: d.Dev})
+			walk(path+"/", d.Dependencies)
+		}
+	}
+	walk("", pl.Dependencies)
+	return pkgs, nil
+}
+
+// parseYarnLock 同时支持 yarn v1 的自定义格式和 yarn berry 的 YAML 格式
+func parseYarnLock(data []byte) ([]NodePackage, error) {
+	pkgs := []NodePackage{}
+	seen := make(map[string]bool)
+	var name string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line[0] != ' ' && strings.HasSuffix(line, ":") {
+			spec := strings.TrimSuffix(line, ":")
+			spec = strings.TrimSpace(strings.Split(spec, ",")[0])
+			name = yarnSpecName(strings.Trim(spec, `"`))
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if name == "" || !strings.HasPrefix(trimmed, "version") {
+			continue
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(trimmed, "version"), ":"))
+		version = strings.Trim(version, `"`)
+		if key := name + "@" + version; !seen[key] {
+			seen[key] = true
+			pkgs = append(pkgs, NodePackage{Name: name, Version: version})
+		}
+		name = ""
+	}
+	return pkgs, scanner.Err()
+}
+
+// yarnSpecName 从 "@scope/pkg@^1.0.0" 或 "pkg@npm:^1.0.0" 中取出包名
+func yarnSpecName(spec string) string {
+	if spec == "__metadata" {
+		return ""
+	}
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		spec = spec[:i]
+	}
+	// 别名写法 pkg@npm:@scope/other@^1.0.0 在上一步之后仍包含 @
+	if i := strings.Index(spec[1:], "@"); i >= 0 {
+		spec = spec[:i+1]
+	}
+	return spec
+}
+
+// pnpmLock 是 pnpm-lock.yaml 中用到的字段
+type pnpmLock struct {
+	Packages  map[string]pnpmPackage `yaml:"packages"`
+	Importers map[string]struct {
+		DevDependencies map[string]interface{} `yaml:"devDependencies"`
+	} `yaml:"importers"`
+	DevDependencies map[string]interface{} `yaml:"devDependencies"`
+}
+
+type pnpmPackage struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Dev     bool   `yaml:"dev"`
+}
+
+// parsePnpmLock 解析 pnpm-lock.yaml，包的键在 v5 中为 /name/version，v6 为 /name@version，v9 为 name@version
+func parsePnpmLock(data []byte) ([]NodePackage, error) {
+	var lock pnpmLock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+
+	devDirect := make(map[string]bool)
+	for name := range lock.DevDependencies {
+		devDirect[name] = true
+	}
+	for _, imp := range lock.Importers {
+		for name := range imp.DevDependencies {
+			devDirect[name] = true
+		}
+	}
+
+	pkgs := []NodePackage{}
+	for key, p := range lock.Packages {
+		name, version := parsePnpmKey(key)
+		if p.Name != "" {
+			name = p.Name
+		}
+		if p.Version != "" {
+			version = p.Version
+		}
+		if name == "" {
+			continue
+		}
+		pkgs = append(pkgs, NodePackage{Name: name, Version: version, Dev: p.Dev || devDirect[name]})
+	}
+	return pkgs, nil
+}
+
+func parsePnpmKey(key string) (string, string) {
+	key = strings.TrimPrefix(key, "/")
+	// 去掉 v6/v9 的 peer 依赖后缀，例如 (react@18.2.0)
+	if i := strings.Index(key, "("); i >= 0 {
+		key = key[:i]
+	}
+
+	// v5 使用 name/version，scope 包名本身带一个 /
+	rest, offset := key, 0
+	if strings.HasPrefix(key, "@") {
+		if i := strings.Index(key, "/"); i >= 0 {
+			rest, offset = key[i+1:], i+1
+		}
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		name, version := key[:offset+i], rest[i+1:]
+		// v5 的 peer 依赖后缀为 _react@18.2.0
+		if j := strings.Index(version, "_"); j >= 0 {
+			version = version[:j]
+		}
+		return name, version
+	}
+
+	if i := strings.LastIndex(key, "@"); i > 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", ""
+}
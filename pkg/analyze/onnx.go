@@ -0,0 +1,209 @@
+package analyze
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ONNX ModelProto 中用到的字段编号
+const (
+	onnxFieldIRVersion       = 1
+	onnxFieldProducerName    = 2
+	onnxFieldProducerVersion = 3
+	onnxFieldOpsetImport     = 8
+)
+
+// protobuf 的 wire type
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// maxONNXField 是读入内存的字段的最大长度，graph 等大字段直接跳过
+const maxONNXField = 1 << 20
+
+// protoReader 按顺序读取 protobuf 的顶层字段，跳过大字段时直接 Seek
+type protoReader struct {
+	f   *os.File
+	r   *bufio.Reader
+	off int64
+}
+
+func (p *protoReader) ReadByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err == nil {
+		p.off++
+	}
+	return b, err
+}
+
+func (p *protoReader) varint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := p.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("protobuf varint 过长")
+}
+
+func (p *protoReader) skip(n int64) error {
+	if n < 0 {
+		return errors.New("protobuf 字段长度无效")
+	}
+	if n <= int64(p.r.Buffered()) {
+		_, err := p.r.Discard(int(n))
+		p.off += n
+		return err
+	}
+	p.off += n
+	if _, err := p.f.Seek(p.off, io.SeekStart); err != nil {
+		return err
+	}
+	p.r.Reset(p.f)
+	return nil
+}
+
+func (p *protoReader) bytes(n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(p.r, buf)
+	p.off += n
+	return buf, err
+}
+
+// readONNXHeader 读取 ModelProto 的 ir_version、producer 和默认域的 opset，跳过 graph
+func readONNXHeader(f *os.File, artifact *ModelArtifact) error {
+	p := &protoReader{f: f, r: bufio.NewReader(f)}
+	var producer, producerVersion string
+	seen := false
+	for {
+		key, err := p.varint()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		seen = true
+		field, wire := key>>3, key&7
+		switch wire {
+		case protoVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			if field == onnxFieldIRVersion {
+				artifact.IRVersion = int64(v)
+			}
+		case protoFixed64:
+			err = p.skip(8)
+		case protoFixed32:
+			err = p.skip(4)
+		case protoBytes:
+			n, err := p.varint()
+			if err != nil {
+				return err
+			}
+			switch field {
+			case onnxFieldProducerName, onnxFieldProducerVersion, onnxFieldOpsetImport:
+				if n > maxONNXField {
+					return errors.New("ONNX 字段过长")
+				}
+				data, err := p.bytes(int64(n))
+				if err != nil {
+					return err
+				}
+				switch field {
+				case onnxFieldProducerName:
+					producer = string(data)
+				case onnxFieldProducerVersion:
+					producerVersion = string(data)
+				case onnxFieldOpsetImport:
+					if domain, version := parseONNXOpset(data); domain == "" || domain == "ai.onnx" {
+						artifact.Opset = version
+					}
+				}
+			default:
+				err = p.skip(int64(n))
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("不是 ONNX 文件：未知的 wire type %d", wire)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !seen || artifact.IRVersion == 0 {
+		return errors.New("不是 ONNX 文件")
+	}
+	artifact.Producer = producer
+	if producerVersion != "" {
+		artifact.Producer += " " + producerVersion
+	}
+	return nil
+}
+
+// parseONNXOpset 解析 OperatorSetIdProto：1 为 domain，2 为 version
+func parseONNXOpset(data []byte) (string, int64) {
+	var (
+		domain  string
+		version int64
+	)
+	for i := 0; i < len(data); {
+		key, n := decodeVarint(data[i:])
+		if n == 0 {
+			break
+		}
+		i += n
+		switch key & 7 {
+		case protoVarint:
+			v, n := decodeVarint(data[i:])
+			if n == 0 {
+				return domain, version
+			}
+			i += n
+			if key>>3 == 2 {
+				version = int64(v)
+			}
+		case protoBytes:
+			l, n := decodeVarint(data[i:])
+			// 先按 uint64 比较，过大的长度转换为 int 后会变成负数
+			if n == 0 || l > uint64(len(data)-i-n) {
+				return domain, version
+			}
+			i += n
+			if key>>3 == 1 {
+				domain = string(data[i : i+int(l)])
+			}
+			i += int(l)
+		default:
+			return domain, version
+		}
+	}
+	return domain, version
+}
+
+// decodeVarint 解码 varint，返回值和占用的字节数，失败时字节数为 0
+func decodeVarint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		v |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
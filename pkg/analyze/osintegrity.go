@@ -0,0 +1,257 @@
+package analyze
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-analyzer-go/pkg/logger"
+	"image-analyzer-go/pkg/utils"
+)
+
+// OSIntegrityReport 是按包管理器记录的摘要校验系统文件的结果
+type OSIntegrityReport struct {
+	Manager         string               `json:"manager"`
+	CheckedPackages int                  `json:"checked_packages"`
+	CheckedFiles    int                  `json:"checked_files"`
+	Packages        []OSPackageIntegrity `json:"packages"`
+	// ChangedBinaries 汇总位于可执行文件和库目录下被修改的文件，是较强的篡改信号
+	ChangedBinaries []string `json:"changed_binaries"`
+}
+
+// OSPackageIntegrity 是一个系统包的校验结果，只包含存在问题的包
+type OSPackageIntegrity struct {
+	Package        string   `json:"package"`
+	Version        string   `json:"version"`
+	Modified       []string `json:"modified"`
+	Missing        []string `json:"missing"`
+	ModifiedConfig []string `json:"modified_config"`
+}
+
+// binaryDirs 是可执行文件和共享库所在的目录
+var binaryDirs = []string{
+	"/bin/", "/sbin/", "/lib/", "/lib32/", "/lib64/", "/libx32/",
+	"/usr/bin/", "/usr/sbin/", "/usr/lib/", "/usr/lib32/", "/usr/lib64/", "/usr/libexec/",
+	"/usr/local/bin/", "/usr/local/sbin/", "/usr/local/lib/",
+}
+
+func isBinaryPath(path string) bool {
+	for _, dir := range binaryDirs {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// osFileCheck 是一个待校验的系统文件
+type osFileCheck struct {
+	pkg     int
+	path    string
+	digest  string
+	newHash func() hash.Hash
+	config  bool
+	// optional 为 true 时文件缺失不视为问题（文档、missingok 等）
+	optional bool
+}
+
+// pgpHashAlgos 是 rpm FILEDIGESTALGO 使用的 PGP 哈希算法编号
+var pgpHashAlgos = map[int]func() hash.Hash{
+	1:  md5.New,
+	2:  sha1.New,
+	8:  sha256.New,
+	9:  sha512.New384,
+	10: sha512.New,
+	11: sha256.New224,
+}
+
+// VerifyOSPackages 按 dpkg 的 md5sums 或 rpm 头部中的文件摘要校验系统文件，
+// 配置文件单独列出。镜像中没有可识别的包数据库时返回 nil
+func VerifyOSPackages(root string, workers int) []OSIntegrityReport {
+	var reports []OSIntegrityReport
+	if dpkgs := readDpkgPackages(root); len(dpkgs) > 0 {
+		reports = append(reports, verifyDpkg(root, dpkgs, workers))
+	}
+	rpms, err := readRPMPackages(root)
+	if err != nil && !errors.Is(err, errRPMDBNotFound) {
+		logger.Warn("读取 rpm 数据库失败", logger.WithError(err))
+	}
+	if len(rpms) > 0 {
+		reports = append(reports, verifyRPM(root, rpms, workers))
+	}
+	return reports
+}
+
+func verifyDpkg(root string, dpkgs []dpkgPackage, workers int) OSIntegrityReport {
+	filter := loadDpkgPathFilter(root)
+	var (
+		pkgs   []OSPackageIntegrity
+		checks []osFileCheck
+	)
+	for _, p := range dpkgs {
+		idx := len(pkgs)
+		pkgs = append(pkgs, newOSPackageIntegrity(p.Name, p.Version))
+
+		if p.md5sums != "" {
+			for path, digest := range readMd5sums(rootPath(root, p.md5sums)) {
+				// 配置文件的摘要以 status 中的 Conffiles 为准
+				if _, ok := p.Conffiles[path]; ok {
+					continue
+				}
+				checks = append(checks, osFileCheck{
+					pkg: idx, path: path, digest: digest, newHash: md5.New,
+					optional: filter.excluded(path),
+				})
+			}
+		}
+		for path, digest := range p.Conffiles {
+			// newconffile/obsolete 表示配置文件尚未生效或已废弃
+			if digest == "newconffile" || digest == "obsolete" {
+				continue
+			}
+			checks = append(checks, osFileCheck{
+				pkg: idx, path: path, digest: digest, newHash: md5.New,
+				config: true, optional: true,
+			})
+		}
+	}
+	return runOSIntegrityChecks(root, "dpkg", pkgs, checks, workers)
+}
+
+func verifyRPM(root string, rpms []rpmPackage, workers int) OSIntegrityReport {
+	var (
+		pkgs   []OSPackageIntegrity
+		checks []osFileCheck
+	)
+	for _, p := range rpms {
+		idx := len(pkgs)
+		pkgs = append(pkgs, newOSPackageIntegrity(p.Name, p.EVR()))
+		newHash, ok := pgpHashAlgos[p.DigestAlgo]
+		if !ok {
+			logger.Warn("不支持的 rpm 文件摘要算法", logger.WithString("package", p.Name), logger.WithInt("algo", p.DigestAlgo))
+			continue
+		}
+		for _, f := range p.Files {
+			// 目录、符号链接和 ghost 文件没有摘要
+			if f.Flags&rpmFileGhost != 0 || f.Digest == "" || f.LinkTo != "" || f.Mode&0170000 != 0100000 {
+				continue
+			}
+			checks = append(checks, osFileCheck{
+				pkg: idx, path: f.Path, digest: f.Digest, newHash: newHash,
+				config: f.Flags&rpmFileConfig != 0,
+				// 容器镜像通常以 nodocs 安装，文档缺失是正常的
+				optional: f.Flags&(rpmFileDoc|rpmFileMissingOK) != 0,
+			})
+		}
+	}
+	return runOSIntegrityChecks(root, "rpm", pkgs, checks, workers)
+}
+
+func newOSPackageIntegrity(name, version string) OSPackageIntegrity {
+	return OSPackageIntegrity{
+		Package:        name,
+		Version:        version,
+		Modified:       []string{},
+		Missing:        []string{},
+		ModifiedConfig: []string{},
+	}
+}
+
+// runOSIntegrityChecks 并发校验文件并汇总结果
+func runOSIntegrityChecks(root, manager string, pkgs []OSPackageIntegrity, checks []osFileCheck, workers int) OSIntegrityReport {
+	report := OSIntegrityReport{
+		Manager:         manager,
+		CheckedPackages: len(pkgs),
+		CheckedFiles:    len(checks),
+		Packages:        []OSPackageIntegrity{},
+		ChangedBinaries: []string{},
+	}
+
+	var mu sync.Mutex
+	runParallel(len(checks), workers, func(i int) {
+		c := checks[i]
+		status := checkFileDigest(root, c.path, c.digest, c.newHash)
+		if status == "" || (status == "missing" && c.optional) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		p := &pkgs[c.pkg]
+		switch {
+		case status == "missing":
+			p.Missing = append(p.Missing, c.path)
+		case c.config:
+			p.ModifiedConfig = append(p.ModifiedConfig, c.path)
+		default:
+			p.Modified = append(p.Modified, c.path)
+			if isBinaryPath(c.path) {
+				report.ChangedBinaries = append(report.ChangedBinaries, c.path)
+			}
+		}
+	})
+
+	for _, p := range pkgs {
+		if len(p.Modified)+len(p.Missing)+len(p.ModifiedConfig) == 0 {
+			continue
+		}
+		sort.Strings(p.Modified)
+		sort.Strings(p.Missing)
+		sort.Strings(p.ModifiedConfig)
+		report.Packages = append(report.Packages, p)
+	}
+	sort.Strings(report.ChangedBinaries)
+	return report
+}
+
+// checkFileDigest 计算文件摘要并与期望值比较，返回 "missing"、"modified" 或空字符串
+func checkFileDigest(root, path, digest string, newHash func() hash.Hash) string {
+	hostPath, err := utils.SecureJoin(root, path)
+	if err != nil {
+		return "missing"
+	}
+	f, err := os.Open(hostPath)
+	if err != nil {
+		return "missing"
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		return "missing"
+	}
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "missing"
+	}
+	if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), digest) {
+		return "modified"
+	}
+	return ""
+}
+
+// readMd5sums 解析 dpkg 的 md5sums 文件，返回镜像内绝对路径到摘要的映射
+func readMd5sums(path string) map[string]string {
+	sums := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return sums
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		digest, file, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			continue
+		}
+		sums["/"+strings.TrimPrefix(file, "/")] = strings.TrimSpace(digest)
+	}
+	return sums
+}
@@ -0,0 +1,204 @@
+package analyze
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// osvRecord 是 OSV 格式的一条漏洞记录，只包含匹配和报告用到的字段
+type osvRecord struct {
+	ID               string         `json:"id"`
+	Modified         string         `json:"modified"`
+	Withdrawn        string         `json:"withdrawn,omitempty"`
+	Aliases          []string       `json:"aliases,omitempty"`
+	Summary          string         `json:"summary,omitempty"`
+	Severity         []osvSeverity  `json:"severity,omitempty"`
+	Affected         []osvAffected  `json:"affected"`
+	DatabaseSpecific map[string]any `json:"database_specific,omitempty"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Severity          []osvSeverity  `json:"severity,omitempty"`
+	Ranges            []osvRange     `json:"ranges,omitempty"`
+	Versions          []string       `json:"versions,omitempty"`
+	EcosystemSpecific map[string]any `json:"ecosystem_specific,omitempty"`
+	DatabaseSpecific  map[string]any `json:"database_specific,omitempty"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// walkOSVSource 依次读取 OSV 目录、zip 包或单个 JSON 文件中的每条记录，
+// zip 包是 OSV 官方按生态提供的 all.zip 格式
+func walkOSVSource(path string, fn func(name string, data []byte) error) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return fn(p, data)
+		})
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() || !strings.HasSuffix(zf.Name, ".json") {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := fn(path+"!/"+zf.Name, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return fn(path, data)
+}
+
+// parseOSVRecord 解析一条 OSV 记录，已撤回的记录返回 nil
+func parseOSVRecord(name string, data []byte) (*osvRecord, error) {
+	var rec osvRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("解析 OSV 记录 %s 失败: %w", name, err)
+	}
+	if rec.ID == "" || rec.Withdrawn != "" {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+// osvEcosystemBase 返回 OSV 生态名称中冒号之前的部分，例如 Debian:12 返回 Debian
+func osvEcosystemBase(ecosystem string) string {
+	base, _, _ := strings.Cut(ecosystem, ":")
+	return base
+}
+
+// osvPackageKey 是按生态和包名索引漏洞记录时使用的键，PyPI 的包名按 PEP 503 规范化
+func osvPackageKey(ecosystem, name string) string {
+	base := osvEcosystemBase(ecosystem)
+	if base == "PyPI" {
+		name = NormalizePythonName(name)
+	}
+	return base + "\x00" + name
+}
+
+// osvReleaseMatches 判断 OSV 记录的生态（例如 Ubuntu:22.04:LTS）是否适用于镜像的发行版版本。
+// 没有版本部分的生态适用于所有版本
+func osvReleaseMatches(ecosystem, release string) bool {
+	_, rest, ok := strings.Cut(ecosystem, ":")
+	if !ok || rest == "" {
+		return true
+	}
+	if release == "" {
+		return false
+	}
+	if rest == release {
+		return true
+	}
+	for _, part := range strings.Split(rest, ":") {
+		if part == release {
+			return true
+		}
+	}
+	// SUSE 的生态名称形如 SUSE:Linux Enterprise Server 15 SP5
+	return strings.HasSuffix(rest, " "+release)
+}
+
+// osvSeverityOf 返回记录的严重程度和 CVSS v3 分数：优先使用生态或数据库给出的等级，其次根据 CVSS v3 向量计算
+func osvSeverityOf(rec *osvRecord, affected *osvAffected) (string, float64) {
+	var score float64
+	for _, list := range [][]osvSeverity{affected.Severity, rec.Severity} {
+		for _, s := range list {
+			if strings.HasPrefix(s.Type, "CVSS_V3") {
+				if v, ok := cvss3BaseScore(s.Score); ok && v > score {
+					score = v
+				}
+			}
+		}
+	}
+	for _, m := range []map[string]any{affected.EcosystemSpecific, affected.DatabaseSpecific, rec.DatabaseSpecific} {
+		for _, key := range []string{"severity", "urgency"} {
+			if s, ok := m[key].(string); ok {
+				if sev := normalizeSeverity(s); sev != "" {
+					return sev, score
+				}
+			}
+		}
+	}
+	for _, list := range [][]osvSeverity{affected.Severity, rec.Severity} {
+		for _, s := range list {
+			// Ubuntu 的记录在 severity 中直接给出等级
+			if s.Type == "Ubuntu" {
+				if sev := normalizeSeverity(s.Score); sev != "" {
+					return sev, score
+				}
+			}
+		}
+	}
+	if score > 0 {
+		return cvssSeverity(score), score
+	}
+	return SeverityUnknown, 0
+}
+
+// normalizeSeverity 将各数据源的严重程度统一为 critical、high、medium、low，无法识别时返回空字符串
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "critical":
+		return SeverityCritical
+	case "high", "important":
+		return SeverityHigh
+	case "medium", "moderate":
+		return SeverityMedium
+	case "low", "negligible", "unimportant":
+		return SeverityLow
+	}
+	return ""
+}
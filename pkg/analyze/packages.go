@@ -0,0 +1,86 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Package 是各生态的第三方组件统一后的记录
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	// Location 为记录该组件的文件（gemspec、installed.json、二进制或 deps.json）在镜像内的路径
+	Location string `json:"location"`
+	License  string `json:"license,omitempty"`
+}
+
+const (
+	EcosystemRubyGems = "rubygems"
+	EcosystemComposer = "composer"
+	EcosystemCargo    = "cargo"
+	EcosystemNuGet    = "nuget"
+)
+
+// packageAnalyzer 是一个生态的包分析器
+type packageAnalyzer interface {
+	// match 根据文件名和属性判断文件是否需要交给该分析器
+	match(path string, info os.FileInfo) bool
+	// analyze 解析文件，返回其中记录的包
+	analyze(root, path string) []Package
+}
+
+// packageAnalyzers 是所有生态的分析器
+var packageAnalyzers = []packageAnalyzer{
+	rubyGemAnalyzer{},
+	composerAnalyzer{},
+	cargoAuditableAnalyzer{},
+	dotnetDepsAnalyzer{},
+}
+
+// ListPackages 遍历一次文件系统，由各生态的分析器解析匹配的文件，汇总成统一的包列表
+func ListPackages(root string, workers int) []Package {
+	type task struct {
+		analyzer packageAnalyzer
+		path     string
+	}
+	var tasks []task
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		for _, a := range packageAnalyzers {
+			if a.match(path, info) {
+				tasks = append(tasks, task{analyzer: a, path: path})
+			}
+		}
+		return nil
+	})
+
+	var (
+		mu   sync.Mutex
+		pkgs = []Package{}
+	)
+	runParallel(len(tasks), workers, func(i int) {
+		found := tasks[i].analyzer.analyze(root, tasks[i].path)
+		mu.Lock()
+		pkgs = append(pkgs, found...)
+		mu.Unlock()
+	})
+	sort.Slice(pkgs, func(i, j int) bool {
+		a, b := pkgs[i], pkgs[j]
+		if a.Ecosystem != b.Ecosystem {
+			return a.Ecosystem < b.Ecosystem
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Location < b.Location
+	})
+	return pkgs
+}
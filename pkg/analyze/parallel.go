@@ -0,0 +1,59 @@
+package analyze
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// defaultWorkers 返回默认的并发度：CPU 核数，最多 8 个，避免大镜像时打满磁盘 IO
+func defaultWorkers() int {
+	n := runtime.NumCPU()
+	if n > 8 {
+		n = 8
+	}
+	return n
+}
+
+// runParallel 以最多 workers 个 goroutine 并发执行 fn(0) ... fn(n-1)
+func runParallel(n, workers int, fn func(i int)) {
+	if workers <= 0 {
+		workers = defaultWorkers()
+	}
+	if workers > n {
+		workers = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				runTask(fn, i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// runTask 执行单个任务。任务解析的是镜像中不可信的文件，其中的 panic 只记录日志，
+// 不影响其他任务，也不会让整个进程退出（HTTP 服务的 Recovery 中间件捕获不到其他 goroutine 中的 panic）
+func runTask(fn func(i int), i int) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("分析任务异常",
+				logger.WithString("panic", fmt.Sprint(r)),
+				logger.WithString("stack", string(debug.Stack())))
+		}
+	}()
+	fn(i)
+}
@@ -0,0 +1,393 @@
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pep440Pattern 是 PEP 440 规定的宽松版本号格式（与 packaging 库一致）
+var pep440Pattern = regexp.MustCompile(`^\s*v?` +
+	`(?:(?P<epoch>[0-9]+)!)?` +
+	`(?P<release>[0-9]+(?:\.[0-9]+)*)` +
+	`(?:[-_.]?(?P<pre_l>alpha|a|beta|b|preview|pre|c|rc)[-_.]?(?P<pre_n>[0-9]+)?)?` +
+	`(?:-(?P<post_n1>[0-9]+)|[-_.]?(?P<post_l>post|rev|r)[-_.]?(?P<post_n2>[0-9]+)?)?` +
+	`(?:[-_.]?(?P<dev_l>dev)[-_.]?(?P<dev_n>[0-9]+)?)?` +
+	`(?:\+(?P<local>[a-z0-9]+(?:[-_.][a-z0-9]+)*))?\s*$`)
+
+// PEP440Version 表示一个解析后的 PEP 440 版本号
+type PEP440Version struct {
+	Epoch   int
+	Release []int
+	// Pre 为预发布标记，PreLabel 为空表示非预发布版本
+	PreLabel string
+	Pre      int
+	// Post 为 -1 表示没有 post 段
+	Post int
+	// Dev 为 -1 表示没有 dev 段
+	Dev   int
+	Local string
+}
+
+// ParsePEP440Version 按照 PEP 440 解析版本号
+func ParsePEP440Version(s string) (*PEP440Version, error) {
+	m := pep440Pattern.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return nil, fmt.Errorf("无效的 PEP 440 版本号: %q", s)
+	}
+	group := func(name string) string {
+		return m[pep440Pattern.SubexpIndex(name)]
+	}
+
+	v := &PEP440Version{Post: -1, Dev: -1}
+	if e := group("epoch"); e != "" {
+		v.Epoch, _ = strconv.Atoi(e)
+	}
+	for _, part := range strings.Split(group("release"), ".") {
+		n, _ := strconv.Atoi(part)
+		v.Release = append(v.Release, n)
+	}
+	if l := group("pre_l"); l != "" {
+		switch l {
+		case "alpha":
+			l = "a"
+		case "beta":
+			l = "b"
+		case "c", "pre", "preview":
+			l = "rc"
+		}
+		v.PreLabel = l
+		v.Pre, _ = strconv.Atoi(group("pre_n"))
+	}
+	if n := group("post_n1"); n != "" {
+		v.Post, _ = strconv.Atoi(n)
+	} else if group("post_l") != "" {
+		v.Post, _ = strconv.Atoi(group("post_n2"))
+	}
+	if group("dev_l") != "" {
+		v.Dev, _ = strconv.Atoi(group("dev_n"))
+	}
+	v.Local = strings.NewReplacer("-", ".", "_", ".").Replace(group("local"))
+	return v, nil
+}
+
+// IsPrerelease 判断是否为预发布或开发版本
+func (v *PEP440Version) IsPrerelease() bool {
+	return v.PreLabel != "" || v.Dev >= 0
+}
+
+// IsPostrelease 判断是否为 post 版本
+func (v *PEP440Version) IsPostrelease() bool {
+	return v.Post >= 0
+}
+
+// Public 返回去掉本地标签后的版本
+func (v *PEP440Version) Public() *PEP440Version {
+	p := *v
+	p.Local = ""
+	return &p
+}
+
+// BaseVersion 返回只包含 epoch 和 release 段的版本
+func (v *PEP440Version) BaseVersion() *PEP440Version {
+	return &PEP440Version{Epoch: v.Epoch, Release: v.Release, Post: -1, Dev: -1}
+}
+
+// String 返回规范化后的版本字符串
+func (v *PEP440Version) String() string {
+	var b strings.Builder
+	if v.Epoch != 0 {
+		fmt.Fprintf(&b, "%d!", v.Epoch)
+	}
+	for i, n := range v.Release {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.Itoa(n))
+	}
+	if v.PreLabel != "" {
+		fmt.Fprintf(&b, "%s%d", v.PreLabel, v.Pre)
+	}
+	if v.Post >= 0 {
+		fmt.Fprintf(&b, ".post%d", v.Post)
+	}
+	if v.Dev >= 0 {
+		fmt.Fprintf(&b, ".dev%d", v.Dev)
+	}
+	if v.Local != "" {
+		b.WriteString("+" + v.Local)
+	}
+	return b.String()
+}
+
+// Compare 比较两个版本，返回 -1、0 或 1
+func (v *PEP440Version) Compare(o *PEP440Version) int {
+	if c := compareInt(v.Epoch, o.Epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(v.Release, o.Release); c != 0 {
+		return c
+	}
+	if c := compareInt(v.preKey(), o.preKey()); c != 0 {
+		return c
+	}
+	if v.PreLabel != "" && v.PreLabel == o.PreLabel {
+		if c := compareInt(v.Pre, o.Pre); c != 0 {
+			return c
+		}
+	}
+	if c := compareInt(v.Post, o.Post); c != 0 {
+		return c
+	}
+	// 没有 dev 段的版本排在有 dev 段的版本之后
+	vDev, oDev := v.Dev, o.Dev
+	if vDev < 0 {
+		vDev = int(^uint(0) >> 1)
+	}
+	if oDev < 0 {
+		oDev = int(^uint(0) >> 1)
+	}
+	if c := compareInt(vDev, oDev); c != 0 {
+		return c
+	}
+	return compareLocal(v.Local, o.Local)
+}
+
+// preKey 返回预发布段的排序键：纯 dev 版本最小，其次 a < b < rc，最后是正式版本
+func (v *PEP440Version) preKey() int {
+	switch {
+	case v.PreLabel == "" && v.Post < 0 && v.Dev >= 0:
+		return -1
+	case v.PreLabel == "a":
+		return 1
+	case v.PreLabel == "b":
+		return 2
+	case v.PreLabel == "rc":
+		return 3
+	default:
+		return 4
+	}
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareRelease 比较 release 段，末尾的 0 不参与比较
+func compareRelease(a, b []int) int {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareInt(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareLocal 比较本地版本标签：数字段大于字母段，段数多的更大
+func compareLocal(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInt(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return 1
+		case bErr == nil:
+			return -1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+// PEP440Specifier 表示单个版本约束，例如 ">=1.0" 或 "==2.*"
+type PEP440Specifier struct {
+	Operator string
+	Version  string
+}
+
+// PEP440SpecifierSet 表示以逗号分隔的一组版本约束
+type PEP440SpecifierSet []PEP440Specifier
+
+var specifierOperators = []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"}
+
+// ParsePEP440SpecifierSet 解析形如 ">=1.0,<2" 的版本约束
+func ParsePEP440SpecifierSet(s string) (PEP440SpecifierSet, error) {
+	var set PEP440SpecifierSet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		spec, err := parsePEP440Specifier(part)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, spec)
+	}
+	return set, nil
+}
+
+func parsePEP440Specifier(s string) (PEP440Specifier, error) {
+	for _, op := range specifierOperators {
+		if strings.HasPrefix(s, op) {
+			ver := strings.TrimSpace(s[len(op):])
+			if ver == "" {
+				break
+			}
+			if op != "===" {
+				check := strings.TrimSuffix(ver, ".*")
+				if _, err := ParsePEP440Version(check); err != nil {
+					return PEP440Specifier{}, err
+				}
+			}
+			return PEP440Specifier{Operator: op, Version: ver}, nil
+		}
+	}
+	return PEP440Specifier{}, fmt.Errorf("无效的版本约束: %q", s)
+}
+
+// String 返回约束集合的字符串形式
+func (set PEP440SpecifierSet) String() string {
+	parts := make([]string, len(set))
+	for i, spec := range set {
+		parts[i] = spec.Operator + spec.Version
+	}
+	return strings.Join(parts, ",")
+}
+
+// Contains 判断版本是否满足所有约束（与 pip check 一致，允许预发布版本）。
+// 无法解析的版本只能按 === 做字面比较，含其他约束时返回错误
+func (set PEP440SpecifierSet) Contains(version string) (bool, error) {
+	v, err := ParsePEP440Version(version)
+	if err != nil {
+		for _, spec := range set {
+			if spec.Operator != "===" {
+				return false, err
+			}
+			if !strings.EqualFold(spec.Version, version) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	for _, spec := range set {
+		if !spec.contains(v, version) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (spec PEP440Specifier) contains(v *PEP440Version, raw string) bool {
+	if spec.Operator == "===" {
+		return strings.EqualFold(spec.Version, raw)
+	}
+
+	if strings.HasSuffix(spec.Version, ".*") {
+		prefix, _ := ParsePEP440Version(strings.TrimSuffix(spec.Version, ".*"))
+		match := v.Epoch == prefix.Epoch && releaseHasPrefix(v.Release, prefix.Release)
+		switch spec.Operator {
+		case "==":
+			return match
+		case "!=":
+			return !match
+		}
+		return false
+	}
+
+	sv, _ := ParsePEP440Version(spec.Version)
+	switch spec.Operator {
+	case "==":
+		// 约束中没有本地标签时，忽略候选版本的本地标签
+		if sv.Local == "" {
+			return v.Public().Compare(sv) == 0
+		}
+		return v.Compare(sv) == 0
+	case "!=":
+		if sv.Local == "" {
+			return v.Public().Compare(sv) != 0
+		}
+		return v.Compare(sv) != 0
+	case "~=":
+		if len(sv.Release) < 2 {
+			return false
+		}
+		prefix := sv.Release[:len(sv.Release)-1]
+		return v.Public().Compare(sv) >= 0 && v.Epoch == sv.Epoch && releaseHasPrefix(v.Release, prefix)
+	case "<=":
+		return v.Public().Compare(sv) <= 0
+	case ">=":
+		return v.Public().Compare(sv) >= 0
+	case "<":
+		if v.Compare(sv) >= 0 {
+			return false
+		}
+		// <V 不匹配 V 本身的预发布版本，除非 V 也是预发布版本
+		if !sv.IsPrerelease() && v.IsPrerelease() && v.BaseVersion().Compare(sv.BaseVersion()) == 0 {
+			return false
+		}
+		return true
+	case ">":
+		if v.Compare(sv) <= 0 {
+			return false
+		}
+		// >V 不匹配 V 的 post 版本和本地版本，除非 V 也是 post 版本
+		if !sv.IsPostrelease() && v.IsPostrelease() && v.BaseVersion().Compare(sv.BaseVersion()) == 0 {
+			return false
+		}
+		if v.Local != "" && v.Public().Compare(sv) == 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// releaseHasPrefix 判断 release 段是否以 prefix 开头（不足的部分按 0 补齐）
+func releaseHasPrefix(release, prefix []int) bool {
+	for i, n := range prefix {
+		var x int
+		if i < len(release) {
+			x = release[i]
+		}
+		if x != n {
+			return false
+		}
+	}
+	return true
+}
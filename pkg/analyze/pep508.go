@@ -0,0 +1,318 @@
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PythonRequirement 表示一条 PEP 508 依赖声明
+type PythonRequirement struct {
+	Name      string
+	Extras    []string
+	Specifier PEP440SpecifierSet
+	URL       string
+	Marker    *MarkerExpr
+	Raw       string
+}
+
+var (
+	requirementNamePattern = regexp.MustCompile(`^\s*([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?)\s*`)
+	pythonNameSeparators   = regexp.MustCompile(`[-_.]+`)
+)
+
+// NormalizePythonName 按照 PEP 503 规范化包名
+func NormalizePythonName(name string) string {
+	return strings.ToLower(pythonNameSeparators.ReplaceAllString(strings.TrimSpace(name), "-"))
+}
+
+// ParsePythonRequirement 解析形如 `name[extra] (>=1.0) ; python_version < "3.8"` 的依赖声明
+func ParsePythonRequirement(s string) (*PythonRequirement, error) {
+	req := &PythonRequirement{Raw: strings.TrimSpace(s)}
+
+	body, marker := s, ""
+	if i := strings.Index(s, ";"); i >= 0 {
+		body, marker = s[:i], s[i+1:]
+	}
+
+	m := requirementNamePattern.FindStringSubmatch(body)
+	if m == nil {
+		return nil, fmt.Errorf("无效的依赖声明: %q", s)
+	}
+	req.Name = m[1]
+	rest := body[len(m[0]):]
+
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("依赖声明的 extras 未闭合: %q", s)
+		}
+		for _, e := range strings.Split(rest[1:end], ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				req.Extras = append(req.Extras, e)
+			}
+		}
+		rest = rest[end+1:]
+	}
+
+	rest = strings.TrimSpace(rest)
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		req.URL = strings.TrimSpace(rest[1:])
+	case rest != "":
+		rest = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(rest, "("), ")"))
+		spec, err := ParsePEP440SpecifierSet(rest)
+		if err != nil {
+			return nil, err
+		}
+		req.Specifier = spec
+	}
+
+	if strings.TrimSpace(marker) != "" {
+		expr, err := ParseMarker(marker)
+		if err != nil {
+			return nil, err
+		}
+		req.Marker = expr
+	}
+	return req, nil
+}
+
+// MarkerEnv 是求值环境标记时使用的变量表
+type MarkerEnv map[string]string
+
+// MarkerExpr 是环境标记表达式的语法树节点
+type MarkerExpr struct {
+	// Op 为 "and"、"or" 时使用 Left/Right，否则为比较运算
+	Op          string
+	Left, Right *MarkerExpr
+	LHS, RHS    markerOperand
+}
+
+type markerOperand struct {
+	Value      string
+	IsVariable bool
+}
+
+// markerVariables 是 PEP 508 定义的环境标记变量
+var markerVariables = map[string]bool{
+	"python_version":                 true,
+	"python_full_version":            true,
+	"os_name":                        true,
+	"sys_platform":                   true,
+	"platform_release":               true,
+	"platform_system":                true,
+	"platform_version":               true,
+	"platform_machine":               true,
+	"platform_python_implementation": true,
+	"implementation_name":            true,
+	"implementation_version":         true,
+	"extra":                          true,
+}
+
+// markerVersionVariables 是按版本号比较的变量
+var markerVersionVariables = map[string]bool{
+	"python_version":         true,
+	"python_full_version":    true,
+	"implementation_version": true,
+	"platform_release":       true,
+}
+
+var markerTokenPattern = regexp.MustCompile(`^\s*(?:(\()|(\))|("[^"]*"|'[^']*')|(===|==|!=|<=|>=|~=|<|>)|(not\s+in\b|in\b|and\b|or\b)|([A-Za-z_][A-Za-z0-9_.]*))`)
+
+type markerParser struct {
+	tokens []string
+	pos    int
+}
+
+// ParseMarker 解析 PEP 508 环境标记表达式
+func ParseMarker(s string) (*MarkerExpr, error) {
+	var tokens []string
+	rest := s
+	for strings.TrimSpace(rest) != "" {
+		m := markerTokenPattern.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("无效的环境标记: %q", s)
+		}
+		tokens = append(tokens, strings.Join(strings.Fields(strings.TrimSpace(m[0])), " "))
+		rest = rest[len(m[0]):]
+	}
+	p := &markerParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("无效的环境标记 %q: %w", s, err)
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("无效的环境标记 %q: 多余的内容 %q", s, p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+func (p *markerParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *markerParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *markerParser) parseOr() (*MarkerExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &MarkerExpr{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *markerParser) parseAnd() (*MarkerExpr, error) {
+	left, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.next()
+		right, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		left = &MarkerExpr{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *markerParser) parseAtom() (*MarkerExpr, error) {
+	if p.peek() == "(" {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("括号未闭合")
+		}
+		return expr, nil
+	}
+
+	lhs, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch op {
+	case "===", "==", "!=", "<=", ">=", "~=", "<", ">", "in", "not in":
+	default:
+		return nil, fmt.Errorf("无效的比较运算符 %q", op)
+	}
+	rhs, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &MarkerExpr{Op: op, LHS: lhs, RHS: rhs}, nil
+}
+
+func (p *markerParser) parseOperand() (markerOperand, error) {
+	t := p.next()
+	switch {
+	case len(t) >= 2 && (t[0] == '"' || t[0] == '\''):
+		return markerOperand{Value: t[1 : len(t)-1]}, nil
+	case markerVariables[t]:
+		return markerOperand{Value: t, IsVariable: true}, nil
+	case t == "os.name", t == "sys.platform", t == "platform.version", t == "platform.machine",
+		t == "platform.python_implementation", t == "python_implementation":
+		// 兼容 PEP 345 中的旧式变量名
+		name := strings.ReplaceAll(t, ".", "_")
+		if t == "python_implementation" {
+			name = "platform_python_implementation"
+		}
+		return markerOperand{Value: name, IsVariable: true}, nil
+	}
+	return markerOperand{}, fmt.Errorf("无效的操作数 %q", t)
+}
+
+// Evaluate 在给定环境中对标记表达式求值。按版本比较的变量遇到无法解析的版本号时返回错误，
+// 不退回到字符串比较（"10.0" < "9.0"）；and/or 的结果已由另一侧决定时忽略该错误
+func (e *MarkerExpr) Evaluate(env MarkerEnv) (bool, error) {
+	if e == nil {
+		return true, nil
+	}
+	switch e.Op {
+	case "and", "or":
+		l, lerr := e.Left.Evaluate(env)
+		if lerr == nil && l == (e.Op == "or") {
+			return l, nil
+		}
+		r, rerr := e.Right.Evaluate(env)
+		if rerr == nil && r == (e.Op == "or") {
+			return r, nil
+		}
+		if lerr != nil {
+			return false, lerr
+		}
+		return r, rerr
+	}
+
+	resolve := func(o markerOperand) string {
+		if o.IsVariable {
+			return env[o.Value]
+		}
+		return o.Value
+	}
+	lhs, rhs := resolve(e.LHS), resolve(e.RHS)
+
+	// extra 的比较需要先规范化名称
+	if (e.LHS.IsVariable && e.LHS.Value == "extra") || (e.RHS.IsVariable && e.RHS.Value == "extra") {
+		lhs, rhs = NormalizePythonName(lhs), NormalizePythonName(rhs)
+	}
+
+	switch e.Op {
+	case "in":
+		return strings.Contains(rhs, lhs), nil
+	case "not in":
+		return !strings.Contains(rhs, lhs), nil
+	}
+
+	versionVar := (e.LHS.IsVariable && markerVersionVariables[e.LHS.Value]) ||
+		(e.RHS.IsVariable && markerVersionVariables[e.RHS.Value])
+	if versionVar || e.Op == "~=" || e.Op == "===" {
+		spec, err := parsePEP440Specifier(e.Op + rhs)
+		if err == nil {
+			var v *PEP440Version
+			if v, err = ParsePEP440Version(lhs); err == nil {
+				return spec.contains(v, lhs), nil
+			}
+		}
+		if versionVar || e.Op == "~=" {
+			return false, fmt.Errorf("无法按版本比较 %q %s %q: %w", lhs, e.Op, rhs, err)
+		}
+	}
+
+	c := strings.Compare(lhs, rhs)
+	switch e.Op {
+	case "==", "===":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return false, nil
+}
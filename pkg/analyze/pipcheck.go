@@ -0,0 +1,216 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// PythonDependencyCheck 是单个 Python 环境的依赖一致性检查结果（类似 pip check）
+type PythonDependencyCheck struct {
+	Environment   string                   `json:"environment"`
+	SitePackages  []string                 `json:"site_packages"`
+	PythonVersion string                   `json:"python_version"`
+	Packages      int                      `json:"packages"`
+	Missing       []PythonRequirementIssue `json:"missing"`
+	Conflicts     []PythonRequirementIssue `json:"conflicts"`
+	// Unevaluable 为因版本号无法按 PEP 440 解析而无法判断的依赖
+	Unevaluable []PythonRequirementIssue `json:"unevaluable"`
+	// WheelIssues 为平台标签与镜像的 C 库或架构不匹配的包
+	WheelIssues []PythonWheelIssue `json:"wheel_issues"`
+}
+
+// PythonRequirementIssue 描述一条未被满足的依赖
+type PythonRequirementIssue struct {
+	Package          string `json:"package"`
+	Version          string `json:"version"`
+	Requirement      string `json:"requirement"`
+	InstalledVersion string `json:"installed_version,omitempty"`
+	Message          string `json:"message"`
+}
+
+// archToPlatformMachine 将 OCI 架构名映射为 Python 的 platform.machine()
+var archToPlatformMachine = map[string]string{
+	"amd64":    "x86_64",
+	"386":      "i686",
+	"arm64":    "aarch64",
+	"arm":      "armv7l",
+	"ppc64le":  "ppc64le",
+	"s390x":    "s390x",
+	"riscv64":  "riscv64",
+	"mips64le": "mips64",
+}
+
+// pythonMarkerEnv 构造用于求值环境标记的变量表。
+// 静态分析拿不到补丁版本号，python_full_version 以 X.Y.0 近似
+func pythonMarkerEnv(pythonVersion, arch string) MarkerEnv {
+	machine := archToPlatformMachine[arch]
+	if machine == "" {
+		machine = arch
+	}
+	fullVersion := pythonVersion
+	if fullVersion != "" {
+		fullVersion += ".0"
+	}
+	return MarkerEnv{
+		"python_version":                 pythonVersion,
+		"python_full_version":            fullVersion,
+		"implementation_version":         fullVersion,
+		"os_name":                        "posix",
+		"sys_platform":                   "linux",
+		"platform_system":                "Linux",
+		"platform_machine":               machine,
+		"platform_python_implementation": "CPython",
+		"implementation_name":            "cpython",
+		"extra":                          "",
+	}
+}
+
+// CheckPythonDependencies 对镜像中的每个 Python 环境做静态的 pip check，
+// 并检查 wheel 的平台标签与镜像的 C 库和架构是否匹配。libc 为 nil 时只检查架构
+func CheckPythonDependencies(root string, envs []PythonEnvironment, arch string, libc *LibcInfo) []PythonDependencyCheck {
+	byPrefix := make(map[string]PythonEnvironment, len(envs))
+	for _, env := range envs {
+		byPrefix[env.Prefix] = env
+	}
+
+	var results []PythonDependencyCheck
+	for _, env := range envs {
+		sites := env.SitePackages
+		if env.SystemSitePackages {
+			if base, ok := byPrefix[venvBasePrefix(env)]; ok {
+				sites = append(append([]string{}, sites...), base.SitePackages...)
+			}
+		}
+
+		// 与 sys.path 一致，先出现的目录中的同名包优先
+		var dists []pythonDist
+		seen := make(map[string]bool)
+		for _, sp := range sites {
+			for _, d := range listPythonDists(root, sp) {
+				key := NormalizePythonName(d.Name)
+				if !seen[key] {
+					seen[key] = true
+					dists = append(dists, d)
+				}
+			}
+		}
+		if len(dists) == 0 {
+			continue
+		}
+
+		pyVersion := majorMinor(env.Version)
+		result := checkPythonDists(dists, pythonMarkerEnv(pyVersion, arch))
+		result.Environment = env.Prefix
+		result.SitePackages = sites
+		result.PythonVersion = pyVersion
+		result.WheelIssues = checkPythonWheels(root, dists, libc, archToPlatformMachine[arch])
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkPythonWheels 检查通过 wheel 安装的包的平台标签
+func checkPythonWheels(root string, dists []pythonDist, libc *LibcInfo, machine string) []PythonWheelIssue {
+	issues := []PythonWheelIssue{}
+	for _, d := range dists {
+		tags := readWheelTags(root, d.MetaDir)
+		if len(tags) == 0 {
+			continue
+		}
+		if reason := checkWheelPlatform(tags, libc, machine); reason != "" {
+			issues = append(issues, PythonWheelIssue{Package: d.Name, Version: d.Version, Tags: tags, Reason: reason})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		return NormalizePythonName(issues[i].Package) < NormalizePythonName(issues[j].Package)
+	})
+	return issues
+}
+
+// checkPythonDists 检查一组发行包的 Requires-Dist 是否都被满足
+func checkPythonDists(dists []pythonDist, env MarkerEnv) PythonDependencyCheck {
+	installed := make(map[string]pythonDist, len(dists))
+	for _, d := range dists {
+		installed[NormalizePythonName(d.Name)] = d
+	}
+
+	result := PythonDependencyCheck{
+		Packages:    len(installed),
+		Missing:     []PythonRequirementIssue{},
+		Conflicts:   []PythonRequirementIssue{},
+		Unevaluable: []PythonRequirementIssue{},
+	}
+	for _, d := range dists {
+		for _, raw := range d.Requires {
+			req, err := ParsePythonRequirement(raw)
+			if err != nil {
+				logger.Debug("解析依赖声明失败",
+					logger.WithString("package", d.Name),
+					logger.WithString("requirement", raw),
+					logger.WithError(err))
+				continue
+			}
+			match, err := req.Marker.Evaluate(env)
+			if err != nil {
+				result.Unevaluable = append(result.Unevaluable, PythonRequirementIssue{
+					Package:     d.Name,
+					Version:     d.Version,
+					Requirement: req.Raw,
+					Message:     fmt.Sprintf("%s %s requirement %s: cannot evaluate marker: %v", d.Name, d.Version, req.Raw, err),
+				})
+				continue
+			}
+			if !match {
+				continue
+			}
+
+			dep, ok := installed[NormalizePythonName(req.Name)]
+			if !ok {
+				result.Missing = append(result.Missing, PythonRequirementIssue{
+					Package:     d.Name,
+					Version:     d.Version,
+					Requirement: req.Raw,
+					Message:     fmt.Sprintf("%s %s requires %s, which is not installed.", d.Name, d.Version, req.Name),
+				})
+				continue
+			}
+			if len(req.Specifier) == 0 {
+				continue
+			}
+			satisfied, err := req.Specifier.Contains(dep.Version)
+			if err != nil {
+				result.Unevaluable = append(result.Unevaluable, PythonRequirementIssue{
+					Package:          d.Name,
+					Version:          d.Version,
+					Requirement:      req.Raw,
+					InstalledVersion: dep.Version,
+					Message: fmt.Sprintf("%s %s has requirement %s%s, but %s %s is not a valid PEP 440 version.",
+						d.Name, d.Version, req.Name, req.Specifier, dep.Name, dep.Version),
+				})
+				continue
+			}
+			if !satisfied {
+				result.Conflicts = append(result.Conflicts, PythonRequirementIssue{
+					Package:          d.Name,
+					Version:          d.Version,
+					Requirement:      req.Raw,
+					InstalledVersion: dep.Version,
+					Message: fmt.Sprintf("%s %s has requirement %s%s, but you have %s %s.",
+						d.Name, d.Version, req.Name, req.Specifier, dep.Name, dep.Version),
+				})
+			}
+		}
+	}
+
+	sortIssues := func(issues []PythonRequirementIssue) {
+		sort.SliceStable(issues, func(i, j int) bool {
+			return NormalizePythonName(issues[i].Package) < NormalizePythonName(issues[j].Package)
+		})
+	}
+	sortIssues(result.Missing)
+	sortIssues(result.Conflicts)
+	sortIssues(result.Unevaluable)
+	return result
+}
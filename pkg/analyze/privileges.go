@@ -0,0 +1,356 @@
+package analyze
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// PrivilegeReport 是带文件能力或 setuid/setgid 位的文件清单，用于评估容器需要的安全上下文
+type PrivilegeReport struct {
+	Files []PrivilegedFile `json:"files"`
+	// Capabilities 为所有文件能力的并集
+	Capabilities []string `json:"capabilities"`
+	// ExtraCapabilities 为不在容器运行时默认能力集中的能力，需要在 securityContext.capabilities.add 中添加，
+	// 否则带生效位的文件执行时会失败（EPERM）
+	ExtraCapabilities []string `json:"extra_capabilities"`
+}
+
+// PrivilegedFile 是一个在执行时提升权限的文件
+type PrivilegedFile struct {
+	Path string `json:"path"`
+	// Mode 为八进制权限，包括 setuid、setgid 和 sticky 位
+	Mode   string `json:"mode"`
+	Setuid bool   `json:"setuid"`
+	Setgid bool   `json:"setgid"`
+	// Owner 为 tar 条目中记录的属主，无法确定时省略
+	Owner     *utils.Owner `json:"owner,omitempty"`
+	OwnerName string       `json:"owner_name,omitempty"`
+	GroupName string       `json:"group_name,omitempty"`
+	// Capabilities 为 getcap 格式的文件能力，例如 cap_net_raw=ep
+	Capabilities string   `json:"capabilities,omitempty"`
+	Permitted    []string `json:"permitted,omitempty"`
+	Inheritable  []string `json:"inheritable,omitempty"`
+	// Effective 表示执行时自动启用 Permitted 中的能力，否则需要程序自行启用
+	Effective bool `json:"effective,omitempty"`
+	// Privileges 为执行该文件后获得的权限说明
+	Privileges []string `json:"privileges"`
+	Severity   string   `json:"severity"`
+}
+
+// capabilityNames 是 Linux 能力的名称，下标为能力编号
+var capabilityNames = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner", "cap_fsetid", "cap_kill", "cap_setgid", "cap_setuid",
+	"cap_setpcap", "cap_linux_immutable", "cap_net_bind_service", "cap_net_broadcast", "cap_net_admin", "cap_net_raw", "cap_ipc_lock", "cap_ipc_owner",
+	"cap_sys_module", "cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace", "cap_sys_pacct", "cap_sys_admin", "cap_sys_boot", "cap_sys_nice",
+	"cap_sys_resource", "cap_sys_time", "cap_sys_tty_config", "cap_mknod", "cap_lease", "cap_audit_write", "cap_audit_control", "cap_setfcap",
+	"cap_mac_override", "cap_mac_admin", "cap_syslog", "cap_wake_alarm", "cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// capabilityDescriptions 是常见能力的说明
+var capabilityDescriptions = map[string]string{
+	"cap_chown":            "修改任意文件的属主",
+	"cap_dac_override":     "绕过文件读写执行权限检查",
+	"cap_dac_read_search":  "绕过文件读取和目录搜索权限检查，可读取任意文件",
+	"cap_fowner":           "绕过要求进程为文件属主的检查",
+	"cap_fsetid":           "修改文件时保留 setuid/setgid 位",
+	"cap_kill":             "向任意进程发送信号",
+	"cap_setgid":           "切换为任意 GID",
+	"cap_setuid":           "切换为任意 UID，等同于 root",
+	"cap_setpcap":          "修改进程的能力集",
+	"cap_net_bind_service": "绑定 1024 以下的端口",
+	"cap_net_admin":        "配置网络接口、路由和防火墙",
+	"cap_net_raw":          "使用原始套接字，可伪造数据包和嗅探网络",
+	"cap_ipc_lock":         "锁定内存",
+	"cap_sys_module":       "加载内核模块",
+	"cap_sys_rawio":        "直接访问设备和 I/O 端口",
+	"cap_sys_chroot":       "使用 chroot",
+	"cap_sys_ptrace":       "跟踪和注入任意进程",
+	"cap_sys_admin":        "执行挂载等大量系统管理操作，接近 root",
+	"cap_sys_boot":         "重启系统",
+	"cap_sys_nice":         "提高进程优先级",
+	"cap_sys_resource":     "突破资源限制",
+	"cap_sys_time":         "修改系统时钟",
+	"cap_mknod":            "创建设备文件",
+	"cap_audit_write":      "写入审计日志",
+	"cap_setfcap":          "为文件设置能力",
+	"cap_syslog":           "读取和配置内核日志",
+	"cap_perfmon":          "使用性能监控接口",
+	"cap_bpf":              "加载 BPF 程序",
+}
+
+// dangerousCapabilities 是可以直接或间接获得 root 权限、突破容器隔离的能力
+var dangerousCapabilities = []string{
+	"cap_dac_override", "cap_dac_read_search", "cap_fowner", "cap_setuid", "cap_setgid", "cap_setpcap", "cap_setfcap", "cap_chown",
+	"cap_net_admin", "cap_sys_module", "cap_sys_rawio", "cap_sys_ptrace", "cap_sys_admin", "cap_sys_boot", "cap_mac_override", "cap_mac_admin", "cap_bpf",
+}
+
+// defaultContainerCapabilities 是 Docker 和 containerd 默认保留的能力
+var defaultContainerCapabilities = []string{
+	"cap_chown", "cap_dac_override", "cap_fowner", "cap_fsetid", "cap_kill", "cap_setgid", "cap_setuid", "cap_setpcap",
+	"cap_net_bind_service", "cap_net_raw", "cap_sys_chroot", "cap_mknod", "cap_audit_write", "cap_setfcap",
+}
+
+const (
+	vfsCapRevisionMask   = 0xFF000000
+	vfsCapRevision1      = 0x01000000
+	vfsCapRevision2      = 0x02000000
+	vfsCapRevision3      = 0x03000000
+	vfsCapFlagsEffective = 0x000001
+)
+
+// fileCapabilities 是 security.capability 扩展属性解析后的内容
+type fileCapabilities struct {
+	permitted   []string
+	inheritable []string
+	effective   bool
+	// rootID 为 v3 格式中用户命名空间的根 UID
+	rootID uint32
+}
+
+// parseFileCapabilities 解析 security.capability 扩展属性（struct vfs_cap_data，小端序）
+func parseFileCapabilities(data []byte) (*fileCapabilities, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("security.capability 长度 %d 不正确", len(data))
+	}
+	magic := binary.LittleEndian.Uint32(data)
+	var words int
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision1:
+		words = 1
+	case vfsCapRevision2, vfsCapRevision3:
+		words = 2
+	default:
+		return nil, fmt.Errorf("未知的 security.capability 版本 %#x", magic&vfsCapRevisionMask)
+	}
+	if len(data) < 4+8*words {
+		return nil, fmt.Errorf("security.capability 长度 %d 不正确", len(data))
+	}
+	caps := &fileCapabilities{effective: magic&vfsCapFlagsEffective != 0}
+	for w := 0; w < words; w++ {
+		permitted := binary.LittleEndian.Uint32(data[4+8*w:])
+		inheritable := binary.LittleEndian.Uint32(data[8+8*w:])
+		for bit := 0; bit < 32; bit++ {
+			if permitted&(1<<bit) != 0 {
+				caps.permitted = append(caps.permitted, capabilityName(32*w+bit))
+			}
+			if inheritable&(1<<bit) != 0 {
+				caps.inheritable = append(caps.inheritable, capabilityName(32*w+bit))
+			}
+		}
+	}
+	if magic&vfsCapRevisionMask == vfsCapRevision3 && len(data) >= 24 {
+		caps.rootID = binary.LittleEndian.Uint32(data[20:])
+	}
+	return caps, nil
+}
+
+func capabilityName(n int) string {
+	if n < len(capabilityNames) {
+		return capabilityNames[n]
+	}
+	return fmt.Sprintf("cap_%d", n)
+}
+
+// String 按 getcap 的格式输出，例如 cap_net_admin,cap_net_raw=ep
+func (c *fileCapabilities) String() string {
+	var parts []string
+	if len(c.permitted) > 0 {
+		// getcap 按 e、i、p 的顺序输出标志
+		effective := ""
+		if c.effective {
+			effective = "e"
+		}
+		both := intersectStrings(c.permitted, c.inheritable)
+		if len(both) == len(c.permitted) && len(both) == len(c.inheritable) {
+			return strings.Join(c.permitted, ",") + "=" + effective + "ip"
+		}
+		parts = append(parts, strings.Join(c.permitted, ",")+"="+effective+"p")
+	}
+	if len(c.inheritable) > 0 {
+		parts = append(parts, strings.Join(c.inheritable, ",")+"=i")
+	}
+	return strings.Join(parts, " ")
+}
+
+func intersectStrings(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if containsString(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ListPrivilegedFiles 列出带文件能力（解压时记录的 security.capability 扩展属性）或 setuid/setgid 位的文件，
+// 并说明执行后获得的权限
+func ListPrivilegedFiles(root string, extract *utils.ExtractInfo) *PrivilegeReport {
+	report := &PrivilegeReport{Files: []PrivilegedFile{}, Capabilities: []string{}, ExtraCapabilities: []string{}}
+
+	users := make(map[int]string)
+	for _, e := range readPasswd(root) {
+		if _, ok := users[e.UID]; !ok {
+			users[e.UID] = e.Name
+		}
+	}
+	groups := make(map[int]string)
+	for _, g := range readGroup(root) {
+		if _, ok := groups[g.GID]; !ok {
+			groups[g.GID] = g.Name
+		}
+	}
+
+	// 解压时不保留 setuid/setgid 位，按 tar 条目中记录的模式查找；只保留最终文件系统中仍然存在的普通文件
+	candidates := make(map[string]os.FileMode)
+	if extract != nil {
+		for p, mode := range extract.Modes {
+			if !mode.IsRegular() || mode&(os.ModeSetuid|os.ModeSetgid) == 0 {
+				continue
+			}
+			if info, err := os.Lstat(rootPath(root, p)); err == nil && info.Mode().IsRegular() {
+				candidates[p] = mode
+			}
+		}
+		for p, attrs := range extract.Xattrs {
+			if _, ok := attrs["security.capability"]; !ok {
+				continue
+			}
+			if _, ok := candidates[p]; ok {
+				continue
+			}
+			info, err := os.Lstat(rootPath(root, p))
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			// 报告 tar 条目中的权限位，磁盘上的权限在解压时被调整过
+			candidates[p] = info.Mode()
+			if mode, ok := extract.FileMode(p); ok {
+				candidates[p] = mode
+			}
+		}
+	}
+
+	for p, mode := range candidates {
+		f := PrivilegedFile{
+			Path:     p,
+			Mode:     fmt.Sprintf("%04o", unixPermBits(mode)),
+			Setuid:   mode&os.ModeSetuid != 0,
+			Setgid:   mode&os.ModeSetgid != 0,
+			Severity: SeverityLow,
+		}
+		raise := func(severity string) {
+			if severityRank(severity) < severityRank(f.Severity) {
+				f.Severity = severity
+			}
+		}
+		if owner, ok := extract.FileOwner(p); ok {
+			f.Owner = &owner
+			f.OwnerName, f.GroupName = users[owner.UID], groups[owner.GID]
+		}
+
+		if f.Setuid {
+			switch {
+			case f.Owner == nil:
+				f.Privileges = append(f.Privileges, "以文件属主的身份运行（属主未知）")
+				raise(SeverityMedium)
+			case f.Owner.UID == 0:
+				f.Privileges = append(f.Privileges, "以 root (UID 0) 的身份运行，获得全部能力")
+				raise(SeverityHigh)
+			default:
+				f.Privileges = append(f.Privileges, fmt.Sprintf("以用户 %s 的身份运行", describeID(f.OwnerName, f.Owner.UID, "UID")))
+				raise(SeverityMedium)
+			}
+		}
+		if f.Setgid {
+			if f.Owner == nil {
+				f.Privileges = append(f.Privileges, "以文件属组的身份运行（属组未知）")
+			} else {
+				f.Privileges = append(f.Privileges, fmt.Sprintf("以组 %s 的身份运行", describeID(f.GroupName, f.Owner.GID, "GID")))
+				// shadow 组可以读取密码哈希
+				if f.Owner.GID == 0 || f.GroupName == "shadow" {
+					raise(SeverityMedium)
+				}
+			}
+		}
+
+		if value, ok := extract.Xattr(p, "security.capability"); ok {
+			caps, err := parseFileCapabilities(value)
+			if err != nil {
+				f.Privileges = append(f.Privileges, err.Error())
+				raise(SeverityMedium)
+			} else {
+				f.Capabilities = caps.String()
+				f.Permitted, f.Inheritable, f.Effective = caps.permitted, caps.inheritable, caps.effective
+				for _, c := range caps.permitted {
+					desc := "执行时获得 " + c
+					if d, ok := capabilityDescriptions[c]; ok {
+						desc += "（" + d + "）"
+					}
+					if !caps.effective {
+						desc += "，需要程序自行启用"
+					}
+					f.Privileges = append(f.Privileges, desc)
+					if containsString(dangerousCapabilities, c) {
+						raise(SeverityHigh)
+					} else if c != "cap_net_bind_service" {
+						raise(SeverityMedium)
+					}
+					if !containsString(report.Capabilities, c) {
+						report.Capabilities = append(report.Capabilities, c)
+					}
+				}
+				if caps.rootID != 0 {
+					f.Privileges = append(f.Privileges, fmt.Sprintf("能力只在根 UID 为 %d 的用户命名空间中生效", caps.rootID))
+				}
+			}
+		}
+		if len(f.Privileges) == 0 {
+			continue
+		}
+		report.Files = append(report.Files, f)
+	}
+
+	for _, c := range report.Capabilities {
+		if !containsString(defaultContainerCapabilities, c) {
+			report.ExtraCapabilities = append(report.ExtraCapabilities, c)
+		}
+	}
+	sort.Strings(report.Capabilities)
+	sort.Strings(report.ExtraCapabilities)
+	sort.Slice(report.Files, func(i, j int) bool {
+		a, b := report.Files[i], report.Files[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra < rb
+		}
+		return a.Path < b.Path
+	})
+	return report
+}
+
+// unixPermBits 将 os.FileMode 转换为 chmod 使用的权限位
+func unixPermBits(mode os.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return bits
+}
+
+func describeID(name string, id int, kind string) string {
+	if name == "" {
+		return fmt.Sprintf("%s %d", kind, id)
+	}
+	return fmt.Sprintf("%s (%s %d)", name, kind, id)
+}
@@ -0,0 +1,257 @@
+package analyze
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/csv"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"image-analyzer-go/pkg/logger"
+	"image-analyzer-go/pkg/utils"
+)
+
+// PythonPackageIntegrity 是一个 Python 包按 RECORD 校验文件完整性的结果，只包含存在问题的包
+type PythonPackageIntegrity struct {
+	Package  string   `json:"package"`
+	Version  string   `json:"version"`
+	MetaDir  string   `json:"meta_dir"`
+	Modified []string `json:"modified"`
+	Missing  []string `json:"missing"`
+	Unlisted []string `json:"unlisted"`
+}
+
+// recordEntry 是 RECORD 中的一行
+type recordEntry struct {
+	path string // 镜像内绝对路径
+	algo string
+	hash string
+	size string
+}
+
+// recordJob 是一个待校验的文件
+type recordJob struct {
+	pkg   int
+	entry recordEntry
+}
+
+// recordHashes 是 RECORD 中允许使用的哈希算法
+var recordHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha224": sha256.New224,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// VerifyPythonRecords 读取每个 dist-info/RECORD，对其中列出的文件重新计算哈希，
+// 报告被修改、缺失以及未在 RECORD 中登记的文件。workers 为并发读取文件的上限
+func VerifyPythonRecords(root string, workers int) []PythonPackageIntegrity {
+	var results []PythonPackageIntegrity
+	for _, sp := range findSitePackages(root) {
+		if isCondaPackageCache(root, sp) {
+			continue
+		}
+		results = append(results, verifySitePackagesRecords(root, sp, workers)...)
+	}
+	return results
+}
+
+func verifySitePackagesRecords(root, sitePackages string, workers int) []PythonPackageIntegrity {
+	var (
+		pkgs []PythonPackageIntegrity
+		jobs []recordJob
+		// 同一 site-packages 中所有 RECORD 登记过的文件，用于判断未登记文件（命名空间包可能被多个包共享）
+		listed = make(map[string]bool)
+		// 每个包拥有的顶层目录
+		topDirs [][]string
+	)
+
+	for _, d := range listPythonDists(root, sitePackages) {
+		if !strings.HasSuffix(d.MetaDir, ".dist-info") {
+			continue
+		}
+		entries, err := readRecord(root, sitePackages, d.MetaDir)
+		if err != nil {
+			continue
+		}
+		idx := len(pkgs)
+		pkgs = append(pkgs, PythonPackageIntegrity{
+			Package:  d.Name,
+			Version:  d.Version,
+			MetaDir:  d.MetaDir,
+			Modified: []string{},
+			Missing:  []string{},
+			Unlisted: []string{},
+		})
+
+		dirs := make(map[string]bool)
+		for _, e := range entries {
+			listed[e.path] = true
+			jobs = append(jobs, recordJob{pkg: idx, entry: e})
+			// bin 下的脚本等 site-packages 之外的文件仍然校验，但不决定包拥有的目录
+			rel, ok := strings.CutPrefix(e.path, sitePackages+"/")
+			if !ok {
+				continue
+			}
+			if top, _, ok := strings.Cut(rel, "/"); ok && top != "" {
+				dirs[top] = true
+			}
+		}
+		var owned []string
+		for dir := range dirs {
+			owned = append(owned, dir)
+		}
+		sort.Strings(owned)
+		topDirs = append(topDirs, owned)
+	}
+
+	var mu sync.Mutex
+	runParallel(len(jobs), workers, func(i int) {
+		job := jobs[i]
+		status := checkRecordEntry(root, job.entry)
+		if status == "" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		p := &pkgs[job.pkg]
+		switch status {
+		case "missing":
+			p.Missing = append(p.Missing, job.entry.path)
+		case "modified":
+			p.Modified = append(p.Modified, job.entry.path)
+		}
+	})
+
+	var results []PythonPackageIntegrity
+	for i := range pkgs {
+		p := &pkgs[i]
+		for _, dir := range topDirs[i] {
+			for _, f := range findUnlistedFiles(root, filepath.Join(sitePackages, dir), listed) {
+				// 共享目录中的未登记文件只归到第一个包下
+				if !listed[f] {
+					listed[f] = true
+					p.Unlisted = append(p.Unlisted, f)
+				}
+			}
+		}
+		if len(p.Modified)+len(p.Missing)+len(p.Unlisted) == 0 {
+			continue
+		}
+		sort.Strings(p.Modified)
+		sort.Strings(p.Missing)
+		sort.Strings(p.Unlisted)
+		results = append(results, *p)
+	}
+	return results
+}
+
+// readRecord 解析 RECORD 文件，路径转换为镜像内绝对路径
+func readRecord(root, sitePackages, metaDir string) ([]recordEntry, error) {
+	f, err := os.Open(rootPath(root, metaDir, "RECORD"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		logger.Warn("解析 RECORD 失败", logger.WithString("dir", metaDir), logger.WithError(err))
+		return nil, err
+	}
+
+	var entries []recordEntry
+	for _, row := range rows {
+		if len(row) == 0 || row[0] == "" {
+			continue
+		}
+		e := recordEntry{path: filepath.Join(sitePackages, row[0])}
+		if filepath.IsAbs(row[0]) {
+			e.path = filepath.Clean(row[0])
+		}
+		if len(row) > 1 {
+			e.algo, e.hash, _ = strings.Cut(row[1], "=")
+		}
+		if len(row) > 2 {
+			e.size = row[2]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// checkRecordEntry 校验单个文件，返回 "missing"、"modified" 或空字符串
+func checkRecordEntry(root string, e recordEntry) string {
+	hostPath, err := utils.SecureJoin(root, e.path)
+	if err != nil {
+		return "missing"
+	}
+	fi, err := os.Stat(hostPath)
+	if err != nil || fi.IsDir() {
+		return "missing"
+	}
+	if e.size != "" && e.size != strconv.FormatInt(fi.Size(), 10) {
+		return "modified"
+	}
+	// RECORD 自身以及运行时生成的 .pyc 没有哈希
+	newHash, ok := recordHashes[e.algo]
+	if e.hash == "" || !ok {
+		return ""
+	}
+
+	f, err := os.Open(hostPath)
+	if err != nil {
+		return "missing"
+	}
+	defer f.Close()
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "missing"
+	}
+	if base64.RawURLEncoding.EncodeToString(h.Sum(nil)) != strings.TrimRight(e.hash, "=") {
+		return "modified"
+	}
+	return ""
+}
+
+// findUnlistedFiles 返回目录中没有被任何 RECORD 登记的文件，忽略运行时生成的字节码
+func findUnlistedFiles(root, dir string, listed map[string]bool) []string {
+	var unlisted []string
+	base := rootPath(root, dir)
+	if base == "" {
+		return nil
+	}
+	_ = filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == "__pycache__" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(info.Name(), ".pyc") {
+			return nil
+		}
+		// RECORD 中的路径基于 site-packages 的原始路径，不是解析链接后的路径
+		if p := filepath.Join(dir, strings.TrimPrefix(path, base)); !listed[p] {
+			unlisted = append(unlisted, p)
+		}
+		return nil
+	})
+	return unlisted
+}
@@ -1,9 +1,15 @@
 package analyze
 
 import (
+	"bufio"
+	"bytes"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+
+	"image-analyzer-go/pkg/logger"
 )
 
 func ListPythonPackages(root string) []string {
@@ -16,3 +22,194 @@ func ListPythonPackages(root string) []string {
 	})
 	return pkgs
 }
+
+// pythonDist 表示 site-packages 中一个已安装的发行包
+type pythonDist struct {
+	Name     string
+	Version  string
+	Requires []string
+	// Installer 为 dist-info/INSTALLER 中记录的安装工具，例如 pip、conda、uv
+	Installer string
+	// MetaDir 为 .dist-info/.egg-info 在镜像内的绝对路径
+	MetaDir string
+	// License、LicenseExpression 和 Classifiers 为元数据中声明的许可证信息
+	License           string
+	LicenseExpression string
+	Classifiers       []string
+}
+
+// pythonLibVersionPattern 用于从 lib/pythonX.Y 路径中提取 Python 版本
+var pythonLibVersionPattern = regexp.MustCompile(`/lib(?:64)?/python(\d+\.\d+)/`)
+
+// findSitePackages 返回镜像内所有 site-packages/dist-packages 目录（镜像内的绝对路径）
+func findSitePackages(root string) []string {
+	var dirs []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if name := info.Name(); name == "site-packages" || name == "dist-packages" {
+			dirs = append(dirs, imagePath(root, path))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	return dirs
+}
+
+// listPythonDists 读取一个 site-packages 目录下所有发行包的元数据
+func listPythonDists(root, sitePackages string) []pythonDist {
+	entries, err := os.ReadDir(rootPath(root, sitePackages))
+	if err != nil {
+		logger.Warn("读取 site-packages 失败", logger.WithString("dir", sitePackages), logger.WithError(err))
+		return nil
+	}
+
+	var dists []pythonDist
+	for _, e := range entries {
+		name := e.Name()
+		metaDir := filepath.Join(sitePackages, name)
+		var dist *pythonDist
+		switch {
+		case strings.HasSuffix(name, ".dist-info"):
+			dist = readPythonMetadata(root, metaDir, "METADATA")
+			if dist != nil {
+				if data, err := os.ReadFile(rootPath(root, metaDir, "INSTALLER")); err == nil {
+					dist.Installer = strings.TrimSpace(string(data))
+				}
+			}
+		case strings.HasSuffix(name, ".egg-info"):
+			if e.IsDir() {
+				dist = readPythonMetadata(root, metaDir, "PKG-INFO")
+				if dist != nil {
+					dist.Requires = append(dist.Requires, readEggRequires(rootPath(root, metaDir, "requires.txt"))...)
+				}
+			} else {
+				// 旧版 distutils 安装时 .egg-info 是单个文件
+				dist = readPythonMetadata(root, sitePackages, name)
+				if dist != nil {
+					dist.MetaDir = metaDir
+				}
+			}
+		}
+		if dist != nil {
+			dists = append(dists, *dist)
+		}
+	}
+	return dists
+}
+
+// readPythonMetadata 解析 METADATA/PKG-INFO 的头部字段
+func readPythonMetadata(root, metaDir, file string) *pythonDist {
+	data, err := os.ReadFile(rootPath(root, metaDir, file))
+	if err != nil {
+		return nil
+	}
+	headers := parseRFC822Headers(data)
+	dist := &pythonDist{
+		Name:     firstHeader(headers, "Name"),
+		Version:  firstHeader(headers, "Version"),
+		Requires: headers["Requires-Dist"],
+		MetaDir:  metaDir,
+
+		License:           firstHeader(headers, "License"),
+		LicenseExpression: firstHeader(headers, "License-Expression"),
+		Classifiers:       headers["Classifier"],
+	}
+	if dist.Name == "" {
+		return nil
+	}
+	return dist
+}
+
+// parseRFC822Headers 解析 RFC 822 风格的头部，遇到第一个空行时停止
+func parseRFC822Headers(data []byte) map[string][]string {
+	headers := make(map[string][]string)
+	var lastKey string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && lastKey != "" {
+			vals := headers[lastKey]
+			vals[len(vals)-1] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		lastKey = strings.TrimSpace(key)
+		headers[lastKey] = append(headers[lastKey], strings.TrimSpace(value))
+	}
+	return headers
+}
+
+func firstHeader(headers map[string][]string, key string) string {
+	if vals := headers[key]; len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// readEggRequires 将 egg-info 的 requires.txt 转换为 Requires-Dist 形式。
+// 带 extra 的段落不属于基础依赖，直接跳过；`[:marker]` 段落转换为环境标记
+func readEggRequires(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var reqs []string
+	marker, skip := "", false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section := line[1 : len(line)-1]
+			extra, m, _ := strings.Cut(section, ":")
+			skip = extra != ""
+			marker = m
+			continue
+		}
+		if skip {
+			continue
+		}
+		if marker != "" {
+			line += "; " + marker
+		}
+		reqs = append(reqs, line)
+	}
+	return reqs
+}
+
+// pythonVersionForSitePackages 推断 site-packages 对应的 Python 版本（X.Y）
+func pythonVersionForSitePackages(root, sitePackages string) string {
+	if m := pythonLibVersionPattern.FindStringSubmatch(sitePackages + "/"); m != nil {
+		return m[1]
+	}
+	// Debian 的 /usr/lib/python3/dist-packages 不带版本号，从同前缀下的 lib/python3.X 推断
+	prefix := sitePackages
+	if i := strings.Index(prefix, "/lib/"); i >= 0 {
+		prefix = prefix[:i]
+	}
+	matches := globInRoot(root, filepath.Join(prefix, "lib", "python[0-9].*"))
+	var best *PEP440Version
+	var bestStr string
+	for _, m := range matches {
+		ver := strings.TrimPrefix(filepath.Base(m), "python")
+		v, err := ParsePEP440Version(ver)
+		if err != nil || len(v.Release) != 2 {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best, bestStr = v, ver
+		}
+	}
+	return bestStr
+}
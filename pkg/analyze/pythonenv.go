@@ -0,0 +1,398 @@
+package analyze
+
+import (
+	"bufio"
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// Python 环境类型
+const (
+	PythonEnvSystem  = "system"
+	PythonEnvVenv    = "venv"
+	PythonEnvConda   = "conda"
+	PythonEnvUnknown = "unknown"
+)
+
+// PythonEnvironment 描述镜像中的一个 Python 环境及其安装的包
+type PythonEnvironment struct {
+	Type               string          `json:"type"`
+	Prefix             string          `json:"prefix"`
+	Interpreter        string          `json:"interpreter,omitempty"`
+	Executables        []string        `json:"executables,omitempty"`
+	Version            string          `json:"version,omitempty"`
+	VersionSource      string          `json:"version_source,omitempty"`
+	BaseInterpreter    string          `json:"base_interpreter,omitempty"`
+	SystemSitePackages bool            `json:"system_site_packages,omitempty"`
+	SitePackages       []string        `json:"site_packages"`
+	Packages           []PythonPackage `json:"packages"`
+}
+
+// PythonPackage 是环境中一个已安装的 Python 包
+type PythonPackage struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Location  string `json:"location"`
+	Installer string `json:"installer,omitempty"`
+}
+
+// PythonCommand 描述镜像 PATH 中某个 python 命令最终指向的解释器
+type PythonCommand struct {
+	Command     string   `json:"command"`
+	Path        string   `json:"path"`
+	Links       []string `json:"links,omitempty"`
+	Resolved    string   `json:"resolved"`
+	Environment string   `json:"environment,omitempty"`
+}
+
+var (
+	pythonExecutablePattern = regexp.MustCompile(`^python(\d+(\.\d+)?)?$`)
+	libpythonPattern        = regexp.MustCompile(`^libpython(\d+\.\d+)`)
+	sysconfigVersionPattern = regexp.MustCompile(`'VERSION':\s*'(\d+\.\d+)'`)
+	patchlevelPattern       = regexp.MustCompile(`#define\s+PY_VERSION\s+"([^"]+)"`)
+	condaPythonMetaPattern  = regexp.MustCompile(`^python-(\d+\.\d+[^-]*)-[^-]+\.json$`)
+)
+
+// pythonLayout 是一次遍历镜像文件系统得到的 Python 相关路径
+type pythonLayout struct {
+	executables  map[string][]string // prefix -> bin/python* 的镜像内路径
+	venvs        map[string]bool
+	condaEnvs    map[string]bool
+	sitePackages []string
+}
+
+func scanPythonLayout(root string) *pythonLayout {
+	layout := &pythonLayout{
+		executables: make(map[string][]string),
+		venvs:       make(map[string]bool),
+		condaEnvs:   make(map[string]bool),
+	}
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			switch name {
+			case "conda-meta":
+				layout.condaEnvs[imagePath(root, filepath.Dir(path))] = true
+				return filepath.SkipDir
+			case "site-packages", "dist-packages":
+				layout.sitePackages = append(layout.sitePackages, imagePath(root, path))
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name == "pyvenv.cfg" {
+			layout.venvs[imagePath(root, filepath.Dir(path))] = true
+			return nil
+		}
+		if filepath.Base(filepath.Dir(path)) == "bin" && pythonExecutablePattern.MatchString(name) {
+			prefix := imagePath(root, filepath.Dir(filepath.Dir(path)))
+			layout.executables[prefix] = append(layout.executables[prefix], imagePath(root, path))
+		}
+		return nil
+	})
+	sort.Strings(layout.sitePackages)
+	// 与 Debian 的 sys.path 顺序一致，/usr/local 下的目录优先
+	sort.SliceStable(layout.sitePackages, func(i, j int) bool {
+		return strings.HasPrefix(layout.sitePackages[i], "/usr/local/") && !strings.HasPrefix(layout.sitePackages[j], "/usr/local/")
+	})
+	return layout
+}
+
+// DiscoverPythonEnvironments 查找镜像中的 Python 解释器、venv 和 conda 环境，并将包归属到各自的环境
+func DiscoverPythonEnvironments(root string) []PythonEnvironment {
+	layout := scanPythonLayout(root)
+
+	prefixes := make(map[string]bool)
+	for p := range layout.executables {
+		prefixes[p] = true
+	}
+	for p := range layout.venvs {
+		prefixes[p] = true
+	}
+
+	envs := make(map[string]*PythonEnvironment)
+	for prefix := range prefixes {
+		env := &PythonEnvironment{
+			Type:         PythonEnvSystem,
+			Prefix:       prefix,
+			Executables:  layout.executables[prefix],
+			SitePackages: []string{},
+			Packages:     []PythonPackage{},
+		}
+		switch {
+		case layout.venvs[prefix]:
+			env.Type = PythonEnvVenv
+		case layout.condaEnvs[prefix]:
+			env.Type = PythonEnvConda
+		}
+		detectPythonInterpreter(root, env)
+		envs[prefix] = env
+	}
+
+	for _, sp := range layout.sitePackages {
+		env := envForSitePackages(envs, sp)
+		if env == nil {
+			if isCondaPackageCache(root, sp) {
+				continue
+			}
+			env = &PythonEnvironment{
+				Type:         PythonEnvUnknown,
+				Prefix:       sp,
+				Version:      pythonVersionForSitePackages(root, sp),
+				SitePackages: []string{},
+				Packages:     []PythonPackage{},
+			}
+			envs[sp] = env
+		}
+		env.SitePackages = append(env.SitePackages, sp)
+		for _, d := range listPythonDists(root, sp) {
+			env.Packages = append(env.Packages, PythonPackage{Name: d.Name, Version: d.Version, Location: sp, Installer: d.Installer})
+		}
+	}
+
+	result := make([]PythonEnvironment, 0, len(envs))
+	for _, env := range envs {
+		// 有 venv/conda 标记但没有解释器和包的目录不是有效的 Python 环境
+		if env.Interpreter == "" && len(env.SitePackages) == 0 {
+			continue
+		}
+		result = append(result, *env)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Prefix < result[j].Prefix })
+	return result
+}
+
+// detectPythonInterpreter 确定环境的解释器路径和版本
+func detectPythonInterpreter(root string, env *PythonEnvironment) {
+	sort.Slice(env.Executables, func(i, j int) bool {
+		// 名字更具体的（python3.11 优先于 python3）排在前面
+		return len(env.Executables[i]) > len(env.Executables[j]) ||
+			(len(env.Executables[i]) == len(env.Executables[j]) && env.Executables[i] < env.Executables[j])
+	})
+
+	var elfPath string
+	for _, exe := range env.Executables {
+		resolved, _, err := utils.ResolveInRoot(root, exe)
+		if err != nil || !isELF(filepath.Join(root, resolved)) {
+			continue
+		}
+		env.Interpreter = exe
+		elfPath = resolved
+		if resolved != exe {
+			env.BaseInterpreter = resolved
+		}
+		break
+	}
+
+	if env.Type == PythonEnvVenv {
+		cfg := readPyvenvCfg(rootPath(root, env.Prefix, "pyvenv.cfg"))
+		env.SystemSitePackages = strings.EqualFold(cfg["include-system-site-packages"], "true")
+		if home := cfg["home"]; home != "" && env.BaseInterpreter == "" {
+			env.BaseInterpreter = home
+		}
+		for _, key := range []string{"version", "version_info"} {
+			if v := cfg[key]; v != "" {
+				env.Version, env.VersionSource = trimPythonVersion(v), "pyvenv.cfg"
+				return
+			}
+		}
+	}
+
+	if env.Type == PythonEnvConda {
+		entries, _ := os.ReadDir(rootPath(root, env.Prefix, "conda-meta"))
+		for _, e := range entries {
+			if m := condaPythonMetaPattern.FindStringSubmatch(e.Name()); m != nil {
+				env.Version, env.VersionSource = m[1], "conda-meta"
+				return
+			}
+		}
+	}
+
+	if elfPath != "" {
+		if v := libpythonVersion(root, elfPath); v != "" {
+			env.Version, env.VersionSource = v, "libpython soname"
+		}
+	}
+	if env.Version == "" {
+		for _, m := range globInRoot(root, filepath.Join(env.Prefix, "lib", "python*", "_sysconfigdata*.py")) {
+			data, err := os.ReadFile(rootPath(root, m))
+			if err != nil {
+				continue
+			}
+			if sm := sysconfigVersionPattern.FindSubmatch(data); sm != nil {
+				env.Version, env.VersionSource = string(sm[1]), "sysconfigdata"
+				break
+			}
+		}
+	}
+	if env.Version == "" && env.Interpreter != "" {
+		if m := pythonExecutablePattern.FindStringSubmatch(filepath.Base(env.Interpreter)); m != nil && m[2] != "" {
+			env.Version, env.VersionSource = m[1], "executable name"
+		}
+	}
+
+	// 安装了头文件时可以从 patchlevel.h 得到完整的版本号
+	if env.Version != "" {
+		data, err := os.ReadFile(rootPath(root, env.Prefix, "include", "python"+env.Version, "patchlevel.h"))
+		if err == nil {
+			if m := patchlevelPattern.FindSubmatch(data); m != nil && strings.HasPrefix(string(m[1]), env.Version+".") {
+				env.Version, env.VersionSource = string(m[1]), env.VersionSource+"+patchlevel.h"
+			}
+		}
+	}
+}
+
+// libpythonVersion 从解释器 DT_NEEDED 中的 libpython soname 推断版本
+func libpythonVersion(root, interpreter string) string {
+	if f, err := elf.Open(rootPath(root, interpreter)); err == nil {
+		libs, _ := f.ImportedLibraries()
+		f.Close()
+		for _, lib := range libs {
+			if m := libpythonPattern.FindStringSubmatch(lib); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// venvBasePrefix 返回 venv 所基于的解释器的前缀目录
+func venvBasePrefix(env PythonEnvironment) string {
+	base := env.BaseInterpreter
+	if pythonExecutablePattern.MatchString(filepath.Base(base)) {
+		base = filepath.Dir(base)
+	}
+	return filepath.Dir(base)
+}
+
+// readPyvenvCfg 解析 venv 的 pyvenv.cfg
+func readPyvenvCfg(path string) map[string]string {
+	cfg := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok {
+			cfg[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return cfg
+}
+
+// trimPythonVersion 将 3.11.4.final.0 这样的 version_info 截取为 3.11.4
+func trimPythonVersion(v string) string {
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+	return strings.Join(parts, ".")
+}
+
+// majorMinor 返回版本号的 X.Y 部分
+func majorMinor(v string) string {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return v
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// envForSitePackages 找到 site-packages 目录所属的环境：前缀最长且版本一致的环境
+func envForSitePackages(envs map[string]*PythonEnvironment, sitePackages string) *PythonEnvironment {
+	libVersion := ""
+	if m := pythonLibVersionPattern.FindStringSubmatch(sitePackages + "/"); m != nil {
+		libVersion = m[1]
+	}
+	match := func(prefix string) *PythonEnvironment {
+		env := envs[prefix]
+		if env == nil || env.Type == PythonEnvUnknown {
+			return nil
+		}
+		if libVersion != "" && env.Version != "" && majorMinor(env.Version) != libVersion {
+			return nil
+		}
+		return env
+	}
+
+	var best *PythonEnvironment
+	for prefix := range envs {
+		if !strings.HasPrefix(sitePackages, strings.TrimSuffix(prefix, "/")+"/lib") {
+			continue
+		}
+		if env := match(prefix); env != nil && (best == nil || len(prefix) > len(best.Prefix)) {
+			best = env
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	// Debian 系的 /usr/bin/python3 同时使用 /usr/local/lib/python3.X/dist-packages
+	if strings.HasPrefix(sitePackages, "/usr/local/lib/") && filepath.Base(sitePackages) == "dist-packages" {
+		return match("/usr")
+	}
+	return nil
+}
+
+// isCondaPackageCache 判断 site-packages 是否位于 conda 的包缓存（pkgs/<name>/）中
+func isCondaPackageCache(root, sitePackages string) bool {
+	for dir := filepath.Dir(sitePackages); dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(rootPath(root, dir, "info", "index.json")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolvePythonCommands 按照镜像的 PATH 解析 python/python3 命令指向的解释器
+func ResolvePythonCommands(root string, env []string, envs []PythonEnvironment) []PythonCommand {
+	var commands []PythonCommand
+	for _, name := range []string{"python", "python3"} {
+		for _, dir := range imagePATH(env) {
+			candidate := filepath.Join(dir, name)
+			resolved, links, err := utils.ResolveInRoot(root, candidate)
+			if err != nil {
+				continue
+			}
+			fi, err := os.Stat(filepath.Join(root, resolved))
+			if err != nil || fi.IsDir() {
+				continue
+			}
+			cmd := PythonCommand{Command: name, Path: candidate, Links: links, Resolved: resolved}
+			cmd.Environment = environmentForCommand(root, envs, candidate, resolved)
+			commands = append(commands, cmd)
+			break
+		}
+	}
+	return commands
+}
+
+// environmentForCommand 找到命令所属的环境：优先匹配命令所在的 bin 目录，其次匹配解析后的解释器
+func environmentForCommand(root string, envs []PythonEnvironment, candidate, resolved string) string {
+	binPrefix := filepath.Dir(filepath.Dir(candidate))
+	for _, e := range envs {
+		if e.Prefix == binPrefix && e.Type != PythonEnvUnknown {
+			return e.Prefix
+		}
+	}
+	for _, e := range envs {
+		for _, exe := range e.Executables {
+			if r, _, err := utils.ResolveInRoot(root, exe); err == nil && r == resolved {
+				return e.Prefix
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,139 @@
+package analyze
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// defaultPATH 是镜像配置中没有设置 PATH 时容器运行时使用的默认值
+const defaultPATH = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// imagePath 将宿主机上的路径转换为镜像内的绝对路径
+func imagePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// rootPath 将镜像内路径在 root 内解析符号链接后转换为宿主机路径。镜像中的链接可能指向绝对路径或用 .. 越过根目录，
+// 直接拼接后读取会读到宿主机上的文件，读取镜像中的文件都应经过它。链接层数过多时返回空字符串
+func rootPath(root string, elem ...string) string {
+	p, err := utils.SecureJoin(root, path.Join(append([]string{"/"}, elem...)...))
+	if err != nil {
+		return ""
+	}
+	return p
+}
+
+// globInRoot 在镜像中按 filepath.Match 的模式匹配路径，逐级在 root 内解析目录，返回镜像内路径
+func globInRoot(root, pattern string) []string {
+	matches := []string{"/"}
+	for _, part := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		var next []string
+		for _, dir := range matches {
+			if !strings.ContainsAny(part, "*?[\\") {
+				next = append(next, path.Join(dir, part))
+				continue
+			}
+			entries, err := os.ReadDir(rootPath(root, dir))
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if ok, _ := path.Match(part, e.Name()); ok {
+					next = append(next, path.Join(dir, e.Name()))
+				}
+			}
+		}
+		matches = next
+	}
+	var out []string
+	for _, m := range matches {
+		if _, err := os.Lstat(rootPath(root, m)); err == nil {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// envValue 从 KEY=VALUE 形式的环境变量列表中取值，后出现的覆盖先出现的
+func envValue(env []string, key string) (string, bool) {
+	value, found := "", false
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			value, found = v, true
+		}
+	}
+	return value, found
+}
+
+// imagePATH 返回镜像配置中 PATH 的各个目录
+func imagePATH(env []string) []string {
+	path, ok := envValue(env, "PATH")
+	if !ok {
+		path = defaultPATH
+	}
+	var dirs []string
+	for _, dir := range strings.Split(path, ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// isELF 判断文件是否为 ELF 格式
+func isELF(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, []byte("\x7fELF"))
+}
+
+// scanChunkSize 是流式扫描大文件时每次读取的字节数
+const scanChunkSize = 1 << 20
+
+// findInFile 流式扫描文件内容，返回正则的第一个匹配的子匹配组。
+// 相邻块之间保留 overlap 字节，匹配内容不应超过该长度
+func findInFile(path string, re *regexp.Regexp, overlap int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	buf := make([]byte, 0, scanChunkSize+overlap)
+	chunk := make([]byte, scanChunkSize)
+	for {
+		n, err := f.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if m := re.FindSubmatch(buf); m != nil {
+			out := make([]string, len(m))
+			for i, g := range m {
+				out[i] = string(g)
+			}
+			return out
+		}
+		if err != nil {
+			return nil
+		}
+		if len(buf) > overlap {
+			buf = append(buf[:0], buf[len(buf)-overlap:]...)
+		}
+	}
+}
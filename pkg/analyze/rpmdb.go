@@ -0,0 +1,406 @@
+package analyze
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"image-analyzer-go/pkg/logger"
+	"image-analyzer-go/pkg/utils"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// rpm 头部中用到的标签
+const (
+	rpmTagName           = 1000
+	rpmTagVersion        = 1001
+	rpmTagRelease        = 1002
+	rpmTagEpoch          = 1003
+	rpmTagLicense        = 1014
+	rpmTagArch           = 1022
+	rpmTagFileSizes      = 1028
+	rpmTagFileModes      = 1030
+	rpmTagFileDigests    = 1035
+	rpmTagFileLinkTos    = 1036
+	rpmTagFileFlags      = 1037
+	rpmTagSourceRPM      = 1044
+	rpmTagDirIndexes     = 1116
+	rpmTagBaseNames      = 1117
+	rpmTagDirNames       = 1118
+	rpmTagFileDigestAlgo = 5011
+)
+
+// rpm 头部的数据类型
+const (
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+)
+
+// rpm 文件标记
+const (
+	rpmFileConfig    = 1 << 0
+	rpmFileDoc       = 1 << 1
+	rpmFileMissingOK = 1 << 3
+	rpmFileGhost     = 1 << 6
+)
+
+// rpmPackage 是 rpm 数据库中的一个已安装包
+type rpmPackage struct {
+	Name      string
+	Epoch     int
+	Version   string
+	Release   string
+	Arch      string
+	License   string
+	SourceRPM string
+	Files     []rpmFile
+	// DigestAlgo 为文件摘要使用的 PGP 哈希算法编号，默认 MD5
+	DigestAlgo int
+}
+
+// rpmFile 是 rpm 包中的一个文件
+type rpmFile struct {
+	Path   string
+	Digest string
+	Size   int64
+	Mode   uint16
+	Flags  int32
+	LinkTo string
+}
+
+// EVR 返回 [epoch:]version-release 形式的版本号
+func (p rpmPackage) EVR() string {
+	if p.Epoch > 0 {
+		return fmt.Sprintf("%d:%s-%s", p.Epoch, p.Version, p.Release)
+	}
+	return p.Version + "-" + p.Release
+}
+
+// rpmDBLocations 是常见的 rpm 数据库位置，按优先级排列
+var rpmDBLocations = []string{
+	"/var/lib/rpm/rpmdb.sqlite",
+	"/usr/lib/sysimage/rpm/rpmdb.sqlite",
+	"/var/lib/rpm/Packages",
+	"/usr/lib/sysimage/rpm/Packages",
+}
+
+// errRPMDBNotFound 表示镜像中没有 rpm 数据库
+var errRPMDBNotFound = errors.New("未找到 rpm 数据库")
+
+// readRPMPackages 读取镜像中的 rpm 数据库，支持 sqlite 和 Berkeley DB 两种后端
+func readRPMPackages(root string) ([]rpmPackage, error) {
+	for _, loc := range rpmDBLocations {
+		path, err := utils.SecureJoin(root, loc)
+		if err != nil {
+			continue
+		}
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			continue
+		}
+
+		var blobs [][]byte
+		if filepath.Ext(loc) == ".sqlite" {
+			blobs, err = readRPMSqliteBlobs(path)
+		} else {
+			blobs, err = readBerkeleyDBValues(path)
+		}
+		if err != nil {
+			return nil, utils.WrapError(err, "读取 rpm 数据库 "+loc+" 失败")
+		}
+
+		var pkgs []rpmPackage
+		for _, blob := range blobs {
+			pkg, err := parseRPMHeader(blob)
+			if err != nil {
+				logger.Debug("解析 rpm 头部失败", logger.WithError(err))
+				continue
+			}
+			// gpg-pubkey 等伪包没有文件，也不是真正安装的软件
+			if pkg.Name == "gpg-pubkey" {
+				continue
+			}
+			pkgs = append(pkgs, *pkg)
+		}
+		sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+		return pkgs, nil
+	}
+	return nil, errRPMDBNotFound
+}
+
+// readRPMSqliteBlobs 读取 rpmdb.sqlite 中的所有头部
+func readRPMSqliteBlobs(path string) ([][]byte, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro&immutable=1")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT blob FROM Packages")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs [][]byte
+	for rows.Next() {
+		var blob []byte
+		if err := rows.Scan(&blob); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, blob)
+	}
+	return blobs, rows.Err()
+}
+
+// Berkeley DB 哈希库的常量
+const (
+	bdbHashMagic     = 0x061561
+	bdbPageHeaderLen = 26
+	bdbPageHash      = 13
+	bdbPageOverflow  = 7
+	bdbItemKeyData   = 1
+	bdbItemOffPage   = 3
+)
+
+// readBerkeleyDBValues 读取 Berkeley DB 哈希库中的所有值，rpm 的 Packages 库中每个值是一个头部
+func readBerkeleyDBValues(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 512 {
+		return nil, errors.New("Berkeley DB 文件过小")
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(data[12:]) != bdbHashMagic {
+		order = binary.BigEndian
+		if order.Uint32(data[12:]) != bdbHashMagic {
+			return nil, errors.New("不是 Berkeley DB 哈希库")
+		}
+	}
+	pageSize := int(order.Uint32(data[20:]))
+	if pageSize < 512 || len(data)%pageSize != 0 {
+		return nil, fmt.Errorf("无效的 Berkeley DB 页大小 %d", pageSize)
+	}
+	page := func(n uint32) []byte {
+		start := int(n) * pageSize
+		if start+pageSize > len(data) {
+			return nil
+		}
+		return data[start : start+pageSize]
+	}
+
+	var values [][]byte
+	for n := 1; n*pageSize < len(data); n++ {
+		p := page(uint32(n))
+		if p[25] != bdbPageHash {
+			continue
+		}
+		entries := int(order.Uint16(p[20:]))
+		// 条目数来自页头，索引表超出页面时跳过整页
+		if bdbPageHeaderLen+2*entries > len(p) {
+			continue
+		}
+		// 条目按 key、value 交替排列，只取 value
+		for i := 1; i < entries; i += 2 {
+			off := int(order.Uint16(p[bdbPageHeaderLen+2*i:]))
+			if off >= pageSize {
+				continue
+			}
+			switch p[off] {
+			case bdbItemKeyData:
+				end := pageSize
+				if i > 0 {
+					end = int(order.Uint16(p[bdbPageHeaderLen+2*(i-1):]))
+				}
+				if end > off+1 && end <= pageSize {
+					values = append(values, append([]byte(nil), p[off+1:end]...))
+				}
+			case bdbItemOffPage:
+				if off+12 > pageSize {
+					continue
+				}
+				pgno := order.Uint32(p[off+4:])
+				total := int(order.Uint32(p[off+8:]))
+				// 溢出页中的数据不可能超过整个数据库文件
+				if total > len(data) {
+					continue
+				}
+				var buf bytes.Buffer
+				// 记录访问过的溢出页，防止构造的循环链表重复读取同一页
+				visited := make(map[uint32]bool)
+				for pgno != 0 && buf.Len() < total && !visited[pgno] {
+					visited[pgno] = true
+					op := page(pgno)
+					if op == nil || op[25] != bdbPageOverflow {
+						break
+					}
+					length := int(order.Uint16(op[22:]))
+					if length == 0 || bdbPageHeaderLen+length > pageSize {
+						break
+					}
+					buf.Write(op[bdbPageHeaderLen : bdbPageHeaderLen+length])
+					pgno = order.Uint32(op[16:])
+				}
+				values = append(values, buf.Bytes())
+			}
+		}
+	}
+	return values, nil
+}
+
+// rpmHeader 是解析后的 rpm 头部索引
+type rpmHeader struct {
+	entries map[int32]rpmHeaderEntry
+	store   []byte
+}
+
+type rpmHeaderEntry struct {
+	typ    uint32
+	offset int
+	count  int
+}
+
+// parseRPMHeader 解析数据库中存储的 rpm 头部（不带 lead 和头部魔数）
+func parseRPMHeader(blob []byte) (*rpmPackage, error) {
+	if len(blob) < 8 {
+		return nil, errors.New("rpm 头部过短")
+	}
+	il := int(binary.BigEndian.Uint32(blob[0:]))
+	dl := int(binary.BigEndian.Uint32(blob[4:]))
+	storeStart := 8 + il*16
+	if il <= 0 || dl < 0 || storeStart+dl > len(blob) {
+		return nil, errors.New("rpm 头部长度无效")
+	}
+
+	h := &rpmHeader{entries: make(map[int32]rpmHeaderEntry, il), store: blob[storeStart : storeStart+dl]}
+	for i := 0; i < il; i++ {
+		e := blob[8+i*16:]
+		tag := int32(binary.BigEndian.Uint32(e[0:]))
+		h.entries[tag] = rpmHeaderEntry{
+			typ:    binary.BigEndian.Uint32(e[4:]),
+			offset: int(int32(binary.BigEndian.Uint32(e[8:]))),
+			count:  int(binary.BigEndian.Uint32(e[12:])),
+		}
+	}
+
+	pkg := &rpmPackage{
+		Name:       h.str(rpmTagName),
+		Version:    h.str(rpmTagVersion),
+		Release:    h.str(rpmTagRelease),
+		Arch:       h.str(rpmTagArch),
+		License:    h.str(rpmTagLicense),
+		SourceRPM:  h.str(rpmTagSourceRPM),
+		DigestAlgo: 1,
+	}
+	if epoch := h.ints(rpmTagEpoch); len(epoch) > 0 {
+		pkg.Epoch = int(epoch[0])
+	}
+	if algo := h.ints(rpmTagFileDigestAlgo); len(algo) > 0 {
+		pkg.DigestAlgo = int(algo[0])
+	}
+	if pkg.Name == "" {
+		return nil, errors.New("rpm 头部缺少包名")
+	}
+
+	baseNames := h.strs(rpmTagBaseNames)
+	dirNames := h.strs(rpmTagDirNames)
+	dirIndexes := h.ints(rpmTagDirIndexes)
+	digests := h.strs(rpmTagFileDigests)
+	linkTos := h.strs(rpmTagFileLinkTos)
+	sizes := h.ints(rpmTagFileSizes)
+	modes := h.ints(rpmTagFileModes)
+	flags := h.ints(rpmTagFileFlags)
+	for i, base := range baseNames {
+		if i >= len(dirIndexes) || int(dirIndexes[i]) >= len(dirNames) {
+			break
+		}
+		f := rpmFile{Path: dirNames[dirIndexes[i]] + base}
+		if i < len(digests) {
+			f.Digest = digests[i]
+		}
+		if i < len(linkTos) {
+			f.LinkTo = linkTos[i]
+		}
+		if i < len(sizes) {
+			f.Size = sizes[i]
+		}
+		if i < len(modes) {
+			f.Mode = uint16(modes[i])
+		}
+		if i < len(flags) {
+			f.Flags = int32(flags[i])
+		}
+		pkg.Files = append(pkg.Files, f)
+	}
+	return pkg, nil
+}
+
+func (h *rpmHeader) strs(tag int32) []string {
+	e, ok := h.entries[tag]
+	if !ok || e.offset < 0 || e.offset > len(h.store) {
+		return nil
+	}
+	switch e.typ {
+	case rpmTypeString, rpmTypeStringArray, rpmTypeI18NString:
+	default:
+		return nil
+	}
+	var out []string
+	data := h.store[e.offset:]
+	for i := 0; i < e.count; i++ {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			break
+		}
+		out = append(out, string(data[:end]))
+		data = data[end+1:]
+	}
+	return out
+}
+
+func (h *rpmHeader) str(tag int32) string {
+	if s := h.strs(tag); len(s) > 0 {
+		return s[0]
+	}
+	return ""
+}
+
+func (h *rpmHeader) ints(tag int32) []int64 {
+	e, ok := h.entries[tag]
+	if !ok || e.offset < 0 {
+		return nil
+	}
+	size := 0
+	switch e.typ {
+	case rpmTypeInt16:
+		size = 2
+	case rpmTypeInt32:
+		size = 4
+	default:
+		return nil
+	}
+	if e.offset+e.count*size > len(h.store) {
+		return nil
+	}
+	out := make([]int64, e.count)
+	for i := range out {
+		b := h.store[e.offset+i*size:]
+		if size == 2 {
+			out[i] = int64(binary.BigEndian.Uint16(b))
+		} else {
+			out[i] = int64(int32(binary.BigEndian.Uint32(b)))
+		}
+	}
+	return out
+}
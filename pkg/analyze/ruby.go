@@ -0,0 +1,79 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// rubyGemAnalyzer 解析 RubyGems 安装的 specifications/*.gemspec
+type rubyGemAnalyzer struct{}
+
+var (
+	// gemspec 中形如 s.name = "rake".freeze 的赋值
+	gemspecAssignPattern = regexp.MustCompile(`(?m)^\s*\w+\.(name|version|licenses?)\s*=\s*(.+)$`)
+	rubyStringPattern    = regexp.MustCompile(`["']([^"']*)["']`)
+	gemFilenamePattern   = regexp.MustCompile(`^(.+)-(\d[^-]*(?:-[a-z0-9_]+(?:-[a-z0-9_]+)?)?)$`)
+)
+
+func (rubyGemAnalyzer) match(path string, info os.FileInfo) bool {
+	if !strings.HasSuffix(info.Name(), ".gemspec") {
+		return false
+	}
+	// 默认 gem 位于 specifications/default 下
+	dir := filepath.Base(filepath.Dir(path))
+	if dir == "default" {
+		dir = filepath.Base(filepath.Dir(filepath.Dir(path)))
+	}
+	return dir == "specifications"
+}
+
+func (rubyGemAnalyzer) analyze(root, path string) []Package {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	pkg := Package{Ecosystem: EcosystemRubyGems, Location: imagePath(root, path)}
+	var licenses []string
+	for _, m := range gemspecAssignPattern.FindAllStringSubmatch(string(data), -1) {
+		values := rubyStringPattern.FindAllStringSubmatch(m[2], -1)
+		if len(values) == 0 {
+			continue
+		}
+		switch m[1] {
+		case "name":
+			if pkg.Name == "" {
+				pkg.Name = values[0][1]
+			}
+		case "version":
+			if pkg.Version == "" {
+				pkg.Version = values[0][1]
+			}
+		case "license", "licenses":
+			for _, v := range values {
+				licenses = append(licenses, v[1])
+			}
+		}
+	}
+	// name 或 version 不是字符串字面量时从文件名 name-version.gemspec 中取
+	if pkg.Name == "" || pkg.Version == "" {
+		if m := gemFilenamePattern.FindStringSubmatch(strings.TrimSuffix(filepath.Base(path), ".gemspec")); m != nil {
+			if pkg.Name == "" {
+				pkg.Name = m[1]
+			}
+			if pkg.Version == "" {
+				pkg.Version = m[2]
+			}
+		}
+	}
+	if pkg.Name == "" {
+		return nil
+	}
+	if len(licenses) > 1 {
+		pkg.License = "(" + strings.Join(licenses, " OR ") + ")"
+	} else {
+		pkg.License = strings.Join(licenses, "")
+	}
+	return []Package{pkg}
+}
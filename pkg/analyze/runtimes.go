@@ -0,0 +1,142 @@
+package analyze
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Runtime 是一个静态检测到的语言运行时或工具链，不会执行镜像中的任何程序
+type Runtime struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	// Method 为版本号的来源，例如 version file、soname、embedded string
+	Method string `json:"method"`
+}
+
+var (
+	goVersionFilePattern = regexp.MustCompile(`^go(\d+\.\d+(?:\.\d+)?)`)
+	rbconfigPattern      = regexp.MustCompile(`CONFIG\["RUBY_PROGRAM_VERSION"\]\s*=\s*"([^"]+)"`)
+	librubyPattern       = regexp.MustCompile(`^libruby(?:-\d+\.\d+)?\.so\.(\d+\.\d+\.\d+)$`)
+	perlConfigPattern    = regexp.MustCompile(`(?m)^\s*version\s*=>\s*'(\d+\.\d+\.\d+)'`)
+	libperlPattern       = regexp.MustCompile(`^libperl\.so\.(\d+\.\d+\.\d+)$`)
+	phpVersionHeader     = regexp.MustCompile(`#define\s+PHP_VERSION\s+"([^"]+)"`)
+	phpBinaryPattern     = regexp.MustCompile(`^php(?:\d+(?:\.\d+)?)?(?:-fpm(?:\d+(?:\.\d+)?)?|-cgi)?$`)
+	phpEmbeddedPattern   = regexp.MustCompile(`X-Powered-By: PHP/(\d+\.\d+\.\d+)`)
+	rVersionMajorPattern = regexp.MustCompile(`#define\s+R_MAJOR\s+"(\d+)"`)
+	rVersionMinorPattern = regexp.MustCompile(`#define\s+R_MINOR\s+"([\d.]+)"`)
+	cmakeShareDirPattern = regexp.MustCompile(`^cmake-(\d+\.\d+)$`)
+	gccVersionDirPattern = regexp.MustCompile(`^\d+(?:\.\d+)*$`)
+)
+
+// ListRuntimes 通过版本文件、库的 soname 和二进制中内嵌的字符串检测 Python、Node、Java、Go、
+// Ruby、Perl、PHP、R、gcc/g++ 和 CMake 的版本
+func ListRuntimes(root string) []Runtime {
+	runtimes := []Runtime{}
+	add := func(name, version, path, method string) {
+		if version != "" {
+			runtimes = append(runtimes, Runtime{Name: name, Version: version, Path: imagePath(root, path), Method: method})
+		}
+	}
+
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		dir := filepath.Dir(path)
+		if info.IsDir() {
+			// CMake 的模块安装在 share/cmake-X.Y 下
+			if m := cmakeShareDirPattern.FindStringSubmatch(name); m != nil {
+				if _, err := os.Stat(filepath.Join(path, "Modules")); err == nil {
+					add("cmake", m[1], path, "share directory")
+				}
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		switch {
+		case (name == "node" || name == "nodejs") && filepath.Base(dir) == "bin":
+			if rt := detectNodeRuntime(root, path); rt != nil {
+				add("node", rt.Version, path, rt.Source)
+			}
+		case name == "release":
+			if rt := readJavaRelease(root, dir); rt != nil {
+				add("java", rt.Version, dir, "release file")
+			}
+		case name == "VERSION":
+			// GOROOT 下的 VERSION 文件，第一行形如 go1.21.5
+			if _, err := os.Stat(filepath.Join(dir, "bin", "go")); err == nil {
+				if data, err := os.ReadFile(path); err == nil {
+					if m := goVersionFilePattern.FindSubmatch(data); m != nil {
+						add("go", string(m[1]), dir, "version file")
+					}
+				}
+			}
+		case name == "rbconfig.rb":
+			if m := findInFile(path, rbconfigPattern, 256); m != nil {
+				add("ruby", m[1], path, "rbconfig.rb")
+			}
+		case librubyPattern.MatchString(name):
+			add("ruby", librubyPattern.FindStringSubmatch(name)[1], path, "soname")
+		case name == "Config.pm" && strings.Contains(path, "/perl"):
+			if m := findInFile(path, perlConfigPattern, 256); m != nil {
+				add("perl", m[1], path, "Config.pm")
+			}
+		case libperlPattern.MatchString(name):
+			add("perl", libperlPattern.FindStringSubmatch(name)[1], path, "soname")
+		case name == "php_version.h":
+			if m := findInFile(path, phpVersionHeader, 256); m != nil {
+				add("php", m[1], path, "header")
+			}
+		case phpBinaryPattern.MatchString(name) && isBinDir(dir) && isELF(path):
+			if m := findInFile(path, phpEmbeddedPattern, 64); m != nil {
+				add("php", m[1], path, "embedded string")
+			}
+		case name == "Rversion.h":
+			if data, err := os.ReadFile(path); err == nil {
+				major, minor := rVersionMajorPattern.FindSubmatch(data), rVersionMinorPattern.FindSubmatch(data)
+				if major != nil && minor != nil {
+					add("r", string(major[1])+"."+string(minor[1]), path, "header")
+				}
+			}
+		case name == "cc1" || name == "cc1plus":
+			// gcc 的编译器本体安装在 lib(exec)/gcc/<triple>/<version>/ 下
+			if filepath.Base(filepath.Dir(filepath.Dir(dir))) == "gcc" && gccVersionDirPattern.MatchString(filepath.Base(dir)) {
+				runtime := "gcc"
+				if name == "cc1plus" {
+					runtime = "g++"
+				}
+				add(runtime, filepath.Base(dir), path, "libexec directory")
+			}
+		}
+		return nil
+	})
+
+	for _, env := range DiscoverPythonEnvironments(root) {
+		// venv 中的解释器是基础环境的链接，版本与基础环境相同
+		if env.Type == PythonEnvVenv || env.Interpreter == "" || env.Version == "" {
+			continue
+		}
+		runtimes = append(runtimes, Runtime{Name: "python", Version: env.Version, Path: env.Interpreter, Method: env.VersionSource})
+	}
+
+	sort.Slice(runtimes, func(i, j int) bool {
+		if runtimes[i].Name != runtimes[j].Name {
+			return runtimes[i].Name < runtimes[j].Name
+		}
+		return runtimes[i].Path < runtimes[j].Path
+	})
+	return runtimes
+}
+
+func isBinDir(dir string) bool {
+	base := filepath.Base(dir)
+	return base == "bin" || base == "sbin"
+}
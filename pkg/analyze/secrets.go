@@ -0,0 +1,461 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-analyzer-go/pkg/utils"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretScanResult 是密钥扫描的结果
+type SecretScanResult struct {
+	Rules        int             `json:"rules"`
+	FilesScanned int             `json:"files_scanned"`
+	Findings     []SecretFinding `json:"findings"`
+	// Errors 为加载规则文件或编译规则时的错误，出错的规则被跳过
+	Errors []string `json:"errors,omitempty"`
+}
+
+// SecretFinding 是一处疑似泄露的凭据，Match 中的值已经脱敏
+type SecretFinding struct {
+	RuleID      string `json:"rule_id"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	// Source 为 file、env、label 或 history
+	Source string `json:"source"`
+	// Path 为文件路径，Key 为环境变量或标签的名称
+	Path string `json:"path,omitempty"`
+	Key  string `json:"key,omitempty"`
+	// Line 为文件中的行号，history 来源时为历史记录的序号，均从 1 开始
+	Line int `json:"line,omitempty"`
+	// Layer 为引入该内容的层序号，从 0 开始，无法确定时省略
+	Layer       *int    `json:"layer,omitempty"`
+	LayerDigest string  `json:"layer_digest,omitempty"`
+	Match       string  `json:"match"`
+	Entropy     float64 `json:"entropy,omitempty"`
+}
+
+// SecretRule 是一条密钥检测规则，规则文件中使用相同的字段
+type SecretRule struct {
+	ID          string `json:"id" yaml:"id"`
+	Description string `json:"description" yaml:"description"`
+	Severity    string `json:"severity" yaml:"severity"`
+	Regex       string `json:"regex" yaml:"regex"`
+	// SecretGroup 为正则中表示密钥值的子匹配组，0 表示整个匹配
+	SecretGroup int `json:"secret_group" yaml:"secret_group"`
+	// MinEntropy 为密钥值的最小香农熵（每字符比特数），用于过滤占位符等低熵的值
+	MinEntropy float64 `json:"min_entropy" yaml:"min_entropy"`
+	// Keywords 为预筛选关键字（不区分大小写），不包含任何关键字的行不应用该规则
+	Keywords []string `json:"keywords" yaml:"keywords"`
+	// Path 为适用的文件路径正则，为空时适用于所有文件，环境变量、标签和构建历史不受限制
+	Path string `json:"path" yaml:"path"`
+
+	re   *regexp.Regexp
+	path *regexp.Regexp
+}
+
+// SecretScanOptions 是密钥扫描的配置
+type SecretScanOptions struct {
+	// RuleFiles 为额外的 YAML 规则文件，格式为 rules: [SecretRule...]
+	RuleFiles []string
+	// AllowPaths 为不扫描的文件路径正则，AllowRegexes 为忽略的密钥值正则
+	AllowPaths   []string
+	AllowRegexes []string
+}
+
+const (
+	SecretSourceFile    = "file"
+	SecretSourceEnv     = "env"
+	SecretSourceLabel   = "label"
+	SecretSourceHistory = "history"
+)
+
+// maxSecretFileSize 是扫描的单个文件的最大大小，更大的文件通常是数据或归档
+const maxSecretFileSize = 10 << 20
+
+// builtinSecretRules 是内置的规则，专用规则排在通用规则之前，同一处的值只报告一次
+var builtinSecretRules = []SecretRule{
+	{ID: "private-key", Description: "私钥", Severity: SeverityCritical,
+		Regex: `-----BEGIN ((?:RSA|DSA|EC|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`, Keywords: []string{"private key"}},
+	{ID: "aws-access-key-id", Description: "AWS Access Key ID", Severity: SeverityHigh,
+		Regex: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`, SecretGroup: 1, Keywords: []string{"akia", "asia"}},
+	{ID: "aws-secret-access-key", Description: "AWS Secret Access Key", Severity: SeverityCritical,
+		Regex: `(?i)aws_?secret_?access_?key\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`, SecretGroup: 1, MinEntropy: 3.5, Keywords: []string{"secret"}},
+	{ID: "github-token", Description: "GitHub 令牌", Severity: SeverityCritical,
+		Regex: `\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`, SecretGroup: 1, Keywords: []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_"}},
+	{ID: "gitlab-token", Description: "GitLab 个人访问令牌", Severity: SeverityCritical,
+		Regex: `\b(glpat-[A-Za-z0-9_-]{20,})\b`, SecretGroup: 1, Keywords: []string{"glpat-"}},
+	{ID: "slack-token", Description: "Slack 令牌", Severity: SeverityHigh,
+		Regex: `\b(xox[abposr]-[A-Za-z0-9-]{10,})\b`, SecretGroup: 1, Keywords: []string{"xox"}},
+	{ID: "google-api-key", Description: "Google API Key", Severity: SeverityHigh,
+		Regex: `\b(AIza[0-9A-Za-z_-]{35})\b`, SecretGroup: 1, Keywords: []string{"aiza"}},
+	{ID: "stripe-secret-key", Description: "Stripe 密钥", Severity: SeverityCritical,
+		Regex: `\b((?:sk|rk)_live_[0-9A-Za-z]{24,})\b`, SecretGroup: 1, Keywords: []string{"_live_"}},
+	{ID: "openai-api-key", Description: "OpenAI API Key", Severity: SeverityHigh,
+		Regex: `\b(sk-(?:proj-)?[A-Za-z0-9_-]{20,}T3BlbkFJ[A-Za-z0-9_-]{20,})\b`, SecretGroup: 1, Keywords: []string{"t3blbkfj"}},
+	{ID: "huggingface-token", Description: "Hugging Face 令牌", Severity: SeverityHigh,
+		Regex: `\b(hf_[A-Za-z0-9]{34})\b`, SecretGroup: 1, MinEntropy: 3.5, Keywords: []string{"hf_"}},
+	{ID: "pypi-token", Description: "PyPI 上传令牌", Severity: SeverityCritical,
+		Regex: `\b(pypi-AgEIcHlwaS5vcmc[A-Za-z0-9_-]{50,})`, SecretGroup: 1, Keywords: []string{"pypi-ageicHlwas5vcmc"}},
+	{ID: "npm-token", Description: "npm 访问令牌", Severity: SeverityCritical,
+		Regex: `\b(npm_[A-Za-z0-9]{36})\b`, SecretGroup: 1, Keywords: []string{"npm_"}},
+	{ID: "npmrc-auth", Description: ".npmrc 中的认证信息", Severity: SeverityHigh,
+		Regex: `(?:^|:)_(?:authToken|auth|password)\s*=\s*["']?([^\s"']+)`, SecretGroup: 1, Keywords: []string{"_auth", "_password"}, Path: `(?:^|/)\.?npmrc$`},
+	{ID: "jwt", Description: "JSON Web Token", Severity: SeverityMedium,
+		Regex: `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})\b`, SecretGroup: 1, Keywords: []string{"eyj"}},
+	{ID: "url-credentials", Description: "URL 中的用户名和密码", Severity: SeverityHigh,
+		Regex: `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@"']+:([^/\s:@"']{3,})@[^\s/"']+`, SecretGroup: 1, Keywords: []string{"://"}},
+	// 通用规则要求变量名以敏感词结尾，未加引号的赋值只在配置文件、脚本和 .env 文件中检查
+	{ID: "generic-assignment", Description: "配置中赋值给密码、密钥或令牌变量的值", Severity: SeverityMedium,
+		Regex:       `(?i)(?:^|[\s;&|(])(?:export\s+)?[A-Za-z0-9_.-]*(?:password|passwd|pwd|secret|token|api_?key|access_?key|secret_?key|private_?key|auth_?key|credentials?)\s*[:=]\s*["']?([^\s"'#()\[\]{},;$:<>]{8,})(?:["'\s]|$)`,
+		SecretGroup: 1, MinEntropy: 3.0, Keywords: []string{"pass", "pwd", "secret", "token", "key", "credential"},
+		Path: `(?i)(?:^|/)(?:\.?env(?:\.[^/]*)?|[^/]*\.(?:env|ini|cfg|conf|properties|toml|ya?ml|sh|bash|zsh|profile|bashrc)|\.pypirc|\.netrc|\.?git-credentials|credentials)$`},
+	{ID: "generic-quoted-assignment", Description: "代码中赋值给密码、密钥或令牌变量的字符串", Severity: SeverityMedium,
+		Regex:       `(?i)\b[A-Za-z0-9_]*(?:password|passwd|secret|token|api_?key|access_?key|secret_?key|private_?key|auth_?key)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`,
+		SecretGroup: 1, MinEntropy: 3.5, Keywords: []string{"pass", "secret", "token", "key"}},
+}
+
+// defaultSecretAllowRegexes 是始终忽略的值，例如文档中的示例密钥
+var defaultSecretAllowRegexes = []string{
+	`EXAMPLE`,
+	`^(?i:x{4,}|\*+|changeme|password|your[_-]?.*|<.*>|\$\{?[A-Za-z_][A-Za-z0-9_]*\}?|%\(?[A-Za-z_]+\)?s?)$`,
+	// 全小写的标识符通常是代码中的变量名或配置项的引用
+	`^[a-z_.]+$`,
+}
+
+// defaultSecretAllowPaths 是始终跳过的文件，文档、测试数据和包管理器元数据中大量出现示例或摘要
+var defaultSecretAllowPaths = []string{
+	`^/usr/share/(?:doc|man|info|locale)/`,
+	`/(?:testdata|tests?|examples?|docs?|man)/`,
+	`(?:^|/)(?:test_[^/]*\.py|[^/]*_test\.(?:go|py)|[^/]*\.(?:test|spec)\.[jt]s)$`,
+	`^/var/lib/(?:dpkg|rpm|apk)/`,
+	`^/etc/ssl/certs/`,
+}
+
+// secretScanner 是编译后的规则和允许列表
+type secretScanner struct {
+	rules        []SecretRule
+	allowPaths   []*regexp.Regexp
+	allowRegexes []*regexp.Regexp
+	errors       []string
+}
+
+// newSecretScanner 编译内置规则、规则文件中的规则和允许列表，出错的条目记录到 errors 后跳过
+func newSecretScanner(opts SecretScanOptions) *secretScanner {
+	s := &secretScanner{}
+	rules := append([]SecretRule{}, builtinSecretRules...)
+	for _, file := range opts.RuleFiles {
+		loaded, err := loadSecretRules(file)
+		if err != nil {
+			s.errors = append(s.errors, err.Error())
+			continue
+		}
+		rules = append(rules, loaded...)
+	}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			s.errors = append(s.errors, fmt.Sprintf("规则 %s 的正则无效: %v", rule.ID, err))
+			continue
+		}
+		if rule.SecretGroup < 0 || rule.SecretGroup > re.NumSubexp() {
+			s.errors = append(s.errors, fmt.Sprintf("规则 %s 的 secret_group 超出子匹配组数量", rule.ID))
+			continue
+		}
+		if rule.Severity == "" {
+			rule.Severity = SeverityMedium
+		}
+		keywords := make([]string, len(rule.Keywords))
+		for i, k := range rule.Keywords {
+			keywords[i] = strings.ToLower(k)
+		}
+		rule.Keywords = keywords
+		rule.re = re
+		if rule.Path != "" {
+			if rule.path, err = regexp.Compile(rule.Path); err != nil {
+				s.errors = append(s.errors, fmt.Sprintf("规则 %s 的路径正则无效: %v", rule.ID, err))
+				continue
+			}
+		}
+		s.rules = append(s.rules, rule)
+	}
+	s.allowPaths = s.compileAll(append(append([]string{}, defaultSecretAllowPaths...), opts.AllowPaths...))
+	s.allowRegexes = s.compileAll(append(append([]string{}, defaultSecretAllowRegexes...), opts.AllowRegexes...))
+	return s
+}
+
+func (s *secretScanner) compileAll(patterns []string) []*regexp.Regexp {
+	var list []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			s.errors = append(s.errors, fmt.Sprintf("允许列表中的正则 %q 无效: %v", p, err))
+			continue
+		}
+		list = append(list, re)
+	}
+	return list
+}
+
+// loadSecretRules 读取 YAML 规则文件
+func loadSecretRules(path string) ([]SecretRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取规则文件 %s 失败: %w", path, err)
+	}
+	var file struct {
+		Rules []SecretRule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析规则文件 %s 失败: %w", path, err)
+	}
+	for i, rule := range file.Rules {
+		if rule.ID == "" || rule.Regex == "" {
+			return nil, fmt.Errorf("规则文件 %s 的第 %d 条规则缺少 id 或 regex", path, i+1)
+		}
+	}
+	return file.Rules, nil
+}
+
+// ScanSecrets 在文件系统、环境变量、标签和构建历史中查找疑似泄露的凭据。
+// 文件和构建历史中的发现根据 extract 和镜像配置的 RootFS、History 确定所在的层
+func ScanSecrets(root string, imgCfg *v1.Image, extract *utils.ExtractInfo, opts SecretScanOptions, workers int) *SecretScanResult {
+	s := newSecretScanner(opts)
+	result := &SecretScanResult{Rules: len(s.rules), Findings: []SecretFinding{}, Errors: s.errors}
+	diffIDs := imgCfg.RootFS.DiffIDs
+	withLayer := func(f *SecretFinding, layer int) {
+		f.Layer = &layer
+		if layer < len(diffIDs) {
+			f.LayerDigest = diffIDs[layer].String()
+		}
+	}
+
+	var files []string
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if s.pathAllowed(imagePath(root, path) + "/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && info.Size() > 0 && info.Size() <= maxSecretFileSize && !s.pathAllowed(imagePath(root, path)) {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	var mu sync.Mutex
+	runParallel(len(files), workers, func(i int) {
+		p := imagePath(root, files[i])
+		findings := s.scanFile(files[i], p)
+		if findings == nil {
+			return
+		}
+		layer, ok := extract.Layer(p)
+		for j := range findings {
+			findings[j].Source = SecretSourceFile
+			findings[j].Path = p
+			if ok {
+				withLayer(&findings[j], layer)
+			}
+		}
+		mu.Lock()
+		result.Findings = append(result.Findings, findings...)
+		mu.Unlock()
+	})
+	result.FilesScanned = len(files)
+
+	for _, kv := range imgCfg.Config.Env {
+		key, _, _ := strings.Cut(kv, "=")
+		for _, f := range s.scanLine(kv, nil) {
+			f.Source, f.Key = SecretSourceEnv, key
+			result.Findings = append(result.Findings, f)
+		}
+	}
+	for _, key := range sortedLabelKeys(imgCfg.Config.Labels) {
+		for _, f := range s.scanLine(key+"="+imgCfg.Config.Labels[key], nil) {
+			f.Source, f.Key = SecretSourceLabel, key
+			result.Findings = append(result.Findings, f)
+		}
+	}
+	layer := 0
+	for i, h := range imgCfg.History {
+		for _, f := range s.scanLine(h.CreatedBy, nil) {
+			f.Source, f.Line = SecretSourceHistory, i+1
+			if !h.EmptyLayer {
+				withLayer(&f, layer)
+			}
+			result.Findings = append(result.Findings, f)
+		}
+		if !h.EmptyLayer {
+			layer++
+		}
+	}
+
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		a, b := result.Findings[i], result.Findings[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+	return result
+}
+
+func (s *secretScanner) pathAllowed(path string) bool {
+	for _, re := range s.allowPaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanFile 逐行扫描文本文件，包含 NUL 字节的二进制文件被跳过
+func (s *secretScanner) scanFile(path, imgPath string) []SecretFinding {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64*1024)
+	if head, _ := r.Peek(8000); bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+	var rules []int
+	for i, rule := range s.rules {
+		if rule.path == nil || rule.path.MatchString(imgPath) {
+			rules = append(rules, i)
+		}
+	}
+
+	var findings []SecretFinding
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxSecretFileSize)
+	for n := 1; scanner.Scan(); n++ {
+		for _, f := range s.scanLine(scanner.Text(), rules) {
+			f.Line = n
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// scanLine 对一行内容应用规则，rules 为 nil 时应用全部规则。同一个值只由第一条命中的规则报告
+func (s *secretScanner) scanLine(line string, rules []int) []SecretFinding {
+	if rules == nil {
+		for i := range s.rules {
+			rules = append(rules, i)
+		}
+	}
+	var findings []SecretFinding
+	reported := make(map[string]bool)
+	lower := []byte(strings.ToLower(line))
+	for _, i := range rules {
+		rule := &s.rules[i]
+		if !keywordsPresent(lower, rule.Keywords) {
+			continue
+		}
+		for _, loc := range rule.re.FindAllStringSubmatchIndex(line, -1) {
+			// 按子匹配组的位置隐藏密钥值，值的文本可能在匹配中更早出现，例如用户名与密码相同的 URL
+			start, end := loc[2*rule.SecretGroup], loc[2*rule.SecretGroup+1]
+			if start < 0 {
+				continue
+			}
+			value := line[start:end]
+			if value == "" || reported[value] || s.valueAllowed(value) {
+				continue
+			}
+			entropy := shannonEntropy(value)
+			if entropy < rule.MinEntropy {
+				continue
+			}
+			reported[value] = true
+			f := SecretFinding{
+				RuleID:      rule.ID,
+				Description: rule.Description,
+				Severity:    rule.Severity,
+				Match:       line[loc[0]:start] + redactSecret(value) + line[end:loc[1]],
+			}
+			if rule.MinEntropy > 0 {
+				f.Entropy = math.Round(entropy*100) / 100
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func (s *secretScanner) valueAllowed(value string) bool {
+	for _, re := range s.allowRegexes {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func keywordsPresent(lower []byte, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	for _, k := range keywords {
+		if bytes.Contains(lower, []byte(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecret 只保留值的前 4 个字符，较短的值全部隐藏
+func redactSecret(value string) string {
+	if len(value) < 12 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-4)
+}
+
+// shannonEntropy 计算字符串的香农熵，单位为每字符比特数
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var entropy float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,398 @@
+package analyze
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// licenseNode 是许可证表达式的语法树：叶子节点为单个许可证，其余为 AND/OR 组合
+type licenseNode struct {
+	op string
+	// id 为 SPDX 标识符，无法识别时为 LicenseRef- 开头的引用；exception 为 WITH 后的例外条款
+	id        string
+	exception string
+	kids      []*licenseNode
+}
+
+// String 按 SPDX 表达式语法输出，不同运算符的子表达式加括号
+func (n *licenseNode) String() string {
+	if n.op == "" {
+		if n.exception != "" {
+			return n.id + " WITH " + n.exception
+		}
+		return n.id
+	}
+	parts := make([]string, len(n.kids))
+	for i, k := range n.kids {
+		parts[i] = k.String()
+		if k.op != "" && k.op != n.op {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " "+n.op+" ")
+}
+
+// ids 返回表达式中出现的全部许可证，按出现顺序去重
+func (n *licenseNode) ids() []string {
+	if n.op == "" {
+		return []string{n.id}
+	}
+	var ids []string
+	for _, k := range n.kids {
+		for _, id := range k.ids() {
+			if !containsString(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// combineLicenses 用 op 连接多个表达式，只有一个时直接返回
+func combineLicenses(op string, nodes []*licenseNode) *licenseNode {
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	}
+	n := &licenseNode{op: op}
+	for _, k := range nodes {
+		// 相同运算符的子表达式展开，避免多余的括号
+		if k.op == op {
+			n.kids = append(n.kids, k.kids...)
+		} else {
+			n.kids = append(n.kids, k)
+		}
+	}
+	return n
+}
+
+// spdxIDs 是常见的 SPDX 标识符，声明的值与其中之一相同（不区分大小写）时直接采用
+var spdxIDs = []string{
+	"0BSD", "AFL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later", "Apache-1.1", "Apache-2.0", "Artistic-1.0", "Artistic-1.0-Perl", "Artistic-2.0",
+	"BSD-1-Clause", "BSD-2-Clause", "BSD-3-Clause", "BSD-4-Clause", "BSL-1.0", "CC-BY-3.0", "CC-BY-4.0", "CC-BY-SA-4.0", "CC0-1.0",
+	"CDDL-1.0", "CDDL-1.1", "EPL-1.0", "EPL-2.0", "EUPL-1.2", "GPL-1.0-only", "GPL-1.0-or-later", "GPL-2.0-only", "GPL-2.0-or-later",
+	"GPL-3.0-only", "GPL-3.0-or-later", "HPND", "ISC", "LGPL-2.0-only", "LGPL-2.0-or-later", "LGPL-2.1-only", "LGPL-2.1-or-later",
+	"LGPL-3.0-only", "LGPL-3.0-or-later", "MIT", "MIT-0", "MPL-1.1", "MPL-2.0", "MS-PL", "NCSA", "OpenSSL", "PostgreSQL", "PSF-2.0",
+	"Python-2.0", "Ruby", "SSPL-1.0", "Unicode-DFS-2016", "Unlicense", "UPL-1.0", "Vim", "W3C", "WTFPL", "X11", "Zlib", "ZPL-2.1",
+}
+
+// licenseAliases 将规范化后的常见写法映射为 SPDX 标识符，键由 licenseKey 生成。
+// 覆盖 Debian copyright 的简称、RPM 的 License 标签、PyPI 分类器和 npm 的非标准写法
+var licenseAliases = map[string]string{
+	"mit": "MIT", "expat": "MIT", "mitx11": "MIT", "mit0": "MIT-0", "mitnoattribution": "MIT-0",
+	"apache": "Apache-2.0", "apache2": "Apache-2.0", "asl2": "Apache-2.0", "apl2": "Apache-2.0", "apache1.1": "Apache-1.1",
+	"bsd3clause": "BSD-3-Clause", "bsd3": "BSD-3-Clause", "newbsd": "BSD-3-Clause", "modifiedbsd": "BSD-3-Clause", "revisedbsd": "BSD-3-Clause",
+	"bsd2clause": "BSD-2-Clause", "bsd2": "BSD-2-Clause", "simplifiedbsd": "BSD-2-Clause", "freebsd": "BSD-2-Clause",
+	"bsd4clause": "BSD-4-Clause", "bsd0clause": "0BSD", "0bsd": "0BSD",
+	// 未注明条款数的 BSD 无法对应到具体的 SPDX 标识符
+	"bsd": "LicenseRef-BSD",
+	"isc": "ISC", "iscl": "ISC",
+	"gpl": "GPL-1.0-or-later", "gpl+": "GPL-1.0-or-later", "gpl1": "GPL-1.0-only", "gpl1+": "GPL-1.0-or-later",
+	"gpl2": "GPL-2.0-only", "gpl2+": "GPL-2.0-or-later", "gpl3": "GPL-3.0-only", "gpl3+": "GPL-3.0-or-later",
+	"lgpl": "LGPL-2.0-or-later", "lgpl+": "LGPL-2.0-or-later", "lgpl2": "LGPL-2.0-only", "lgpl2+": "LGPL-2.0-or-later",
+	"lgpl2.1": "LGPL-2.1-only", "lgpl2.1+": "LGPL-2.1-or-later", "lgpl3": "LGPL-3.0-only", "lgpl3+": "LGPL-3.0-or-later",
+	"agpl": "AGPL-3.0-or-later", "agpl3": "AGPL-3.0-only", "agpl3+": "AGPL-3.0-or-later",
+	"mpl": "MPL-2.0", "mpl1.1": "MPL-1.1", "mpl2": "MPL-2.0",
+	"epl": "EPL-1.0", "epl1": "EPL-1.0", "epl2": "EPL-2.0",
+	"cddl": "CDDL-1.0", "cddl1": "CDDL-1.0", "cddl1.1": "CDDL-1.1",
+	"psf": "PSF-2.0", "psf2": "PSF-2.0", "python": "Python-2.0", "python2": "Python-2.0",
+	"artistic": "Artistic-1.0", "artistic1": "Artistic-1.0", "artistic2": "Artistic-2.0", "perl": "Artistic-1.0-Perl",
+	"boost": "BSL-1.0", "bsl1": "BSL-1.0", "bsl": "BSL-1.0",
+	"zlib": "Zlib", "zliblibpng": "Zlib", "openssl": "OpenSSL", "unlicense": "Unlicense", "wtfpl": "WTFPL",
+	"cc0": "CC0-1.0", "cc01": "CC0-1.0", "ccby4": "CC-BY-4.0", "ccby3": "CC-BY-3.0", "ccbysa4": "CC-BY-SA-4.0",
+	"hpnd": "HPND", "historicalpermissionnoticeanddisclaimer": "HPND", "upl": "UPL-1.0", "upl1": "UPL-1.0",
+	"ruby": "Ruby", "postgresql": "PostgreSQL", "vim": "Vim", "x11": "X11", "zpl2.1": "ZPL-2.1", "eupl1.2": "EUPL-1.2",
+	"sspl": "SSPL-1.0", "sspl1": "SSPL-1.0", "publicdomain": "LicenseRef-Public-Domain",
+}
+
+var (
+	orLaterPattern      = regexp.MustCompile(`(?i)(?:,?\s*|-)(?:or\s+(?:any\s+)?later(?:\s+version)?|or-later)`)
+	licenseTokenPattern = regexp.MustCompile(`(?i)\(|\)|\s+(?:and|or|with)\s+|\s*[&|]\s*`)
+	licenseWordsPattern = regexp.MustCompile(`\b(?:the|gnu|license|licence|licensed|version|only|software)\b`)
+	licenseVPattern     = regexp.MustCompile(`v(\d)`)
+	licenseDotZero      = regexp.MustCompile(`(\d)\.0(\D|$)`)
+	licenseRefPattern   = regexp.MustCompile(`[^A-Za-z0-9.]+`)
+)
+
+// licenseKey 将许可证名称规范化为查找 licenseAliases 的键，例如
+// "GNU General Public License v2 or later" 和 "GPL-2+" 都得到 gpl2+
+func licenseKey(name string) string {
+	s := strings.ToLower(strings.TrimSpace(name))
+	s = orLaterPattern.ReplaceAllString(s, "+")
+	for _, r := range []struct{ from, to string }{
+		{"gnu affero general public", "agpl"},
+		{"gnu lesser general public", "lgpl"},
+		{"gnu library general public", "lgpl"},
+		{"lesser general public", "lgpl"},
+		{"library general public", "lgpl"},
+		{"affero general public", "agpl"},
+		{"general public", "gpl"},
+		{"mozilla public", "mpl"},
+		{"eclipse public", "epl"},
+		{"python software foundation", "psf"},
+		{"common development and distribution", "cddl"},
+		{"boost software", "boost"},
+		{"universal permissive", "upl"},
+		{"creative commons zero", "cc0"},
+		{"creative commons attribution share alike", "ccbysa"},
+		{"creative commons attribution", "ccby"},
+		{"public domain", "publicdomain"},
+		{"3-clause", "3clause"},
+		{"2-clause", "2clause"},
+	} {
+		s = strings.ReplaceAll(s, r.from, r.to)
+	}
+	s = licenseWordsPattern.ReplaceAllString(s, "")
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '+':
+			return r
+		}
+		return -1
+	}, s)
+	s = licenseVPattern.ReplaceAllString(s, "$1")
+	for licenseDotZero.MatchString(s) {
+		s = licenseDotZero.ReplaceAllString(s, "$1$2")
+	}
+	return s
+}
+
+// normalizeLicenseID 将单个许可证名称转换为 SPDX 标识符，无法识别时返回 LicenseRef- 开头的引用
+func normalizeLicenseID(name string) string {
+	name = strings.TrimSpace(name)
+	for _, id := range spdxIDs {
+		if strings.EqualFold(id, name) {
+			return id
+		}
+	}
+	// 旧的 SPDX 标识符，例如 GPL-2.0+、LGPL-2.1
+	if id, ok := licenseAliases[licenseKey(name)]; ok {
+		return id
+	}
+	// PyPI 分类器和部分元数据在括号中给出简称，例如 "ISC License (ISCL)"
+	if i := strings.LastIndex(name, "("); i > 0 && strings.HasSuffix(name, ")") {
+		if id := normalizeLicenseID(name[i+1 : len(name)-1]); !strings.HasPrefix(id, "LicenseRef-") {
+			return id
+		}
+		if id := normalizeLicenseID(name[:i]); !strings.HasPrefix(id, "LicenseRef-") {
+			return id
+		}
+	}
+	if strings.HasPrefix(name, "LicenseRef-") {
+		return name
+	}
+	ref := strings.Trim(licenseRefPattern.ReplaceAllString(name, "-"), "-")
+	if len(ref) > 64 {
+		ref = ref[:64]
+	}
+	if ref == "" {
+		ref = "unknown"
+	}
+	return "LicenseRef-" + ref
+}
+
+// parseLicenseExpression 解析声明的许可证，支持 SPDX 表达式以及 Debian、RPM 使用的 and/or 写法，
+// 例如 "GPLv2+ and LGPLv2+"、"GPL-1+ or Artistic"、"(MIT OR Apache-2.0)"
+func parseLicenseExpression(declared string) *licenseNode {
+	declared = strings.TrimSpace(orLaterPattern.ReplaceAllString(declared, "+"))
+	if declared == "" || strings.EqualFold(declared, "NOASSERTION") || strings.EqualFold(declared, "UNKNOWN") || strings.EqualFold(declared, "NONE") {
+		return nil
+	}
+	// 许可证名称本身可能包含 and/or，例如 "Common Development and Distribution License"，
+	// 整体能识别为已知许可证时不再拆分
+	if id := normalizeLicenseID(declared); !strings.HasPrefix(id, "LicenseRef-") {
+		return &licenseNode{id: id}
+	}
+	var tokens []string
+	last := 0
+	for _, loc := range licenseTokenPattern.FindAllStringIndex(declared, -1) {
+		tokens = append(tokens, declared[last:loc[0]], strings.ToUpper(strings.TrimSpace(declared[loc[0]:loc[1]])))
+		last = loc[1]
+	}
+	tokens = append(tokens, declared[last:])
+	var cleaned []string
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			switch t {
+			case "&":
+				t = "AND"
+			case "|":
+				t = "OR"
+			}
+			cleaned = append(cleaned, t)
+		}
+	}
+	p := &licenseExprParser{tokens: cleaned}
+	n := p.or()
+	if n == nil || p.pos < len(p.tokens) {
+		// 无法解析时整体作为一个许可证名称
+		return &licenseNode{id: normalizeLicenseID(declared)}
+	}
+	return n
+}
+
+type licenseExprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *licenseExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *licenseExprParser) or() *licenseNode {
+	var nodes []*licenseNode
+	for {
+		n := p.and()
+		if n == nil {
+			return nil
+		}
+		nodes = append(nodes, n)
+		if p.peek() != "OR" {
+			return combineLicenses("OR", nodes)
+		}
+		p.pos++
+	}
+}
+
+func (p *licenseExprParser) and() *licenseNode {
+	var nodes []*licenseNode
+	for {
+		n := p.term()
+		if n == nil {
+			return nil
+		}
+		nodes = append(nodes, n)
+		if p.peek() != "AND" {
+			return combineLicenses("AND", nodes)
+		}
+		p.pos++
+	}
+}
+
+func (p *licenseExprParser) term() *licenseNode {
+	switch t := p.peek(); t {
+	case "", "AND", "OR", "WITH", ")":
+		return nil
+	case "(":
+		p.pos++
+		n := p.or()
+		if n == nil || p.peek() != ")" {
+			return nil
+		}
+		p.pos++
+		return n
+	default:
+		p.pos++
+		n := &licenseNode{id: normalizeLicenseID(t)}
+		if p.peek() == "WITH" {
+			p.pos++
+			exc := p.peek()
+			switch exc {
+			case "", "AND", "OR", "WITH", "(", ")":
+				return nil
+			}
+			p.pos++
+			n.exception = exc
+		}
+		return n
+	}
+}
+
+// licenseTextRule 是许可证全文的识别规则：规范化后的文本需要包含 all 中的每一组短语之一，并且不包含 none 中的短语
+type licenseTextRule struct {
+	id   string
+	all  [][]string
+	none []string
+}
+
+// licenseTextRules 按从具体到一般的顺序排列，LGPL、AGPL 的全文中也会出现 GPL 的字样
+var licenseTextRules = []licenseTextRule{
+	{id: "AGPL-3.0-only", all: [][]string{{"gnu affero general public license"}, {"version 3"}}},
+	{id: "LGPL-3.0-only", all: [][]string{{"gnu lesser general public license"}, {"version 3"}}},
+	{id: "LGPL-2.1-only", all: [][]string{{"gnu lesser general public license"}, {"version 2 1"}}},
+	{id: "LGPL-2.0-only", all: [][]string{{"gnu library general public license"}, {"version 2"}}},
+	{id: "GPL-3.0-only", all: [][]string{{"gnu general public license"}, {"version 3 29 june 2007", "either version 3 of the license"}},
+		none: []string{"gnu lesser general public license", "gnu affero general public license"}},
+	{id: "GPL-2.0-only", all: [][]string{{"gnu general public license"}, {"version 2 june 1991", "either version 2 of the license"}},
+		none: []string{"gnu lesser general public license", "gnu library general public license"}},
+	{id: "Apache-2.0", all: [][]string{{"apache license"}, {"version 2 0"}}},
+	{id: "MPL-2.0", all: [][]string{{"mozilla public license version 2 0", "mozilla public license v 2 0"}}},
+	{id: "EPL-2.0", all: [][]string{{"eclipse public license v 2 0", "eclipse public license version 2 0"}}},
+	{id: "EPL-1.0", all: [][]string{{"eclipse public license v 1 0", "eclipse public license version 1 0"}}},
+	{id: "CDDL-1.0", all: [][]string{{"common development and distribution license"}}},
+	{id: "BSL-1.0", all: [][]string{{"boost software license version 1 0"}}},
+	{id: "PSF-2.0", all: [][]string{{"python software foundation license version 2"}}},
+	{id: "Artistic-2.0", all: [][]string{{"the artistic license 2 0"}}},
+	{id: "OpenSSL", all: [][]string{{"this product includes software developed by the openssl project"}}},
+	{id: "Unlicense", all: [][]string{{"this is free and unencumbered software released into the public domain"}}},
+	{id: "CC0-1.0", all: [][]string{{"cc0 1 0 universal", "creative commons legal code cc0"}}},
+	{id: "WTFPL", all: [][]string{{"do what the fuck you want to public license"}}},
+	{id: "Zlib", all: [][]string{{"altered source versions must be plainly marked as such"}, {"this notice may not be removed or altered from any source distribution"}}},
+	{id: "BSD-3-Clause", all: [][]string{{"redistribution and use in source and binary forms"},
+		{"neither the name of", "the names of its contributors may not be used", "the name of the author may not be used"}},
+		none: []string{"all advertising materials mentioning features"}},
+	{id: "BSD-4-Clause", all: [][]string{{"redistribution and use in source and binary forms"}, {"all advertising materials mentioning features"}}},
+	{id: "BSD-2-Clause", all: [][]string{{"redistribution and use in source and binary forms"}},
+		none: []string{"neither the name of", "the names of its contributors may not be used", "the name of the author may not be used", "all advertising materials mentioning features"}},
+	{id: "MIT", all: [][]string{{"permission is hereby granted free of charge to any person obtaining a copy"}, {"the above copyright notice and this permission notice shall be included"}}},
+	{id: "ISC", all: [][]string{{"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted",
+		"permission to use copy modify and distribute this software for any purpose with or without fee is hereby granted"}}},
+}
+
+// classifyLicenseText 根据许可证全文中的特征短语识别许可证，返回识别出的全部 SPDX 标识符
+func classifyLicenseText(text string) []string {
+	normalized := normalizeLicenseText(text)
+	var ids []string
+	for _, rule := range licenseTextRules {
+		if rule.matches(normalized) {
+			ids = append(ids, rule.id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (r licenseTextRule) matches(text string) bool {
+	for _, phrase := range r.none {
+		if strings.Contains(text, phrase) {
+			return false
+		}
+	}
+	for _, alternatives := range r.all {
+		found := false
+		for _, phrase := range alternatives {
+			if strings.Contains(text, phrase) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLicenseText 转为小写，并将标点和连续空白替换为单个空格，消除排版差异
+func normalizeLicenseText(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	space := true
+	for _, r := range strings.ToLower(text) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
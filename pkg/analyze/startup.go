@@ -0,0 +1,354 @@
+package analyze
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// StartupReport 是对 Entrypoint 和 Cmd 的静态检查结果，判断容器能否启动
+type StartupReport struct {
+	WouldStart bool `json:"would_start"`
+	// Reason 为无法启动的第一个原因，能启动时为最终执行的程序说明
+	Reason string `json:"reason"`
+	// Command 为运行时执行的参数，即 Entrypoint 后接 Cmd
+	Command []string `json:"command"`
+	// ShellForm 表示命令为 /bin/sh -c 形式（Dockerfile 中的 shell 形式）
+	ShellForm        bool   `json:"shell_form"`
+	WorkingDir       string `json:"working_dir"`
+	WorkingDirExists bool   `json:"working_dir_exists"`
+	// Chain 为从命令到最终执行的程序的解析过程：命令、shebang 解释器、动态链接器和依赖库
+	Chain    []StartupStep `json:"chain"`
+	Problems []string      `json:"problems"`
+	Warnings []string      `json:"warnings"`
+}
+
+// StartupStep 是解析链上的一个文件
+type StartupStep struct {
+	// Kind 为 command、shell-command（shell 形式中执行的命令）、interpreter、loader 或 library
+	Kind string `json:"kind"`
+	// Name 为配置或文件中写的名称，Path 为在镜像中找到的路径
+	Name     string `json:"name"`
+	Path     string `json:"path,omitempty"`
+	Found    bool   `json:"found"`
+	FileType string `json:"file_type,omitempty"`
+}
+
+const (
+	StartupStepCommand      = "command"
+	StartupStepShellCommand = "shell-command"
+	StartupStepInterpreter  = "interpreter"
+	StartupStepLoader       = "loader"
+	StartupStepLibrary      = "library"
+)
+
+// maxShebangDepth 是内核允许的解释器嵌套层数
+const maxShebangDepth = 4
+
+// shellBuiltins 是 POSIX shell 的内置命令和关键字，shell 形式的命令以它们开头时不查找 PATH
+var shellBuiltins = []string{
+	":", ".", "[", "!", "{", "alias", "bg", "break", "case", "cd", "command", "continue", "echo", "eval", "exit", "export",
+	"false", "fg", "for", "getopts", "if", "jobs", "kill", "printf", "pwd", "read", "readonly", "return", "set", "shift",
+	"source", "test", "times", "trap", "true", "type", "ulimit", "umask", "unalias", "unset", "until", "wait", "while",
+}
+
+// goArchToELF 将镜像配置中的 GOARCH 风格架构名映射为 ELFFile.Arch
+var goArchToELF = map[string]string{
+	"amd64": "x86_64", "arm64": "aarch64", "386": "386", "arm": "arm", "ppc64le": "ppc64", "ppc64": "ppc64",
+	"s390x": "s390", "riscv64": "riscv", "mips64le": "mips", "loong64": "loongarch",
+}
+
+type startupChecker struct {
+	root     string
+	imgCfg   *v1.Image
+	dirs     []string
+	resolver *libraryResolver
+	report   *StartupReport
+	// libraries 记录已检查过的依赖库，避免重复
+	libraries map[string]bool
+}
+
+// CheckStartup 按容器运行时的方式解析 Entrypoint 和 Cmd：在 PATH 中查找可执行文件，
+// 跟随 shebang 找到解释器，检查 ELF 的动态链接器和依赖库是否存在，并检查工作目录。
+// shell 形式的命令还会检查镜像中是否有 /bin/sh，以及其中执行的第一个命令能否找到
+func CheckStartup(root string, imgCfg *v1.Image) *StartupReport {
+	report := &StartupReport{Command: []string{}, Chain: []StartupStep{}, Problems: []string{}, Warnings: []string{}}
+	c := &startupChecker{
+		root:      root,
+		imgCfg:    imgCfg,
+		dirs:      imagePATH(imgCfg.Config.Env),
+		resolver:  newLibraryResolver(root, imgCfg.Config.Env),
+		report:    report,
+		libraries: make(map[string]bool),
+	}
+	report.Command = append(append(report.Command, imgCfg.Config.Entrypoint...), imgCfg.Config.Cmd...)
+	c.checkWorkingDir()
+
+	if len(report.Command) == 0 || report.Command[0] == "" {
+		report.Problems = append(report.Problems, "没有设置 Entrypoint 和 Cmd，运行时没有可执行的命令")
+	} else {
+		argv := report.Command
+		report.ShellForm = len(argv) >= 3 && argv[1] == "-c" && containsString([]string{"sh", "bash", "ash", "dash"}, path.Base(argv[0]))
+		final := c.resolveExecutable(StartupStepCommand, argv[0], 0)
+		if report.ShellForm {
+			if final == "" && !report.Chain[0].Found {
+				report.Problems[len(report.Problems)-1] += "，shell 形式的命令需要镜像中有 " + argv[0]
+			} else {
+				c.checkShellCommand(argv[2])
+			}
+		}
+	}
+
+	report.WouldStart = len(report.Problems) == 0
+	if report.WouldStart {
+		report.Reason = c.describeChain()
+	} else {
+		report.Reason = report.Problems[0]
+	}
+	return report
+}
+
+// checkWorkingDir 检查工作目录。不存在时运行时会以 root 身份创建，非 root 用户可能无法在其中写入
+func (c *startupChecker) checkWorkingDir() {
+	dir := c.imgCfg.Config.WorkingDir
+	if dir == "" {
+		dir = "/"
+	}
+	c.report.WorkingDir = dir
+	if !path.IsAbs(dir) {
+		c.report.Problems = append(c.report.Problems, fmt.Sprintf("工作目录 %s 不是绝对路径", dir))
+		return
+	}
+	p, err := utils.SecureJoin(c.root, dir)
+	if err != nil {
+		c.report.Warnings = append(c.report.Warnings, fmt.Sprintf("工作目录 %s 无法解析: %v", dir, err))
+		return
+	}
+	info, err := os.Stat(p)
+	switch {
+	case err != nil:
+		c.report.Warnings = append(c.report.Warnings, fmt.Sprintf("工作目录 %s 在镜像中不存在，运行时会以 root 身份创建，非 root 用户可能无法在其中写入", dir))
+	case !info.IsDir():
+		c.report.Problems = append(c.report.Problems, fmt.Sprintf("工作目录 %s 不是目录，运行时无法切换到该目录", dir))
+	default:
+		c.report.WorkingDirExists = true
+	}
+}
+
+// resolveExecutable 解析一个要执行的文件并检查它能否被执行，返回镜像内的最终路径，失败时记录问题并返回空字符串。
+// 不含 / 的名称在 PATH 中查找；含 / 的相对路径相对于工作目录
+func (c *startupChecker) resolveExecutable(kind, name string, depth int) string {
+	var candidates []string
+	switch {
+	case path.IsAbs(name):
+		candidates = []string{path.Clean(name)}
+	case strings.Contains(name, "/"):
+		candidates = []string{path.Join(c.report.WorkingDir, name)}
+	default:
+		for _, dir := range c.dirs {
+			candidates = append(candidates, path.Join("/", dir, name))
+		}
+	}
+	res := resolveCommand(c.root, name, candidates)
+	step := StartupStep{Kind: kind, Name: name, Path: res.Resolved, Found: res.Found, FileType: res.FileType}
+	c.report.Chain = append(c.report.Chain, step)
+
+	if !res.Found {
+		switch {
+		case len(res.NotExecutable) > 0:
+			c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 没有可执行权限（permission denied）", res.NotExecutable[0]))
+		case strings.Contains(name, "/"):
+			c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 在镜像中不存在（no such file or directory）", candidates[0]))
+		default:
+			c.report.Problems = append(c.report.Problems, fmt.Sprintf("在 PATH（%s）中找不到 %s（executable file not found in $PATH）", strings.Join(c.dirs, ":"), name))
+		}
+		return ""
+	}
+
+	hostPath := filepath.Join(c.root, res.Resolved)
+	switch res.FileType {
+	case CommandFileELF:
+		if !c.checkELF(res.Resolved, hostPath) {
+			return ""
+		}
+	case CommandFileScript:
+		if depth >= maxShebangDepth {
+			c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 的解释器嵌套超过 %d 层（too many levels of symbolic links）", res.Resolved, maxShebangDepth))
+			return ""
+		}
+		interp, args, crlf := readShebang(hostPath)
+		if crlf {
+			c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 的 shebang 行以 CRLF 结尾，解释器或其参数会带上 \\r（Windows 换行）", res.Resolved))
+			return ""
+		}
+		if interp == "" {
+			c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 的 shebang 行没有指定解释器", res.Resolved))
+			return ""
+		}
+		final := c.resolveExecutable(StartupStepInterpreter, interp, depth+1)
+		// /usr/bin/env 再按 PATH 查找真正的解释器
+		if final != "" && path.Base(interp) == "env" {
+			if target := envTarget(args); target != "" {
+				return c.resolveExecutable(StartupStepInterpreter, target, depth+1)
+			}
+		}
+		return final
+	default:
+		c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 既不是 ELF 文件也没有 shebang 行，无法直接执行（exec format error）", res.Resolved))
+		return ""
+	}
+	return res.Resolved
+}
+
+// checkELF 检查 ELF 的架构是否与镜像一致、动态链接器和依赖库是否存在
+func (c *startupChecker) checkELF(imgPath, hostPath string) bool {
+	file := readELFFile(c.root, hostPath)
+	if file == nil {
+		c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 不是可执行的 ELF 文件", imgPath))
+		return false
+	}
+	file.Path = imgPath
+	if want, ok := goArchToELF[c.imgCfg.Architecture]; ok && file.Arch != want {
+		c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 的架构为 %s，与镜像的 %s 不一致（exec format error）", imgPath, file.Arch, c.imgCfg.Architecture))
+		return false
+	}
+	if file.Interpreter == "" {
+		return true
+	}
+
+	resolved, _, err := utils.ResolveInRoot(c.root, file.Interpreter)
+	found := false
+	if err == nil {
+		if info, err := os.Stat(filepath.Join(c.root, resolved)); err == nil && info.Mode().IsRegular() {
+			found = true
+		}
+	}
+	step := StartupStep{Kind: StartupStepLoader, Name: file.Interpreter, Found: found}
+	if found {
+		step.Path, step.FileType = resolved, CommandFileELF
+	}
+	c.report.Chain = append(c.report.Chain, step)
+	if !found {
+		c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 的动态链接器 %s 在镜像中不存在，执行时报 no such file or directory", imgPath, file.Interpreter))
+		return false
+	}
+	c.libraries[resolved] = true
+	return c.checkLibraries(file)
+}
+
+// checkLibraries 按 ld.so 的规则逐层解析依赖库，报告找不到的库
+func (c *startupChecker) checkLibraries(file *ELFFile) bool {
+	ok := true
+	queue := []*ELFFile{file}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		for _, lib := range obj.Needed {
+			p := c.resolver.resolve(obj, lib.Name)
+			if p == "" {
+				c.report.Chain = append(c.report.Chain, StartupStep{Kind: StartupStepLibrary, Name: lib.Name})
+				c.report.Problems = append(c.report.Problems, fmt.Sprintf("%s 依赖的 %s 在镜像中找不到（error while loading shared libraries）", obj.Path, lib.Name))
+				ok = false
+				continue
+			}
+			if c.libraries[p] {
+				continue
+			}
+			c.libraries[p] = true
+			c.report.Chain = append(c.report.Chain, StartupStep{Kind: StartupStepLibrary, Name: lib.Name, Path: p, Found: true, FileType: CommandFileELF})
+			hostPath, err := utils.SecureJoin(c.root, p)
+			if err != nil {
+				continue
+			}
+			if dep := readELFFile(c.root, hostPath); dep != nil {
+				dep.Path = p
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return ok
+}
+
+// checkShellCommand 检查 shell 形式命令中第一个执行的程序。
+// 跳过开头的变量赋值和 exec；命令名包含变量、引号等需要 shell 展开的字符时不检查
+func (c *startupChecker) checkShellCommand(script string) {
+	fields := strings.Fields(script)
+	for len(fields) > 0 {
+		f := fields[0]
+		if f == "exec" || (strings.Contains(f, "=") && !strings.ContainsAny(f[:strings.Index(f, "=")], "/$")) {
+			fields = fields[1:]
+			continue
+		}
+		break
+	}
+	if len(fields) == 0 {
+		return
+	}
+	name := fields[0]
+	if strings.ContainsAny(name, "$`'\"\\(){}|;&<>*?[]~") || containsString(shellBuiltins, name) {
+		return
+	}
+	c.resolveExecutable(StartupStepShellCommand, name, 0)
+}
+
+// readShebang 读取脚本的 shebang 行，返回解释器、参数和行尾是否为 CRLF
+func readShebang(hostPath string) (string, []string, bool) {
+	f, err := os.Open(hostPath)
+	if err != nil {
+		return "", nil, false
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(io.LimitReader(f, 256)).ReadBytes('\n')
+	line = bytes.TrimPrefix(bytes.TrimSuffix(line, []byte("\n")), []byte("#!"))
+	crlf := bytes.HasSuffix(line, []byte("\r"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return "", nil, crlf
+	}
+	// 内核只将解释器后的剩余部分作为一个参数，这里按空白拆分以便找到 env 要执行的命令
+	return fields[0], fields[1:], crlf
+}
+
+// envTarget 返回 env 的参数中要执行的命令，跳过选项和变量赋值
+func envTarget(args []string) string {
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") || strings.Contains(a, "=") {
+			continue
+		}
+		return a
+	}
+	return ""
+}
+
+// describeChain 生成能启动时的说明，例如 "/app/run.sh -> /bin/bash (ELF，动态链接器 /lib64/ld-linux-x86-64.so.2)"
+func (c *startupChecker) describeChain() string {
+	var parts []string
+	loader := ""
+	for _, s := range c.report.Chain {
+		switch s.Kind {
+		case StartupStepCommand, StartupStepInterpreter, StartupStepShellCommand:
+			parts = append(parts, s.Path)
+		case StartupStepLoader:
+			if loader == "" {
+				loader = s.Path
+			}
+		}
+	}
+	desc := "将执行 " + strings.Join(parts, " -> ")
+	if loader != "" {
+		desc += "，动态链接器 " + loader
+	}
+	if c.report.ShellForm {
+		desc += "（shell 形式）"
+	}
+	return desc
+}
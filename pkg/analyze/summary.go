@@ -1,17 +1,86 @@
 package analyze
 
+import "time"
+
 type Summary struct {
-	Architecture   string          `json:"architecture"`
-	OS             string          `json:"os"`
-	Env            []string        `json:"env"`
-	OSInfo         string          `json:"os_info"`
-	PythonPackages []string        `json:"python_packages"`
-	Tools          map[string]bool `json:"tools"`
+	Architecture          string                   `json:"architecture"`
+	OS                    string                   `json:"os"`
+	Env                   []string                 `json:"env"`
+	OSInfo                string                   `json:"os_info"`
+	Libc                  *LibcInfo                `json:"libc,omitempty"`
+	PythonPackages        []string                 `json:"python_packages"`
+	PythonEnvironments    []PythonEnvironment      `json:"python_environments,omitempty"`
+	PythonCommands        []PythonCommand          `json:"python_commands,omitempty"`
+	PythonDependencyCheck []PythonDependencyCheck  `json:"python_dependency_check,omitempty"`
+	CondaEnvironments     []CondaEnvironment       `json:"conda_environments,omitempty"`
+	PythonIntegrity       []PythonPackageIntegrity `json:"python_integrity,omitempty"`
+	OSIntegrity           []OSIntegrityReport      `json:"os_integrity,omitempty"`
+	Node                  *NodeInventory           `json:"node,omitempty"`
+	GoBinaries            []GoBinary               `json:"go_binaries,omitempty"`
+	Java                  *JavaInventory           `json:"java,omitempty"`
+	Packages              []Package                `json:"packages,omitempty"`
+	ELFFiles              []ELFFile                `json:"elf_files,omitempty"`
+	CUDA                  *CUDAInventory           `json:"cuda,omitempty"`
+	Models                *ModelInventory          `json:"models,omitempty"`
+	Runtimes              []Runtime                `json:"runtimes,omitempty"`
+	Commands              []CommandResolution      `json:"commands,omitempty"`
+	Secrets               *SecretScanResult        `json:"secrets,omitempty"`
+	Findings              []Finding                `json:"findings,omitempty"`
+	Vulnerabilities       *VulnerabilityReport     `json:"vulnerabilities,omitempty"`
+	Malware               *MalwareScanResult       `json:"malware,omitempty"`
+	Licenses              *LicenseReport           `json:"licenses,omitempty"`
+	Certificates          *CertificateReport       `json:"certificates,omitempty"`
+	Accounts              *AccountReport           `json:"accounts,omitempty"`
+	Privileges            *PrivilegeReport         `json:"privileges,omitempty"`
+	Startup               *StartupReport           `json:"startup,omitempty"`
+	Tools                 map[string]bool          `json:"tools"`
 }
 
 type AnalyzeOptions struct {
-	CheckOSInfo         bool     `json:"check_os_info"`
-	CheckPythonPackages bool     `json:"check_python_packages"`
-	CheckCommonTools    bool     `json:"check_common_tools"`
-	SpecificCommands    []string `json:"specific_commands"`
+	CheckOSInfo            bool `json:"check_os_info"`
+	CheckPythonPackages    bool `json:"check_python_packages"`
+	CheckPythonEnvs        bool `json:"check_python_envs"`
+	CheckPythonDeps        bool `json:"check_python_deps"`
+	CheckConda             bool `json:"check_conda"`
+	VerifyPythonRecords    bool `json:"verify_python_records"`
+	VerifyOSPackages       bool `json:"verify_os_packages"`
+	CheckNode              bool `json:"check_node"`
+	CheckGoBinaries        bool `json:"check_go_binaries"`
+	CheckJava              bool `json:"check_java"`
+	CheckPackageEcosystems bool `json:"check_package_ecosystems"`
+	CheckELF               bool `json:"check_elf"`
+	CheckCUDA              bool `json:"check_cuda"`
+	CheckModels            bool `json:"check_models"`
+	CheckRuntimes          bool `json:"check_runtimes"`
+	CheckSecrets           bool `json:"check_secrets"`
+	// SecretRuleFiles 为额外的密钥规则文件，SecretAllowPaths 和 SecretAllowRegexes 为允许列表
+	SecretRuleFiles    []string `json:"secret_rule_files"`
+	SecretAllowPaths   []string `json:"secret_allow_paths"`
+	SecretAllowRegexes []string `json:"secret_allow_regexes"`
+	CheckMalware       bool     `json:"check_malware"`
+	// MalwareRuleFiles 为 YARA 子集语法的规则文件，MalwareHashLists 为已知恶意文件的 SHA-256 列表
+	MalwareRuleFiles []string `json:"malware_rule_files"`
+	MalwareHashLists []string `json:"malware_hash_lists"`
+	// MalwareMaxFileSize 为内容规则扫描的最大文件大小，0 表示使用默认值
+	MalwareMaxFileSize int64 `json:"malware_max_file_size"`
+	CheckLicenses      bool  `json:"check_licenses"`
+	// LicenseAllow 和 LicenseDeny 为许可证策略的允许和禁止列表，支持 * 通配符，例如 AGPL-*
+	LicenseAllow      []string `json:"license_allow"`
+	LicenseDeny       []string `json:"license_deny"`
+	CheckCertificates bool     `json:"check_certificates"`
+	// RequiredCAs 为镜像必须信任的内部 CA，每项可以是 SHA-256 指纹、CN 或主题的一部分
+	RequiredCAs     []string `json:"required_cas"`
+	CheckAccounts   bool     `json:"check_accounts"`
+	CheckPrivileges bool     `json:"check_privileges"`
+	CheckStartup    bool     `json:"check_startup"`
+	CheckHardening  bool     `json:"check_hardening"`
+	// VulnDB 为本地 OSV 漏洞数据源（目录、zip 或 JSON 文件），为空时不做漏洞匹配
+	VulnDB string `json:"vuln_db"`
+	// VulnDBMaxAge 为索引库允许的最长未更新时间，超过时拒绝扫描，除非 AllowStaleDB 为 true；0 表示不检查
+	VulnDBMaxAge     time.Duration `json:"vuln_db_max_age"`
+	AllowStaleDB     bool          `json:"allow_stale_db"`
+	IntegrityWorkers int           `json:"integrity_workers"`
+	CheckCommonTools bool          `json:"check_common_tools"`
+	CommonTools      []string      `json:"common_tools"`
+	SpecificCommands []string      `json:"specific_commands"`
 }
@@ -1,22 +1,13 @@
 package analyze
 
-import (
-	"os"
-	"path/filepath"
-)
-
-func CheckCommonTools(root string) map[string]bool {
-	tools := []string{"sshd", "python3", "curl", "wget", "nvcc"}
+// CheckCommonTools 按镜像 PATH 检查常用工具是否可用，tools 为空时使用 DefaultCommonTools
+func CheckCommonTools(root string, env []string, tools []string) map[string]bool {
+	if len(tools) == 0 {
+		tools = DefaultCommonTools
+	}
 	result := make(map[string]bool)
-	for _, t := range tools {
-		found := false
-		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-			if err == nil && info.Name() == t {
-				found = true
-			}
-			return nil
-		})
-		result[t] = found
+	for _, res := range ResolveCommands(root, env, tools) {
+		result[res.Command] = res.Found
 	}
 	return result
 }
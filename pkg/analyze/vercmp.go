@@ -0,0 +1,426 @@
+package analyze
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// compareDebianVersion 按 dpkg 的规则比较 [epoch:]upstream[-revision] 形式的版本号
+func compareDebianVersion(a, b string) int {
+	ea, ua, ra := splitDebianVersion(a)
+	eb, ub, rb := splitDebianVersion(b)
+	if c := compareInt(ea, eb); c != 0 {
+		return c
+	}
+	if c := debianVerrevcmp(ua, ub); c != 0 {
+		return c
+	}
+	return debianVerrevcmp(ra, rb)
+}
+
+func splitDebianVersion(v string) (int, string, string) {
+	epoch := 0
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		if n, err := strconv.Atoi(e); err == nil {
+			epoch, v = n, rest
+		}
+	}
+	upstream, revision := v, ""
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		upstream, revision = v[:i], v[i+1:]
+	}
+	return epoch, upstream, revision
+}
+
+// debianOrder 是非数字部分中单个字符的排序权重：~ 最小，其次是字符串结尾，字母小于其他符号
+func debianOrder(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return 0
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	case c != 0:
+		return int(c) + 256
+	}
+	return 0
+}
+
+// debianVerrevcmp 与 dpkg 的 verrevcmp 一致，交替比较非数字部分和数字部分
+func debianVerrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			var ac, bc int
+			if i < len(a) {
+				ac = debianOrder(a[i])
+			}
+			if j < len(b) {
+				bc = debianOrder(b[j])
+			}
+			if ac != bc {
+				return compareInt(ac, bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = compareInt(int(a[i]), int(b[j]))
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// compareRPMVersion 比较 [epoch:]version[-release] 形式的 RPM 版本号，缺少 release 的一方只比较 version
+func compareRPMVersion(a, b string) int {
+	ea, va, ra := splitRPMVersion(a)
+	eb, vb, rb := splitRPMVersion(b)
+	if c := compareInt(ea, eb); c != 0 {
+		return c
+	}
+	if c := rpmvercmp(va, vb); c != 0 {
+		return c
+	}
+	if ra == "" || rb == "" {
+		return 0
+	}
+	return rpmvercmp(ra, rb)
+}
+
+func splitRPMVersion(v string) (int, string, string) {
+	epoch := 0
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		if n, err := strconv.Atoi(e); err == nil {
+			epoch, v = n, rest
+		}
+	}
+	version, release := v, ""
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		version, release = v[:i], v[i+1:]
+	}
+	return epoch, version, release
+}
+
+// rpmvercmp 与 rpm 的同名函数一致：按字母数字段比较，数字段大于字母段，~ 小于任何内容，^ 大于结尾
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	isAlnum := func(c byte) bool { return isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+		// ~ 表示预发布版本，排在任何内容之前
+		if i < len(a) && a[i] == '~' || j < len(b) && b[j] == '~' {
+			if i >= len(a) || a[i] != '~' {
+				return 1
+			}
+			if j >= len(b) || b[j] != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		// ^ 表示在某个版本之后的快照，大于结尾但小于任何其他内容
+		if i < len(a) && a[i] == '^' || j < len(b) && b[j] == '^' {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if a[i] != '^' {
+				return 1
+			}
+			if b[j] != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+		si, sj := i, j
+		numeric := isDigit(a[i])
+		if numeric {
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+		} else {
+			for i < len(a) && isAlnum(a[i]) && !isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isAlnum(b[j]) && !isDigit(b[j]) {
+				j++
+			}
+		}
+		segA, segB := a[si:i], b[sj:j]
+		if segB == "" {
+			// 类型不同的段：数字段较新
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+		if numeric {
+			segA, segB = strings.TrimLeft(segA, "0"), strings.TrimLeft(segB, "0")
+			if c := compareInt(len(segA), len(segB)); c != 0 {
+				return c
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case i >= len(a) && j >= len(b):
+		return 0
+	case i >= len(a):
+		return -1
+	}
+	return 1
+}
+
+// apkSuffixOrder 是 apk 版本后缀的顺序，预发布后缀小于没有后缀，其余后缀大于没有后缀
+var apkSuffixOrder = map[string]int{
+	"alpha": -4, "beta": -3, "pre": -2, "rc": -1,
+	"cvs": 1, "svn": 2, "git": 3, "hg": 4, "p": 5,
+}
+
+// apkVersion 是解析后的 apk 版本号，例如 1.2.3a_rc1_p2-r4
+type apkVersion struct {
+	numbers  []string
+	letter   byte
+	suffixes [][2]int
+	revision int
+}
+
+func parseAPKVersion(v string) apkVersion {
+	var p apkVersion
+	if i := strings.LastIndex(v, "-r"); i >= 0 {
+		if n, err := strconv.Atoi(v[i+2:]); err == nil {
+			p.revision = n
+			v = v[:i]
+		}
+	}
+	parts := strings.Split(v, "_")
+	main := parts[0]
+	if n := len(main); n > 0 && main[n-1] >= 'a' && main[n-1] <= 'z' {
+		p.letter = main[n-1]
+		main = main[:n-1]
+	}
+	p.numbers = strings.Split(main, ".")
+	for _, s := range parts[1:] {
+		name := strings.TrimRightFunc(s, unicode.IsDigit)
+		n, _ := strconv.Atoi(s[len(name):])
+		p.suffixes = append(p.suffixes, [2]int{apkSuffixOrder[name], n})
+	}
+	return p
+}
+
+// compareAPKVersion 按 apk-tools 的规则比较 Alpine 包的版本号
+func compareAPKVersion(a, b string) int {
+	pa, pb := parseAPKVersion(a), parseAPKVersion(b)
+	for i := 0; i < len(pa.numbers) || i < len(pb.numbers); i++ {
+		if i >= len(pa.numbers) {
+			return -1
+		}
+		if i >= len(pb.numbers) {
+			return 1
+		}
+		x, y := pa.numbers[i], pb.numbers[i]
+		// 第一段之后以 0 开头的数字按小数比较
+		if i > 0 && (strings.HasPrefix(x, "0") || strings.HasPrefix(y, "0")) {
+			if c := strings.Compare(strings.TrimRight(x, "0"), strings.TrimRight(y, "0")); c != 0 {
+				return c
+			}
+			continue
+		}
+		nx, _ := strconv.Atoi(x)
+		ny, _ := strconv.Atoi(y)
+		if c := compareInt(nx, ny); c != 0 {
+			return c
+		}
+	}
+	if c := compareInt(int(pa.letter), int(pb.letter)); c != 0 {
+		return c
+	}
+	for i := 0; i < len(pa.suffixes) || i < len(pb.suffixes); i++ {
+		var sa, sb [2]int
+		if i < len(pa.suffixes) {
+			sa = pa.suffixes[i]
+		}
+		if i < len(pb.suffixes) {
+			sb = pb.suffixes[i]
+		}
+		if c := compareInt(sa[0], sb[0]); c != 0 {
+			return c
+		}
+		if c := compareInt(sa[1], sb[1]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(pa.revision, pb.revision)
+}
+
+// compareSemver 比较语义化版本号，忽略前缀 v 和构建元数据。
+// 预发布版本小于对应的正式版本，预发布标识符按语义化版本规范逐个比较
+func compareSemver(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	a, _, _ = strings.Cut(a, "+")
+	b, _, _ = strings.Cut(b, "+")
+	coreA, preA, hasPreA := strings.Cut(a, "-")
+	coreB, preB, hasPreB := strings.Cut(b, "-")
+	if c := compareLibcVersion(coreA, coreB); c != 0 {
+		return c
+	}
+	switch {
+	case !hasPreA && !hasPreB:
+		return 0
+	case !hasPreA:
+		return 1
+	case !hasPreB:
+		return -1
+	}
+	ia, ib := strings.Split(preA, "."), strings.Split(preB, ".")
+	for i := 0; i < len(ia) && i < len(ib); i++ {
+		na, errA := strconv.Atoi(ia[i])
+		nb, errB := strconv.Atoi(ib[i])
+		switch {
+		case errA == nil && errB == nil:
+			if c := compareInt(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(ia[i], ib[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(ia), len(ib))
+}
+
+// mavenQualifierOrder 是 Maven ComparableVersion 中已知限定符的顺序，空字符串表示正式版本
+var mavenQualifierOrder = map[string]int{
+	"alpha": 1, "a": 1, "beta": 2, "b": 2, "milestone": 3, "m": 3,
+	"rc": 4, "cr": 4, "snapshot": 5, "": 6, "ga": 6, "final": 6, "release": 6, "sp": 7,
+}
+
+// compareMavenVersion 近似实现 Maven 的 ComparableVersion：按 .、- 以及数字和字母的边界分段，
+// 数字段按数值比较，已知限定符按固定顺序比较，未知限定符大于已知限定符并按字典序比较
+func compareMavenVersion(a, b string) int {
+	ta, tb := mavenTokens(a), mavenTokens(b)
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		var x, y string
+		if i < len(ta) {
+			x = ta[i]
+		}
+		if i < len(tb) {
+			y = tb[i]
+		}
+		if c := compareMavenToken(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func mavenTokens(v string) []string {
+	v = strings.ToLower(v)
+	var tokens []string
+	start := 0
+	for i := 0; i <= len(v); i++ {
+		split := i == len(v) || v[i] == '.' || v[i] == '-'
+		if !split && i > start && isDigit(v[i]) != isDigit(v[i-1]) {
+			tokens = append(tokens, v[start:i])
+			start = i
+			continue
+		}
+		if split {
+			if i > start {
+				tokens = append(tokens, v[start:i])
+			}
+			start = i + 1
+		}
+	}
+	// 末尾的 0 和正式版本限定符不影响顺序，例如 1.0 与 1.0.0、1-final 相同
+	for len(tokens) > 0 {
+		last := tokens[len(tokens)-1]
+		if strings.Trim(last, "0") != "" && mavenQualifierOrder[last] != 6 {
+			break
+		}
+		tokens = tokens[:len(tokens)-1]
+	}
+	return tokens
+}
+
+// compareMavenToken 比较两个分段，空字符串表示该侧已经没有更多分段
+func compareMavenToken(x, y string) int {
+	nx, errX := strconv.Atoi(x)
+	ny, errY := strconv.Atoi(y)
+	switch {
+	case errX == nil && errY == nil:
+		return compareInt(nx, ny)
+	case errX == nil && y == "":
+		return compareInt(nx, 0)
+	case errY == nil && x == "":
+		return compareInt(0, ny)
+	case errX == nil:
+		// 数字大于任何限定符，包括表示正式版本的空字符串
+		return 1
+	case errY == nil:
+		return -1
+	}
+	ox, knownX := mavenQualifierOrder[x]
+	oy, knownY := mavenQualifierOrder[y]
+	switch {
+	case knownX && knownY:
+		return compareInt(ox, oy)
+	case knownX:
+		return -1
+	case knownY:
+		return 1
+	}
+	return strings.Compare(x, y)
+}
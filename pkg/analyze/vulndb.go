@@ -0,0 +1,461 @@
+package analyze
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"image-analyzer-go/pkg/logger"
+)
+
+// VulnDBSchemaVersion 是索引库的格式版本，格式变化后旧的索引库需要重新导入
+const VulnDBSchemaVersion = 1
+
+// 索引库目录中的文件：data.jsonl 每行是一个包的全部受影响记录，index.json 记录每个包在 data.jsonl 中的偏移和长度
+const (
+	vulnDBMetadataFile = "metadata.json"
+	vulnDBIndexFile    = "index.json"
+	vulnDBDataFile     = "data.jsonl"
+)
+
+// VulnDBMetadata 是索引库的元数据
+type VulnDBMetadata struct {
+	SchemaVersion int       `json:"schema_version"`
+	Sources       []string  `json:"sources"`
+	ImportedAt    time.Time `json:"imported_at"`
+	// UpdatedAt 为数据中最新的记录修改时间，用于判断漏洞库是否过期
+	UpdatedAt time.Time `json:"updated_at"`
+	Records   int       `json:"records"`
+	Packages  int       `json:"packages"`
+	// Ecosystems 为每个生态的记录数量
+	Ecosystems  map[string]int `json:"ecosystems"`
+	IndexSHA256 string         `json:"index_sha256"`
+	DataSHA256  string         `json:"data_sha256"`
+}
+
+// VulnDB 是扫描使用的漏洞库：db import 生成的索引库，或者原始的 OSV 目录、zip 包和 JSON 文件
+type VulnDB struct {
+	Path string
+	// Meta 为索引库的元数据，原始 OSV 数据源为 nil
+	Meta  *VulnDBMetadata
+	index map[string][2]int64
+}
+
+// DefaultVulnDBDir 返回默认的索引库目录
+func DefaultVulnDBDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "vulndb"
+	}
+	return filepath.Join(dir, "image-analyzer", "vulndb")
+}
+
+// OpenVulnDB 打开漏洞库。包含 metadata.json 的目录按索引库打开并校验版本和摘要，其余路径按原始 OSV 数据源处理
+func OpenVulnDB(path string) (*VulnDB, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开漏洞库失败: %w", err)
+	}
+	if fi.IsDir() {
+		if _, err := os.Stat(filepath.Join(path, vulnDBMetadataFile)); err == nil {
+			return openIndexedVulnDB(path)
+		}
+	}
+	return &VulnDB{Path: path}, nil
+}
+
+func openIndexedVulnDB(dir string) (*VulnDB, error) {
+	data, err := os.ReadFile(filepath.Join(dir, vulnDBMetadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取漏洞库元数据失败: %w", err)
+	}
+	var meta VulnDBMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("解析漏洞库元数据失败: %w", err)
+	}
+	if meta.SchemaVersion != VulnDBSchemaVersion {
+		return nil, fmt.Errorf("漏洞库 %s 的格式版本为 %d，当前需要 %d，请重新导入", dir, meta.SchemaVersion, VulnDBSchemaVersion)
+	}
+	for name, want := range map[string]string{vulnDBIndexFile: meta.IndexSHA256, vulnDBDataFile: meta.DataSHA256} {
+		sum, err := sha256File(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("校验漏洞库失败: %w", err)
+		}
+		if sum != want {
+			return nil, fmt.Errorf("漏洞库文件 %s 的 SHA-256 不匹配，文件可能已损坏，请重新导入", name)
+		}
+	}
+	data, err = os.ReadFile(filepath.Join(dir, vulnDBIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取漏洞库索引失败: %w", err)
+	}
+	db := &VulnDB{Path: dir, Meta: &meta}
+	if err := json.Unmarshal(data, &db.index); err != nil {
+		return nil, fmt.Errorf("解析漏洞库索引失败: %w", err)
+	}
+	return db, nil
+}
+
+// Age 返回索引库数据的新旧程度：距离最新记录修改时间（没有时为导入时间）的时长。原始数据源返回 false
+func (db *VulnDB) Age(now time.Time) (time.Duration, bool) {
+	if db.Meta == nil {
+		return 0, false
+	}
+	ref := db.Meta.UpdatedAt
+	if ref.IsZero() {
+		ref = db.Meta.ImportedAt
+	}
+	return now.Sub(ref), true
+}
+
+// CheckAge 检查索引库是否超过允许的时长没有更新，maxAge 为 0 时不检查。allowStale 为 true 时只记录警告
+func (db *VulnDB) CheckAge(maxAge time.Duration, allowStale bool) error {
+	age, ok := db.Age(time.Now())
+	if !ok || maxAge <= 0 || age <= maxAge {
+		return nil
+	}
+	if allowStale {
+		logger.Warn("漏洞库已过期，扫描结果可能遗漏新漏洞",
+			logger.WithString("db", db.Path),
+			logger.WithString("age", FormatAge(age)))
+		return nil
+	}
+	return fmt.Errorf("漏洞库 %s 已有 %s没有更新，超过允许的 %s，请重新导入，或允许使用过期的漏洞库（--allow-stale）",
+		db.Path, FormatAge(age), FormatAge(maxAge))
+}
+
+// FormatAge 将时长格式化为天数，不足一天时为小时数
+func FormatAge(d time.Duration) string {
+	if d >= 24*time.Hour {
+		return fmt.Sprintf("%.1f 天", d.Hours()/24)
+	}
+	return fmt.Sprintf("%.1f 小时", d.Hours())
+}
+
+// lookup 返回指定包的受影响记录，同时返回记录解析错误。索引库按偏移直接读取，原始数据源需要遍历全部记录
+func (db *VulnDB) lookup(wanted map[string]bool) (map[string][]osvMatchEntry, []string, error) {
+	result := make(map[string][]osvMatchEntry)
+	if db.Meta != nil {
+		f, err := os.Open(filepath.Join(db.Path, vulnDBDataFile))
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取漏洞库失败: %w", err)
+		}
+		defer f.Close()
+		for key := range wanted {
+			pos, ok := db.index[key]
+			if !ok {
+				continue
+			}
+			buf := make([]byte, pos[1])
+			if _, err := f.ReadAt(buf, pos[0]); err != nil {
+				return nil, nil, fmt.Errorf("读取漏洞库失败: %w", err)
+			}
+			var entries []osvMatchEntry
+			if err := json.Unmarshal(buf, &entries); err != nil {
+				return nil, nil, fmt.Errorf("解析漏洞库记录失败: %w", err)
+			}
+			result[key] = entries
+		}
+		return result, nil, nil
+	}
+
+	var errs []string
+	failed := 0
+	err := walkOSVSource(db.Path, func(name string, data []byte) error {
+		rec, err := parseOSVRecord(name, data)
+		if err != nil {
+			if failed++; failed <= maxVulnErrors {
+				errs = append(errs, err.Error())
+			}
+			return nil
+		}
+		if rec == nil {
+			return nil
+		}
+		for i := range rec.Affected {
+			key := osvPackageKey(rec.Affected[i].Package.Ecosystem, rec.Affected[i].Package.Name)
+			if wanted[key] {
+				result[key] = append(result[key], newOSVMatchEntry(rec, &rec.Affected[i]))
+			}
+		}
+		return nil
+	})
+	if failed > maxVulnErrors {
+		errs = append(errs, fmt.Sprintf("另有 %d 条记录解析失败", failed-maxVulnErrors))
+	}
+	if err != nil {
+		return nil, errs, fmt.Errorf("读取漏洞数据源 %s 失败: %w", db.Path, err)
+	}
+	return result, errs, nil
+}
+
+// ImportVulnDB 将 OSV 目录、zip 包或 JSON 文件导入为按生态和包名索引的漏洞库，替换 dir 中已有的库。
+// 多个数据源合并导入，同一条记录只保留第一次出现的版本。也可以导入 db export 生成的离线包（.tar.gz）
+func ImportVulnDB(dir string, sources []string) (*VulnDBMetadata, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("没有指定漏洞数据源")
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, fmt.Errorf("创建漏洞库目录失败: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".vulndb-import-")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	var meta *VulnDBMetadata
+	if len(sources) == 1 && isVulnDBBundle(sources[0]) {
+		if err := extractVulnDBBundle(sources[0], tmp); err != nil {
+			return nil, err
+		}
+		db, err := openIndexedVulnDB(tmp)
+		if err != nil {
+			return nil, err
+		}
+		meta = db.Meta
+	} else {
+		if meta, err = buildVulnDB(tmp, sources); err != nil {
+			return nil, err
+		}
+	}
+
+	old := dir + ".old"
+	_ = os.RemoveAll(old)
+	if _, err := os.Stat(dir); err == nil {
+		if err := os.Rename(dir, old); err != nil {
+			return nil, fmt.Errorf("替换漏洞库失败: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		_ = os.Rename(old, dir)
+		return nil, fmt.Errorf("替换漏洞库失败: %w", err)
+	}
+	_ = os.RemoveAll(old)
+	return meta, nil
+}
+
+// buildVulnDB 解析数据源中的全部记录，按包写入 data.jsonl 和 index.json，最后写入带摘要的元数据
+func buildVulnDB(dir string, sources []string) (*VulnDBMetadata, error) {
+	meta := &VulnDBMetadata{
+		SchemaVersion: VulnDBSchemaVersion,
+		ImportedAt:    time.Now().UTC(),
+		Ecosystems:    make(map[string]int),
+	}
+	entries := make(map[string][]osvMatchEntry)
+	seen := make(map[string]bool)
+	failed := 0
+	for _, src := range sources {
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			abs = src
+		}
+		meta.Sources = append(meta.Sources, abs)
+		err = walkOSVSource(src, func(name string, data []byte) error {
+			rec, err := parseOSVRecord(name, data)
+			if err != nil {
+				failed++
+				logger.Warn("跳过无法解析的漏洞记录", logger.WithError(err))
+				return nil
+			}
+			if rec == nil || seen[rec.ID] {
+				return nil
+			}
+			seen[rec.ID] = true
+			meta.Records++
+			if t, err := time.Parse(time.RFC3339, rec.Modified); err == nil && t.After(meta.UpdatedAt) {
+				meta.UpdatedAt = t.UTC()
+			}
+			var ecosystems []string
+			for i := range rec.Affected {
+				aff := &rec.Affected[i]
+				if aff.Package.Name == "" {
+					continue
+				}
+				key := osvPackageKey(aff.Package.Ecosystem, aff.Package.Name)
+				entries[key] = append(entries[key], newOSVMatchEntry(rec, aff))
+				if base := osvEcosystemBase(aff.Package.Ecosystem); !containsString(ecosystems, base) {
+					ecosystems = append(ecosystems, base)
+				}
+			}
+			for _, e := range ecosystems {
+				meta.Ecosystems[e]++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取漏洞数据源 %s 失败: %w", src, err)
+		}
+	}
+	if meta.Records == 0 {
+		return nil, fmt.Errorf("数据源中没有有效的 OSV 记录（%d 条解析失败）", failed)
+	}
+	if failed > 0 {
+		logger.Warn("部分漏洞记录解析失败", logger.WithInt("count", failed))
+	}
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	meta.Packages = len(keys)
+
+	f, err := os.Create(filepath.Join(dir, vulnDBDataFile))
+	if err != nil {
+		return nil, fmt.Errorf("写入漏洞库失败: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(f, h))
+	index := make(map[string][2]int64, len(keys))
+	var offset int64
+	for _, k := range keys {
+		data, err := json.Marshal(entries[k])
+		if err != nil {
+			return nil, fmt.Errorf("写入漏洞库失败: %w", err)
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return nil, fmt.Errorf("写入漏洞库失败: %w", err)
+		}
+		index[k] = [2]int64{offset, int64(len(data))}
+		offset += int64(len(data)) + 1
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("写入漏洞库失败: %w", err)
+	}
+	meta.DataSHA256 = hex.EncodeToString(h.Sum(nil))
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return nil, fmt.Errorf("写入漏洞库索引失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, vulnDBIndexFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("写入漏洞库索引失败: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	meta.IndexSHA256 = hex.EncodeToString(sum[:])
+
+	data, err = json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("写入漏洞库元数据失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, vulnDBMetadataFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("写入漏洞库元数据失败: %w", err)
+	}
+	return meta, nil
+}
+
+// ExportVulnDB 校验索引库后将其打包为 .tar.gz 离线包，用于复制到无法联网的机器上导入，返回离线包的 SHA-256
+func ExportVulnDB(dir, bundle string) (string, error) {
+	if _, err := openIndexedVulnDB(dir); err != nil {
+		return "", err
+	}
+	f, err := os.Create(bundle)
+	if err != nil {
+		return "", fmt.Errorf("创建离线包失败: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{vulnDBMetadataFile, vulnDBIndexFile, vulnDBDataFile} {
+		if err := addFileToTar(tw, filepath.Join(dir, name), name); err != nil {
+			return "", fmt.Errorf("写入离线包失败: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("写入离线包失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("写入离线包失败: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func addFileToTar(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: fi.Size(), ModTime: fi.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func isVulnDBBundle(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// extractVulnDBBundle 解压离线包，只接受索引库的三个文件
+func extractVulnDBBundle(bundle, dir string) error {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return fmt.Errorf("打开离线包失败: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("读取离线包失败: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取离线包失败: %w", err)
+		}
+		switch hdr.Name {
+		case vulnDBMetadataFile, vulnDBIndexFile, vulnDBDataFile:
+		default:
+			return fmt.Errorf("离线包中包含未知文件 %s", hdr.Name)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("离线包中的 %s 不是普通文件", hdr.Name)
+		}
+		out, err := os.Create(filepath.Join(dir, hdr.Name))
+		if err != nil {
+			return fmt.Errorf("解压离线包失败: %w", err)
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return fmt.Errorf("解压离线包失败: %w", err)
+		}
+	}
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
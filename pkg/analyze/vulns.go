@@ -0,0 +1,445 @@
+package analyze
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"image-analyzer-go/pkg/utils"
+)
+
+// VulnerabilityReport 是离线漏洞扫描的结果
+type VulnerabilityReport struct {
+	// Database 为使用的漏洞数据源路径
+	Database string `json:"database"`
+	// Distro 为镜像发行版对应的 OSV 生态，例如 Debian:12，无法识别时为空
+	Distro          string          `json:"distro,omitempty"`
+	Packages        int             `json:"packages"`
+	Counts          map[string]int  `json:"counts"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	Errors          []string        `json:"errors,omitempty"`
+}
+
+// Vulnerability 是一个包命中的一条漏洞记录
+type Vulnerability struct {
+	ID string `json:"id"`
+	// Aliases 为同一漏洞在其他数据源中的编号，例如 CVE 和 GHSA
+	Aliases   []string `json:"aliases,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	Severity  string   `json:"severity"`
+	Score     float64  `json:"score,omitempty"`
+	Ecosystem string   `json:"ecosystem"`
+	Package   string   `json:"package"`
+	Version   string   `json:"version"`
+	// Binaries 为系统包漏洞中由该源包构建的已安装二进制包
+	Binaries      []string `json:"binaries,omitempty"`
+	FixedVersions []string `json:"fixed_versions,omitempty"`
+	Paths         []string `json:"paths"`
+}
+
+// vulnPackage 是参与漏洞匹配的一个包。系统包按源包匹配，Release 为发行版版本
+type vulnPackage struct {
+	Ecosystem string
+	Release   string
+	Name      string
+	Version   string
+	Binaries  []string
+	Paths     []string
+}
+
+// osvMatchEntry 是某个包对应的一条受影响记录，只保留匹配和报告用到的字段，也是索引库中保存的格式
+type osvMatchEntry struct {
+	ID        string     `json:"id"`
+	Aliases   []string   `json:"aliases,omitempty"`
+	Summary   string     `json:"summary,omitempty"`
+	Severity  string     `json:"severity"`
+	Score     float64    `json:"score,omitempty"`
+	Ecosystem string     `json:"ecosystem"`
+	Ranges    []osvRange `json:"ranges,omitempty"`
+	Versions  []string   `json:"versions,omitempty"`
+}
+
+func newOSVMatchEntry(rec *osvRecord, aff *osvAffected) osvMatchEntry {
+	severity, score := osvSeverityOf(rec, aff)
+	var ranges []osvRange
+	for _, r := range aff.Ranges {
+		// GIT 范围需要提交历史，离线时无法判断
+		if r.Type == "ECOSYSTEM" || r.Type == "SEMVER" {
+			ranges = append(ranges, r)
+		}
+	}
+	return osvMatchEntry{
+		ID:        rec.ID,
+		Aliases:   rec.Aliases,
+		Summary:   rec.Summary,
+		Severity:  severity,
+		Score:     score,
+		Ecosystem: aff.Package.Ecosystem,
+		Ranges:    ranges,
+		Versions:  aff.Versions,
+	}
+}
+
+// maxVulnErrors 是报告中保留的数据源错误数量，其余只计数
+const maxVulnErrors = 20
+
+var goToolchainVersionPattern = regexp.MustCompile(`^go(\d+)\.(\d+)(?:\.(\d+))?(?:(rc|beta)(\d+))?$`)
+
+func newVulnerabilityReport(database string) *VulnerabilityReport {
+	return &VulnerabilityReport{
+		Database:        database,
+		Counts:          make(map[string]int),
+		Vulnerabilities: []Vulnerability{},
+	}
+}
+
+// ScanVulnerabilities 收集镜像中的系统包和各语言的包，与漏洞库离线匹配
+func ScanVulnerabilities(root string, db *VulnDB, workers int) *VulnerabilityReport {
+	report := newVulnerabilityReport(db.Path)
+	pkgs, distro := collectVulnPackages(root, workers)
+	report.Distro = distro
+	report.Packages = len(pkgs)
+
+	wanted := make(map[string]bool)
+	for _, p := range pkgs {
+		wanted[osvPackageKey(p.Ecosystem, p.Name)] = true
+	}
+	index, errs, err := db.lookup(wanted)
+	report.Errors = append(report.Errors, errs...)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+
+	report.Vulnerabilities = matchVulnerabilities(pkgs, func(key string) []osvMatchEntry { return index[key] })
+	for _, v := range report.Vulnerabilities {
+		report.Counts[v.Severity]++
+	}
+	return report
+}
+
+// matchVulnerabilities 按生态的版本比较规则判断每个包是否落在受影响的范围内，同一个包的同一条记录只报告一次
+func matchVulnerabilities(pkgs []vulnPackage, lookup func(key string) []osvMatchEntry) []Vulnerability {
+	vulns := []Vulnerability{}
+	for _, p := range pkgs {
+		seen := make(map[string]int)
+		for _, e := range lookup(osvPackageKey(p.Ecosystem, p.Name)) {
+			if osvEcosystemBase(e.Ecosystem) != p.Ecosystem || !osvReleaseMatches(e.Ecosystem, p.Release) {
+				continue
+			}
+			if !osvVersionAffected(&e, p.Ecosystem, p.Version) {
+				continue
+			}
+			fixed := osvFixedVersions(e.Ranges)
+			if i, ok := seen[e.ID]; ok {
+				for _, f := range fixed {
+					if !containsString(vulns[i].FixedVersions, f) {
+						vulns[i].FixedVersions = append(vulns[i].FixedVersions, f)
+					}
+				}
+				continue
+			}
+			ecosystem := p.Ecosystem
+			if p.Release != "" {
+				ecosystem += ":" + p.Release
+			}
+			seen[e.ID] = len(vulns)
+			vulns = append(vulns, Vulnerability{
+				ID:            e.ID,
+				Aliases:       e.Aliases,
+				Summary:       e.Summary,
+				Severity:      e.Severity,
+				Score:         e.Score,
+				Ecosystem:     ecosystem,
+				Package:       p.Name,
+				Version:       p.Version,
+				Binaries:      p.Binaries,
+				FixedVersions: fixed,
+				Paths:         p.Paths,
+			})
+		}
+	}
+	sort.SliceStable(vulns, func(i, j int) bool {
+		a, b := vulns[i], vulns[j]
+		if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+			return ra < rb
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.ID < b.ID
+	})
+	return vulns
+}
+
+// osvVersionAffected 按 OSV 规范判断版本是否受影响：命中 versions 列表，或落在某个 ECOSYSTEM/SEMVER 范围内
+func osvVersionAffected(e *osvMatchEntry, ecosystem, version string) bool {
+	if containsString(e.Versions, version) {
+		return true
+	}
+	for _, r := range e.Ranges {
+		var cmp func(a, b string) int
+		switch r.Type {
+		case "ECOSYSTEM":
+			cmp = versionComparator(ecosystem)
+		case "SEMVER":
+			cmp = compareSemver
+		default:
+			continue
+		}
+		if osvRangeAffected(r.Events, version, cmp) {
+			return true
+		}
+	}
+	return false
+}
+
+// osvRangeAffected 将事件按版本排序后依次应用：introduced 进入受影响状态，fixed 和 last_affected 之后离开
+func osvRangeAffected(events []osvEvent, version string, cmp func(a, b string) int) bool {
+	eventVersion := func(e osvEvent) string {
+		switch {
+		case e.Introduced != "":
+			return e.Introduced
+		case e.Fixed != "":
+			return e.Fixed
+		case e.LastAffected != "":
+			return e.LastAffected
+		}
+		return e.Limit
+	}
+	sorted := append([]osvEvent{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		vi, vj := eventVersion(sorted[i]), eventVersion(sorted[j])
+		if sorted[i].Introduced == "0" || sorted[j].Introduced == "0" {
+			return sorted[i].Introduced == "0" && sorted[j].Introduced != "0"
+		}
+		return cmp(vi, vj) < 0
+	})
+
+	affected := false
+	for _, e := range sorted {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || cmp(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if cmp(version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if cmp(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+func osvFixedVersions(ranges []osvRange) []string {
+	var fixed []string
+	for _, r := range ranges {
+		for _, e := range r.Events {
+			if e.Fixed != "" && !containsString(fixed, e.Fixed) {
+				fixed = append(fixed, e.Fixed)
+			}
+		}
+	}
+	return fixed
+}
+
+// versionComparator 返回 OSV 生态对应的版本比较函数
+func versionComparator(ecosystem string) func(a, b string) int {
+	switch ecosystem {
+	case "Debian", "Ubuntu":
+		return compareDebianVersion
+	case "Alpine", "Wolfi", "Chainguard":
+		return compareAPKVersion
+	case "Rocky Linux", "AlmaLinux", "Red Hat", "openSUSE", "SUSE", "Mageia", "Photon OS":
+		return compareRPMVersion
+	case "PyPI":
+		return comparePEP440
+	case "Maven":
+		return compareMavenVersion
+	}
+	return compareSemver
+}
+
+// comparePEP440 按 PEP 440 比较版本号，无法解析时退回语义化版本比较
+func comparePEP440(a, b string) int {
+	va, errA := ParsePEP440Version(a)
+	vb, errB := ParsePEP440Version(b)
+	if errA != nil || errB != nil {
+		return compareSemver(a, b)
+	}
+	return va.Compare(vb)
+}
+
+// osvDistro 根据 os-release 返回发行版在 OSV 中的生态名称和版本，不支持的发行版返回空字符串
+func osvDistro(osRelease map[string]string) (string, string) {
+	id, version := osRelease["ID"], osRelease["VERSION_ID"]
+	major, minor, _ := strings.Cut(version, ".")
+	minor, _, _ = strings.Cut(minor, ".")
+	switch id {
+	case "debian":
+		return "Debian", major
+	case "ubuntu":
+		return "Ubuntu", version
+	case "alpine":
+		if minor == "" {
+			return "Alpine", ""
+		}
+		return "Alpine", "v" + major + "." + minor
+	case "rocky":
+		return "Rocky Linux", major
+	case "almalinux":
+		return "AlmaLinux", major
+	case "rhel":
+		return "Red Hat", major
+	case "opensuse-leap":
+		return "openSUSE", "Leap " + version
+	case "sles":
+		if minor != "" && minor != "0" {
+			return "SUSE", major + " SP" + minor
+		}
+		return "SUSE", major
+	case "mageia":
+		return "Mageia", version
+	case "photon":
+		return "Photon OS", version
+	case "wolfi":
+		return "Wolfi", ""
+	case "chainguard":
+		return "Chainguard", ""
+	}
+	return "", ""
+}
+
+// readOSRelease 读取 /etc/os-release，不存在时读取 /usr/lib/os-release
+func readOSRelease(root string) map[string]string {
+	fields := make(map[string]string)
+	for _, p := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		path, err := utils.SecureJoin(root, p)
+		if err != nil {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			k, v, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if ok && !strings.HasPrefix(k, "#") {
+				fields[k] = strings.Trim(v, `"'`)
+			}
+		}
+		f.Close()
+		break
+	}
+	return fields
+}
+
+// collectVulnPackages 收集系统包管理器和各语言的包，相同的包合并路径
+func collectVulnPackages(root string, workers int) ([]vulnPackage, string) {
+	var pkgs []vulnPackage
+	index := make(map[string]int)
+	add := func(ecosystem, release, name, version, binary, path string) {
+		if name == "" || version == "" {
+			return
+		}
+		key := strings.Join([]string{ecosystem, release, name, version}, "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(pkgs)
+			index[key] = i
+			pkgs = append(pkgs, vulnPackage{Ecosystem: ecosystem, Release: release, Name: name, Version: version})
+		}
+		if binary != "" && !containsString(pkgs[i].Binaries, binary) {
+			pkgs[i].Binaries = append(pkgs[i].Binaries, binary)
+		}
+		if path != "" && !containsString(pkgs[i].Paths, path) {
+			pkgs[i].Paths = append(pkgs[i].Paths, path)
+		}
+	}
+
+	distro, release := osvDistro(readOSRelease(root))
+	distroName := distro
+	if distro != "" && release != "" {
+		distroName += ":" + release
+	}
+	switch distro {
+	case "Debian", "Ubuntu":
+		for _, p := range readDpkgPackages(root) {
+			// 安全公告按源包发布，Source 字段形如 "openssl" 或 "openssl (3.0.11-1)"，省略时与包名相同
+			source, version := p.Name, p.Version
+			if p.Source != "" {
+				name, ver, hasVer := strings.Cut(p.Source, " (")
+				source = name
+				if hasVer {
+					version = strings.TrimSuffix(ver, ")")
+				}
+			}
+			add(distro, release, source, version, p.Name, "/var/lib/dpkg/status")
+		}
+	case "Alpine", "Wolfi", "Chainguard":
+		for _, p := range readAPKPackages(root) {
+			add(distro, release, p.Origin, p.Version, p.Name, "/lib/apk/db/installed")
+		}
+	case "Rocky Linux", "AlmaLinux", "Red Hat", "openSUSE", "SUSE", "Mageia", "Photon OS":
+		rpms, err := readRPMPackages(root)
+		if err == nil {
+			for _, p := range rpms {
+				add(distro, release, p.Name, p.EVR(), p.Name, "rpmdb")
+			}
+		}
+	}
+
+	for _, sp := range findSitePackages(root) {
+		for _, d := range listPythonDists(root, sp) {
+			add("PyPI", "", d.Name, d.Version, "", d.MetaDir)
+		}
+	}
+	if inv := ListNodePackages(root); inv != nil {
+		for _, p := range inv.Packages {
+			add("npm", "", p.Name, p.Version, "", p.Path)
+		}
+		for _, l := range inv.Lockfiles {
+			for _, p := range l.Packages {
+				add("npm", "", p.Name, p.Version, "", l.Path)
+			}
+		}
+	}
+	for _, b := range ListGoBinaries(root, workers) {
+		if m := goToolchainVersionPattern.FindStringSubmatch(b.GoVersion); m != nil {
+			version := m[1] + "." + m[2] + "." + m[3]
+			if m[3] == "" {
+				version = m[1] + "." + m[2] + ".0"
+			}
+			if m[4] != "" {
+				version += "-" + m[4] + "." + m[5]
+			}
+			add("Go", "", "stdlib", version, "", b.Path)
+		}
+		for _, dep := range append([]GoModule{b.MainModule}, b.Deps...) {
+			if dep.Replace != nil {
+				dep = *dep.Replace
+			}
+			if dep.Version != "(devel)" {
+				add("Go", "", dep.Path, dep.Version, "", b.Path)
+			}
+		}
+	}
+	if java := ListJavaPackages(root, workers); java != nil {
+		for _, a := range java.Archives {
+			for _, art := range a.Artifacts {
+				if art.GroupID != "" {
+					add("Maven", "", art.GroupID+":"+art.ArtifactID, art.Version, "", a.NestingPath)
+				}
+			}
+		}
+	}
+	return pkgs, distroName
+}
@@ -0,0 +1,109 @@
+package analyze
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// PythonWheelIssue 是平台标签与镜像的 C 库或架构不匹配的 wheel
+type PythonWheelIssue struct {
+	Package string   `json:"package"`
+	Version string   `json:"version"`
+	Tags    []string `json:"tags"`
+	Reason  string   `json:"reason"`
+}
+
+var (
+	manylinuxTagPattern  = regexp.MustCompile(`^manylinux_(\d+)_(\d+)_(.+)$`)
+	musllinuxTagPattern  = regexp.MustCompile(`^musllinux_(\d+)_(\d+)_(.+)$`)
+	legacyManylinuxGlibc = map[string]string{
+		"manylinux1":    "2.5",
+		"manylinux2010": "2.12",
+		"manylinux2014": "2.17",
+	}
+)
+
+// readWheelTags 读取 dist-info/WHEEL 中的 Tag 字段，不是通过 wheel 安装时返回 nil
+func readWheelTags(root, metaDir string) []string {
+	if !strings.HasSuffix(metaDir, ".dist-info") {
+		return nil
+	}
+	data, err := os.ReadFile(rootPath(root, metaDir, "WHEEL"))
+	if err != nil {
+		return nil
+	}
+	return parseRFC822Headers(data)["Tag"]
+}
+
+// checkWheelPlatform 判断 wheel 能否在镜像上加载，兼容时返回空字符串。
+// 一个 wheel 可能带有多个标签，只要有一个平台兼容即可
+func checkWheelPlatform(tags []string, libc *LibcInfo, machine string) string {
+	var reasons []string
+	for _, tag := range tags {
+		parts := strings.Split(tag, "-")
+		if len(parts) != 3 {
+			continue
+		}
+		// 压缩标签集形如 manylinux_2_17_x86_64.manylinux2014_x86_64
+		for _, platform := range strings.Split(parts[2], ".") {
+			reason := wheelPlatformMismatch(platform, libc, machine)
+			if reason == "" {
+				return ""
+			}
+			if !containsString(reasons, reason) {
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func wheelPlatformMismatch(platform string, libc *LibcInfo, machine string) string {
+	if platform == "any" {
+		return ""
+	}
+
+	var family, minVersion, arch string
+	switch {
+	case strings.HasPrefix(platform, "linux_"):
+		arch = strings.TrimPrefix(platform, "linux_")
+	case manylinuxTagPattern.MatchString(platform):
+		m := manylinuxTagPattern.FindStringSubmatch(platform)
+		family, minVersion, arch = LibcGlibc, m[1]+"."+m[2], m[3]
+	case musllinuxTagPattern.MatchString(platform):
+		m := musllinuxTagPattern.FindStringSubmatch(platform)
+		family, minVersion, arch = LibcMusl, m[1]+"."+m[2], m[3]
+	default:
+		legacy, a, ok := strings.Cut(platform, "_")
+		glibc, known := legacyManylinuxGlibc[legacy]
+		if !ok || !known {
+			return fmt.Sprintf("platform %s is not linux", platform)
+		}
+		family, minVersion, arch = LibcGlibc, glibc, a
+	}
+
+	if machine != "" && arch != machine {
+		return fmt.Sprintf("built for %s, image is %s", arch, machine)
+	}
+	if family == "" || libc == nil {
+		return ""
+	}
+	if libc.Family != family {
+		return fmt.Sprintf("%s wheel requires %s, image uses %s", platform, family, libc.Family)
+	}
+	if libc.Version != "" && compareLibcVersion(libc.Version, minVersion) < 0 {
+		return fmt.Sprintf("requires %s >= %s, image has %s %s", family, minVersion, libc.Family, libc.Version)
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1077 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+// 支持的 YARA 语法子集：
+//   - 规则：rule 名称 [: 标签...] { meta: ... strings: ... condition: ... }，可以带 private、global 修饰（忽略）
+//   - meta：字符串、整数和布尔值，description、severity 用于报告，filetype 限制适用的文件类型（elf、script、text、binary，逗号分隔）
+//   - strings：文本字符串（nocase、wide、ascii、fullword），十六进制串（??、半字节通配、[n-m] 跳转、(A|B) 选择），正则 /.../is
+//   - condition：and、or、not、括号、比较运算，$a、$a at N、#a、filesize（支持 KB、MB 后缀）、
+//     any/all/none/N of them 或 of ($a, $b*)，uint8/uint16/uint32/uint16be/uint32be(偏移)
+//
+// 不支持模块（import）、include、规则引用、xor/base64 修饰符和算术运算
+
+// yaraRule 是一条解析后的规则
+type yaraRule struct {
+	ID        string
+	Tags      []string
+	Meta      map[string]string
+	FileTypes []string
+	strings   []*yaraString
+	cond      yaraExpr
+}
+
+const (
+	yaraText = iota
+	yaraHex
+	yaraRegex
+)
+
+// yaraString 是规则中的一个字符串，文本字符串的各种编码形式预先展开到 patterns 中
+type yaraString struct {
+	id       string
+	kind     int
+	patterns [][]byte
+	nocase   bool
+	fullword bool
+	wide     bool
+	hex      []hexToken
+	re       *regexp.Regexp
+	// atom 为正则匹配必须包含的字面量，文件中没有时跳过正则，atomFold 表示按小写比较
+	atom     []byte
+	atomFold bool
+}
+
+// hexToken 是十六进制串的一个元素：按掩码比较的字节、长度范围内的跳转，或多个候选序列
+type hexToken struct {
+	kind     int
+	val      byte
+	mask     byte
+	min, max int
+	alts     [][]hexToken
+}
+
+const (
+	hexByte = iota
+	hexJump
+	hexAlt
+)
+
+// maxYARAMatches 是统计字符串出现次数的上限
+const maxYARAMatches = 1000
+
+// yaraExpr 是条件表达式，布尔值以 0 和 1 表示
+type yaraExpr func(c *yaraContext) int64
+
+// yaraFile 是被扫描的文件内容，小写形式只在有 nocase 字符串时计算一次
+type yaraFile struct {
+	data  []byte
+	lower []byte
+}
+
+func (f *yaraFile) lowered() []byte {
+	if f.lower == nil {
+		f.lower = asciiLower(f.data)
+	}
+	return f.lower
+}
+
+// yaraContext 缓存一条规则在一个文件上各字符串的匹配结果
+type yaraContext struct {
+	file   *yaraFile
+	rule   *yaraRule
+	found  []int8
+	counts []int
+}
+
+// match 判断规则是否命中文件，返回命中的字符串标识符
+func (r *yaraRule) match(file *yaraFile) (bool, []string) {
+	c := &yaraContext{file: file, rule: r, found: make([]int8, len(r.strings)), counts: make([]int, len(r.strings))}
+	for i := range c.counts {
+		c.counts[i] = -1
+	}
+	if r.cond(c) == 0 {
+		return false, nil
+	}
+	var ids []string
+	for i, s := range r.strings {
+		if c.found[i] == 1 || (c.found[i] == 0 && c.matched(i)) {
+			ids = append(ids, s.id)
+		}
+	}
+	return true, ids
+}
+
+func (c *yaraContext) matched(i int) bool {
+	if c.found[i] == 0 {
+		c.found[i] = -1
+		if c.rule.strings[i].find(c.file, 0, 1) > 0 {
+			c.found[i] = 1
+		}
+	}
+	return c.found[i] == 1
+}
+
+func (c *yaraContext) count(i int) int {
+	if c.counts[i] < 0 {
+		c.counts[i] = c.rule.strings[i].find(c.file, 0, maxYARAMatches)
+		if c.counts[i] > 0 {
+			c.found[i] = 1
+		} else {
+			c.found[i] = -1
+		}
+	}
+	return c.counts[i]
+}
+
+// find 从 start 开始统计匹配次数（允许重叠），达到 limit 时停止
+func (s *yaraString) find(file *yaraFile, start, limit int) int {
+	n := 0
+	switch s.kind {
+	case yaraText:
+		data := file.data
+		if s.nocase {
+			data = file.lowered()
+		}
+		for _, p := range s.patterns {
+			for off := start; off <= len(data)-len(p); {
+				i := bytes.Index(data[off:], p)
+				if i < 0 {
+					break
+				}
+				if !s.fullword || s.isFullword(data, off+i, len(p)) {
+					if n++; n >= limit {
+						return n
+					}
+				}
+				off += i + 1
+			}
+		}
+	case yaraHex:
+		for off := start; off < len(file.data); off++ {
+			if first := s.hex[0]; first.kind == hexByte && first.mask == 0xff {
+				i := bytes.IndexByte(file.data[off:], first.val)
+				if i < 0 {
+					break
+				}
+				off += i
+			}
+			if _, ok := hexMatch(s.hex, file.data, off); ok {
+				if n++; n >= limit {
+					return n
+				}
+			}
+		}
+	case yaraRegex:
+		if !s.atomPresent(file) {
+			return 0
+		}
+		for off := start; off < len(file.data); {
+			loc := s.re.FindIndex(file.data[off:])
+			if loc == nil {
+				break
+			}
+			if n++; n >= limit {
+				return n
+			}
+			off += loc[0] + 1
+		}
+	}
+	return n
+}
+
+// matchAt 判断字符串是否恰好出现在偏移 off 处
+func (s *yaraString) matchAt(file *yaraFile, off int) bool {
+	if off < 0 || off > len(file.data) {
+		return false
+	}
+	switch s.kind {
+	case yaraText:
+		data := file.data
+		if s.nocase {
+			data = file.lowered()
+		}
+		for _, p := range s.patterns {
+			if bytes.HasPrefix(data[off:], p) && (!s.fullword || s.isFullword(data, off, len(p))) {
+				return true
+			}
+		}
+	case yaraHex:
+		_, ok := hexMatch(s.hex, file.data, off)
+		return ok
+	case yaraRegex:
+		loc := s.re.FindIndex(file.data[off:])
+		return loc != nil && loc[0] == 0
+	}
+	return false
+}
+
+func (s *yaraString) atomPresent(file *yaraFile) bool {
+	switch {
+	case s.atom == nil:
+		return true
+	case s.atomFold:
+		return bytes.Contains(file.lowered(), s.atom)
+	}
+	return bytes.Contains(file.data, s.atom)
+}
+
+// regexAtom 返回正则开头必须出现的字面量（至少 3 个字节），用 bytes.Contains 预先过滤比直接执行正则快得多
+func regexAtom(expr string) ([]byte, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, false
+	}
+	re = re.Simplify()
+	for re.Op == syntax.OpConcat || re.Op == syntax.OpCapture {
+		if len(re.Sub) == 0 {
+			return nil, false
+		}
+		// 跳过开头的 ^、\b 等零宽断言
+		i := 0
+		for i < len(re.Sub)-1 && isEmptyWidth(re.Sub[i].Op) {
+			i++
+		}
+		re = re.Sub[i]
+	}
+	if re.Op != syntax.OpLiteral || len(string(re.Rune)) < 3 {
+		return nil, false
+	}
+	lit := string(re.Rune)
+	if re.Flags&syntax.FoldCase != 0 {
+		// asciiLower 不转换非 ASCII 字符，这类字面量不能用于忽略大小写的预过滤
+		for _, r := range re.Rune {
+			if r >= 0x80 {
+				return nil, false
+			}
+		}
+		return asciiLower([]byte(lit)), true
+	}
+	return []byte(lit), false
+}
+
+func isEmptyWidth(op syntax.Op) bool {
+	switch op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary, syntax.OpEmptyMatch:
+		return true
+	}
+	return false
+}
+
+func (s *yaraString) isFullword(data []byte, i, n int) bool {
+	step := 1
+	if s.wide {
+		step = 2
+	}
+	if i-step >= 0 && isWordByte(data[i-step]) {
+		return false
+	}
+	return i+n >= len(data) || !isWordByte(data[i+n])
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// asciiLower 只转换 ASCII 字母，保持长度不变，二进制内容的偏移仍然有效
+func asciiLower(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		if b >= 'A' && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		out[i] = b
+	}
+	return out
+}
+
+// hexMatch 从 pos 开始按顺序匹配十六进制串，跳转和候选序列通过回溯尝试，返回匹配结束的位置
+func hexMatch(tokens []hexToken, data []byte, pos int) (int, bool) {
+	if len(tokens) == 0 {
+		return pos, true
+	}
+	t := tokens[0]
+	switch t.kind {
+	case hexByte:
+		if pos < len(data) && data[pos]&t.mask == t.val {
+			return hexMatch(tokens[1:], data, pos+1)
+		}
+	case hexJump:
+		max := t.max
+		if max < 0 || pos+max > len(data) {
+			max = len(data) - pos
+		}
+		for n := t.min; n <= max; n++ {
+			if end, ok := hexMatch(tokens[1:], data, pos+n); ok {
+				return end, true
+			}
+		}
+	case hexAlt:
+		for _, alt := range t.alts {
+			seq := append(append(make([]hexToken, 0, len(alt)+len(tokens)-1), alt...), tokens[1:]...)
+			if end, ok := hexMatch(seq, data, pos); ok {
+				return end, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseYARARules 解析规则文本，name 用于错误信息
+func parseYARARules(name, src string) ([]*yaraRule, error) {
+	p := &yaraParser{name: name, src: src}
+	var rules []*yaraRule
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return rules, nil
+		}
+		r, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+}
+
+type yaraParser struct {
+	name string
+	src  string
+	pos  int
+	cur  *yaraRule
+}
+
+func (p *yaraParser) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return fmt.Errorf("%s 第 %d 行: %s", p.name, line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace 跳过空白和 // 、/* */ 注释
+func (p *yaraParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])):
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+				p.pos += i
+			} else {
+				p.pos = len(p.src)
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			if i := strings.Index(p.src[p.pos+2:], "*/"); i >= 0 {
+				p.pos += i + 4
+			} else {
+				p.pos = len(p.src)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *yaraParser) peekIdent() string {
+	p.skipSpace()
+	end := p.pos
+	for end < len(p.src) && isWordByte(p.src[end]) {
+		end++
+	}
+	return p.src[p.pos:end]
+}
+
+func (p *yaraParser) ident() (string, error) {
+	id := p.peekIdent()
+	if id == "" || (id[0] >= '0' && id[0] <= '9') {
+		return "", p.errorf("需要标识符")
+	}
+	p.pos += len(id)
+	return id, nil
+}
+
+// acceptWord 在下一个标识符为 word 时消耗它
+func (p *yaraParser) acceptWord(word string) bool {
+	if p.peekIdent() == word {
+		p.pos += len(word)
+		return true
+	}
+	return false
+}
+
+func (p *yaraParser) accept(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *yaraParser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("需要 %q", s)
+	}
+	return nil
+}
+
+func (p *yaraParser) rule() (*yaraRule, error) {
+	for _, word := range []string{"import", "include"} {
+		if p.acceptWord(word) {
+			return nil, p.errorf("不支持 %s", word)
+		}
+	}
+	for p.acceptWord("private") || p.acceptWord("global") {
+	}
+	if !p.acceptWord("rule") {
+		return nil, p.errorf("需要 rule")
+	}
+	id, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	r := &yaraRule{ID: id, Meta: make(map[string]string)}
+	p.cur = r
+	if p.accept(":") {
+		for {
+			p.skipSpace()
+			if p.pos >= len(p.src) || p.src[p.pos] == '{' {
+				break
+			}
+			tag, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			r.Tags = append(r.Tags, tag)
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if p.acceptWord("meta") {
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		for next := p.peekIdent(); next != "" && next != "strings" && next != "condition"; next = p.peekIdent() {
+			if err := p.meta(r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.acceptWord("strings") {
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		for p.accept("$") {
+			s, err := p.stringDef()
+			if err != nil {
+				return nil, err
+			}
+			r.strings = append(r.strings, s)
+		}
+	}
+	if !p.acceptWord("condition") {
+		return nil, p.errorf("规则 %s 缺少 condition", id)
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if r.cond, err = p.orExpr(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	if ft := r.Meta["filetype"]; ft != "" {
+		for _, t := range strings.Split(ft, ",") {
+			r.FileTypes = append(r.FileTypes, strings.ToLower(strings.TrimSpace(t)))
+		}
+	}
+	return r, nil
+}
+
+func (p *yaraParser) meta(r *yaraRule) error {
+	key, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	p.skipSpace()
+	switch {
+	case p.pos < len(p.src) && p.src[p.pos] == '"':
+		v, err := p.quoted()
+		if err != nil {
+			return err
+		}
+		r.Meta[key] = v
+	case p.acceptWord("true"):
+		r.Meta[key] = "true"
+	case p.acceptWord("false"):
+		r.Meta[key] = "false"
+	default:
+		n, ok := p.number()
+		if !ok {
+			return p.errorf("meta %s 的值无效", key)
+		}
+		r.Meta[key] = strconv.FormatInt(n, 10)
+	}
+	return nil
+}
+
+// quoted 解析双引号字符串，支持 \n \t \r \\ \" 和 \xHH 转义
+func (p *yaraParser) quoted() (string, error) {
+	if err := p.expect(`"`); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\n':
+			return "", p.errorf("字符串没有结束")
+		case '\\':
+			if p.pos >= len(p.src) {
+				return "", p.errorf("字符串没有结束")
+			}
+			e := p.src[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '\\', '"':
+				b.WriteByte(e)
+			case 'x':
+				if p.pos+2 > len(p.src) {
+					return "", p.errorf("无效的 \\x 转义")
+				}
+				v, err := strconv.ParseUint(p.src[p.pos:p.pos+2], 16, 8)
+				if err != nil {
+					return "", p.errorf("无效的 \\x 转义")
+				}
+				b.WriteByte(byte(v))
+				p.pos += 2
+			default:
+				return "", p.errorf("不支持的转义 \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("字符串没有结束")
+}
+
+// number 解析十进制或 0x 开头的十六进制整数，可以带 KB、MB 后缀
+func (p *yaraParser) number() (int64, bool) {
+	word := p.peekIdent()
+	if word == "" || word[0] < '0' || word[0] > '9' {
+		return 0, false
+	}
+	mult := int64(1)
+	digits := word
+	if !strings.HasPrefix(word, "0x") {
+		switch {
+		case strings.HasSuffix(word, "KB"):
+			mult, digits = 1<<10, strings.TrimSuffix(word, "KB")
+		case strings.HasSuffix(word, "MB"):
+			mult, digits = 1<<20, strings.TrimSuffix(word, "MB")
+		}
+	}
+	n, err := strconv.ParseInt(digits, 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	p.pos += len(word)
+	return n * mult, true
+}
+
+func (p *yaraParser) stringDef() (*yaraString, error) {
+	id, err := p.ident()
+	if err != nil {
+		return nil, p.errorf("不支持匿名字符串")
+	}
+	for _, s := range p.cur.strings {
+		if s.id == "$"+id {
+			return nil, p.errorf("重复的字符串 $%s", id)
+		}
+	}
+	s := &yaraString{id: "$" + id}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("字符串 %s 没有值", s.id)
+	}
+	var text, regex string
+	switch p.src[p.pos] {
+	case '"':
+		s.kind = yaraText
+		if text, err = p.quoted(); err != nil {
+			return nil, err
+		}
+		if text == "" {
+			return nil, p.errorf("字符串 %s 为空", s.id)
+		}
+	case '{':
+		s.kind = yaraHex
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if end < 0 {
+			return nil, p.errorf("十六进制串没有结束")
+		}
+		if s.hex, err = parseHexString(p.src[p.pos+1 : p.pos+end]); err != nil {
+			return nil, p.errorf("十六进制串 %s 无效: %v", s.id, err)
+		}
+		p.pos += end + 1
+	case '/':
+		s.kind = yaraRegex
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] != '/' {
+			if p.src[p.pos] == '\n' {
+				return nil, p.errorf("正则没有结束")
+			}
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("正则没有结束")
+		}
+		regex = strings.ReplaceAll(p.src[start:p.pos], `\/`, "/")
+		p.pos++
+		for p.pos < len(p.src) && (p.src[p.pos] == 'i' || p.src[p.pos] == 's') {
+			regex = "(?" + string(p.src[p.pos]) + ")" + regex
+			p.pos++
+		}
+	default:
+		return nil, p.errorf("字符串 %s 的值无效", s.id)
+	}
+
+	ascii := false
+modifiers:
+	for {
+		switch {
+		case p.acceptWord("nocase"):
+			s.nocase = true
+		case p.acceptWord("wide"):
+			s.wide = true
+		case p.acceptWord("ascii"):
+			ascii = true
+		case p.acceptWord("fullword"):
+			s.fullword = true
+		case p.acceptWord("private"):
+		default:
+			if word := p.peekIdent(); word == "xor" || word == "base64" || word == "base64wide" {
+				return nil, p.errorf("不支持修饰符 %s", word)
+			}
+			break modifiers
+		}
+	}
+	switch s.kind {
+	case yaraText:
+		if s.nocase {
+			text = string(asciiLower([]byte(text)))
+		}
+		if !s.wide || ascii {
+			s.patterns = append(s.patterns, []byte(text))
+		}
+		if s.wide {
+			wide := make([]byte, 0, len(text)*2)
+			for i := 0; i < len(text); i++ {
+				wide = append(wide, text[i], 0)
+			}
+			s.patterns = append(s.patterns, wide)
+		}
+	case yaraHex:
+		if s.nocase || s.wide || s.fullword {
+			return nil, p.errorf("十六进制串 %s 不支持 nocase、wide 和 fullword", s.id)
+		}
+	case yaraRegex:
+		if s.wide {
+			return nil, p.errorf("正则 %s 不支持 wide", s.id)
+		}
+		if s.nocase {
+			regex = "(?i)" + regex
+		}
+		if s.re, err = regexp.Compile(regex); err != nil {
+			return nil, p.errorf("正则 %s 无效: %v", s.id, err)
+		}
+		s.atom, s.atomFold = regexAtom(regex)
+	}
+	return s, nil
+}
+
+// parseHexString 解析十六进制串的内容，例如 4D 5A ?? [2-4] (01 | 02)
+func parseHexString(src string) ([]hexToken, error) {
+	src = strings.Join(strings.Fields(src), "")
+	tokens, rest, err := parseHexSequence(src)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("多余的内容 %q", rest)
+	}
+	if len(tokens) == 0 || tokens[0].kind == hexJump || tokens[len(tokens)-1].kind == hexJump {
+		return nil, fmt.Errorf("不能为空，也不能以跳转开始或结束")
+	}
+	return tokens, nil
+}
+
+// parseHexSequence 解析到 | 或 ) 为止的一段序列，返回剩余的内容
+func parseHexSequence(src string) ([]hexToken, string, error) {
+	var tokens []hexToken
+	for src != "" {
+		switch src[0] {
+		case '|', ')':
+			return tokens, src, nil
+		case '(':
+			t := hexToken{kind: hexAlt}
+			src = src[1:]
+			for {
+				alt, rest, err := parseHexSequence(src)
+				if err != nil {
+					return nil, "", err
+				}
+				if len(alt) == 0 {
+					return nil, "", fmt.Errorf("空的候选序列")
+				}
+				t.alts = append(t.alts, alt)
+				if rest == "" {
+					return nil, "", fmt.Errorf("缺少 )")
+				}
+				src = rest[1:]
+				if rest[0] == ')' {
+					break
+				}
+			}
+			tokens = append(tokens, t)
+		case '[':
+			end := strings.IndexByte(src, ']')
+			if end < 0 {
+				return nil, "", fmt.Errorf("缺少 ]")
+			}
+			t := hexToken{kind: hexJump}
+			lo, hi, isRange := strings.Cut(src[1:end], "-")
+			var err error
+			if t.min, err = strconv.Atoi(lo); err != nil && !(isRange && lo == "") {
+				return nil, "", fmt.Errorf("无效的跳转 %s", src[:end+1])
+			}
+			t.max = t.min
+			if isRange {
+				t.max = -1
+				if hi != "" {
+					if t.max, err = strconv.Atoi(hi); err != nil || t.max < t.min {
+						return nil, "", fmt.Errorf("无效的跳转 %s", src[:end+1])
+					}
+				}
+			}
+			tokens = append(tokens, t)
+			src = src[end+1:]
+		default:
+			if len(src) < 2 {
+				return nil, "", fmt.Errorf("不完整的字节 %q", src)
+			}
+			t := hexToken{kind: hexByte}
+			for i, shift := range []uint{4, 0} {
+				c := src[i]
+				if c == '?' {
+					continue
+				}
+				v, err := strconv.ParseUint(string(c), 16, 8)
+				if err != nil {
+					return nil, "", fmt.Errorf("无效的字节 %q", src[:2])
+				}
+				t.val |= byte(v) << shift
+				t.mask |= 0xf << shift
+			}
+			if t.mask == 0 {
+				// ?? 等价于长度为 1 的跳转
+				t = hexToken{kind: hexJump, min: 1, max: 1}
+			}
+			tokens = append(tokens, t)
+			src = src[2:]
+		}
+	}
+	return tokens, "", nil
+}
+
+func (p *yaraParser) orExpr() (yaraExpr, error) {
+	left, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptWord("or") {
+		right, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(c *yaraContext) int64 { return yaraBool(l(c) != 0 || right(c) != 0) }
+	}
+	return left, nil
+}
+
+func (p *yaraParser) andExpr() (yaraExpr, error) {
+	left, err := p.notExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptWord("and") {
+		right, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(c *yaraContext) int64 { return yaraBool(l(c) != 0 && right(c) != 0) }
+	}
+	return left, nil
+}
+
+func (p *yaraParser) notExpr() (yaraExpr, error) {
+	if p.acceptWord("not") {
+		e, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		return func(c *yaraContext) int64 { return yaraBool(e(c) == 0) }, nil
+	}
+	return p.cmpExpr()
+}
+
+func (p *yaraParser) cmpExpr() (yaraExpr, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		return func(c *yaraContext) int64 {
+			a, b := left(c), right(c)
+			switch op {
+			case "==":
+				return yaraBool(a == b)
+			case "!=":
+				return yaraBool(a != b)
+			case "<=":
+				return yaraBool(a <= b)
+			case ">=":
+				return yaraBool(a >= b)
+			case "<":
+				return yaraBool(a < b)
+			}
+			return yaraBool(a > b)
+		}, nil
+	}
+	return left, nil
+}
+
+func (p *yaraParser) primary() (yaraExpr, error) {
+	p.skipSpace()
+	switch {
+	case p.accept("("):
+		e, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case p.accept("$"):
+		i, err := p.stringRef()
+		if err != nil {
+			return nil, err
+		}
+		if p.acceptWord("at") {
+			off, ok := p.number()
+			if !ok {
+				return nil, p.errorf("at 后需要偏移")
+			}
+			return func(c *yaraContext) int64 {
+				return yaraBool(c.rule.strings[i].matchAt(c.file, int(off)))
+			}, nil
+		}
+		return func(c *yaraContext) int64 { return yaraBool(c.matched(i)) }, nil
+	case p.accept("#"):
+		i, err := p.stringRef()
+		if err != nil {
+			return nil, err
+		}
+		return func(c *yaraContext) int64 { return int64(c.count(i)) }, nil
+	case p.acceptWord("true"):
+		return func(*yaraContext) int64 { return 1 }, nil
+	case p.acceptWord("false"):
+		return func(*yaraContext) int64 { return 0 }, nil
+	case p.acceptWord("filesize"):
+		return func(c *yaraContext) int64 { return int64(len(c.file.data)) }, nil
+	case p.acceptWord("any"):
+		return p.ofExpr(-1)
+	case p.acceptWord("all"):
+		return p.ofExpr(-2)
+	case p.acceptWord("none"):
+		return p.ofExpr(0)
+	}
+	for _, fn := range []string{"uint8", "uint16", "uint32", "uint16be", "uint32be"} {
+		if p.acceptWord(fn) {
+			return p.intAt(fn)
+		}
+	}
+	if n, ok := p.number(); ok {
+		if p.peekIdent() == "of" {
+			return p.ofExpr(int(n))
+		}
+		return func(*yaraContext) int64 { return n }, nil
+	}
+	return nil, p.errorf("无效的条件表达式")
+}
+
+// stringRef 解析条件中引用的字符串名称（不含前缀），返回其序号
+func (p *yaraParser) stringRef() (int, error) {
+	id, err := p.ident()
+	if err != nil {
+		return 0, err
+	}
+	for i, s := range p.cur.strings {
+		if s.id == "$"+id {
+			return i, nil
+		}
+	}
+	return 0, p.errorf("未定义的字符串 $%s", id)
+}
+
+// ofExpr 解析 "of them" 或 "of ($a, $b*)"，n 为 -1 表示 any，-2 表示 all，其余为至少命中的数量（none 为 0）
+func (p *yaraParser) ofExpr(n int) (yaraExpr, error) {
+	if !p.acceptWord("of") {
+		return nil, p.errorf("需要 of")
+	}
+	var set []int
+	if p.acceptWord("them") {
+		for i := range p.cur.strings {
+			set = append(set, i)
+		}
+	} else {
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name := "$" + p.peekIdent()
+			p.pos += len(name) - 1
+			wildcard := p.accept("*")
+			matched := false
+			for i, s := range p.cur.strings {
+				if s.id == name || (wildcard && strings.HasPrefix(s.id, name)) {
+					if !containsInt(set, i) {
+						set = append(set, i)
+					}
+					matched = true
+				}
+			}
+			if !matched {
+				return nil, p.errorf("未定义的字符串 %s", name)
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if len(set) == 0 {
+		return nil, p.errorf("规则没有定义字符串")
+	}
+	none := n == 0
+	return func(c *yaraContext) int64 {
+		need := n
+		switch n {
+		case -1:
+			need = 1
+		case -2:
+			need = len(set)
+		}
+		hits := 0
+		for _, i := range set {
+			if c.matched(i) {
+				hits++
+				if !none && hits >= need {
+					return 1
+				}
+			}
+		}
+		if none {
+			return yaraBool(hits == 0)
+		}
+		return 0
+	}, nil
+}
+
+// intAt 解析 uint8(偏移) 等读取整数的函数，超出文件范围时为 0
+func (p *yaraParser) intAt(fn string) (yaraExpr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	off, ok := p.number()
+	if !ok {
+		return nil, p.errorf("%s 需要整数偏移", fn)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	size := map[string]int64{"uint8": 1, "uint16": 2, "uint32": 4, "uint16be": 2, "uint32be": 4}[fn]
+	return func(c *yaraContext) int64 {
+		data := c.file.data
+		if off+size > int64(len(data)) {
+			return 0
+		}
+		b := data[off : off+size]
+		switch fn {
+		case "uint8":
+			return int64(b[0])
+		case "uint16":
+			return int64(binary.LittleEndian.Uint16(b))
+		case "uint32":
+			return int64(binary.LittleEndian.Uint32(b))
+		case "uint16be":
+			return int64(binary.BigEndian.Uint16(b))
+		}
+		return int64(binary.BigEndian.Uint32(b))
+	}, nil
+}
+
+func yaraBool(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -12,36 +12,72 @@ import (
 
 // LogConfig 日志配置
 type LogConfig struct {
-	Dir    string `json:"dir"`
-	File   string `json:"file"`
-	Level  string `json:"level"`
-	Format string `json:"format"`
+	Dir    string `json:"dir" yaml:"dir"`
+	File   string `json:"file" yaml:"file"`
+	Level  string `json:"level" yaml:"level"`
+	Format string `json:"format" yaml:"format"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Host           string        `json:"host"`
-	Port           int           `json:"port"`
+	Host           string        `json:"host" yaml:"host"`
+	Port           int           `json:"port" yaml:"port"`
 	ReadTimeout    time.Duration `json:"read_timeout"`
 	WriteTimeout   time.Duration `json:"write_timeout"`
-	MaxRequestSize int64         `json:"max_request_size"`
+	MaxRequestSize int64         `json:"max_request_size" yaml:"max_request_size"`
 }
 
 // AnalyzeConfig 分析配置
 type AnalyzeConfig struct {
-	UnpackDir           string   `json:"unpack_dir"`
-	CheckOSInfo         bool     `json:"check_os_info"`
-	CheckPythonPackages bool     `json:"check_python_packages"`
-	CheckCommonTools    bool     `json:"check_common_tools"`
-	SpecificCommands    []string `json:"specific_commands"`
+	UnpackDir              string        `json:"unpack_dir" yaml:"unpack_dir"`
+	CheckOSInfo            bool          `json:"check_os_info" yaml:"check_os_info"`
+	CheckPythonPackages    bool          `json:"check_python_packages" yaml:"check_python_packages"`
+	CheckPythonEnvs        bool          `json:"check_python_envs" yaml:"check_python_envs"`
+	CheckPythonDeps        bool          `json:"check_python_deps" yaml:"check_python_deps"`
+	CheckConda             bool          `json:"check_conda" yaml:"check_conda"`
+	VerifyPythonRecords    bool          `json:"verify_python_records" yaml:"verify_python_records"`
+	VerifyOSPackages       bool          `json:"verify_os_packages" yaml:"verify_os_packages"`
+	CheckNode              bool          `json:"check_node" yaml:"check_node"`
+	CheckGoBinaries        bool          `json:"check_go_binaries" yaml:"check_go_binaries"`
+	CheckJava              bool          `json:"check_java" yaml:"check_java"`
+	CheckPackageEcosystems bool          `json:"check_package_ecosystems" yaml:"check_package_ecosystems"`
+	CheckELF               bool          `json:"check_elf" yaml:"check_elf"`
+	CheckCUDA              bool          `json:"check_cuda" yaml:"check_cuda"`
+	CheckModels            bool          `json:"check_models" yaml:"check_models"`
+	CheckRuntimes          bool          `json:"check_runtimes" yaml:"check_runtimes"`
+	CheckSecrets           bool          `json:"check_secrets" yaml:"check_secrets"`
+	SecretRuleFiles        []string      `json:"secret_rule_files" yaml:"secret_rule_files"`
+	SecretAllowPaths       []string      `json:"secret_allow_paths" yaml:"secret_allow_paths"`
+	SecretAllowRegexes     []string      `json:"secret_allow_regexes" yaml:"secret_allow_regexes"`
+	CheckMalware           bool          `json:"check_malware" yaml:"check_malware"`
+	MalwareRuleFiles       []string      `json:"malware_rule_files" yaml:"malware_rule_files"`
+	MalwareHashLists       []string      `json:"malware_hash_lists" yaml:"malware_hash_lists"`
+	MalwareMaxFileSize     int64         `json:"malware_max_file_size" yaml:"malware_max_file_size"`
+	CheckLicenses          bool          `json:"check_licenses" yaml:"check_licenses"`
+	LicenseAllow           []string      `json:"license_allow" yaml:"license_allow"`
+	LicenseDeny            []string      `json:"license_deny" yaml:"license_deny"`
+	CheckCertificates      bool          `json:"check_certificates" yaml:"check_certificates"`
+	RequiredCAs            []string      `json:"required_cas" yaml:"required_cas"`
+	CheckAccounts          bool          `json:"check_accounts" yaml:"check_accounts"`
+	CheckPrivileges        bool          `json:"check_privileges" yaml:"check_privileges"`
+	CheckStartup           bool          `json:"check_startup" yaml:"check_startup"`
+	CheckHardening         bool          `json:"check_hardening" yaml:"check_hardening"`
+	VulnDB                 string        `json:"vuln_db" yaml:"vuln_db"`
+	VulnDBMaxAge           time.Duration `json:"vuln_db_max_age" yaml:"vuln_db_max_age"`
+	AllowStaleDB           bool          `json:"allow_stale_db" yaml:"allow_stale_db"`
+	IntegrityWorkers       int           `json:"integrity_workers" yaml:"integrity_workers"`
+	CheckCommonTools       bool          `json:"check_common_tools" yaml:"check_common_tools"`
+	// CommonTools 为 check_common_tools 开启时按 PATH 检查的命令
+	CommonTools      []string `json:"common_tools" yaml:"common_tools"`
+	SpecificCommands []string `json:"specific_commands" yaml:"specific_commands"`
 }
 
 // Config 全局配置
 type Config struct {
-	Log     LogConfig     `json:"log"`
-	Server  ServerConfig  `json:"server"`
-	Analyze AnalyzeConfig `json:"analyze"`
-	GinMode string        `json:"gin_mode"`
+	Log     LogConfig     `json:"log" yaml:"log"`
+	Server  ServerConfig  `json:"server" yaml:"server"`
+	Analyze AnalyzeConfig `json:"analyze" yaml:"analyze"`
+	GinMode string        `json:"gin_mode" yaml:"gin_mode"`
 }
 
 // DefaultConfig 返回默认配置
@@ -61,11 +97,31 @@ func DefaultConfig() *Config {
 			MaxRequestSize: 10 * 1024 * 1024, // 10MB
 		},
 		Analyze: AnalyzeConfig{
-			UnpackDir:           "images",
-			CheckOSInfo:         true,
-			CheckPythonPackages: true,
-			CheckCommonTools:    true,
-			SpecificCommands:    []string{},
+			UnpackDir:              "images",
+			CheckOSInfo:            true,
+			CheckPythonPackages:    true,
+			CheckPythonEnvs:        true,
+			CheckPythonDeps:        true,
+			CheckConda:             true,
+			CheckCommonTools:       true,
+			CheckNode:              true,
+			CheckGoBinaries:        true,
+			CheckJava:              true,
+			CheckPackageEcosystems: true,
+			CheckCUDA:              true,
+			CheckModels:            true,
+			CheckRuntimes:          true,
+			CommonTools:            []string{"sshd", "python3", "curl", "wget", "nvcc"},
+			CheckSecrets:           true,
+			CheckMalware:           true,
+			CheckLicenses:          true,
+			CheckCertificates:      true,
+			CheckAccounts:          true,
+			CheckPrivileges:        true,
+			CheckStartup:           true,
+			CheckHardening:         true,
+			VulnDBMaxAge:           7 * 24 * time.Hour,
+			SpecificCommands:       []string{},
 		},
 		GinMode: gin.DebugMode,
 	}
@@ -0,0 +1,17 @@
+package config
+
+import "testing"
+
+// TestLoadShippedConfig 确保仓库自带的 config.yaml 可以被加载
+func TestLoadShippedConfig(t *testing.T) {
+	cfg, err := LoadConfig("../../config.yaml")
+	if err != nil {
+		t.Fatalf("加载 config.yaml 失败: %v", err)
+	}
+	if cfg.Server.Port != 8080 {
+		t.Errorf("server.port = %d, want 8080", cfg.Server.Port)
+	}
+	if len(cfg.Analyze.CommonTools) == 0 {
+		t.Error("analyze.common_tools 为空")
+	}
+}
@@ -32,15 +32,59 @@ func (a *AnalyzeImage) HandleAnalyze(c *gin.Context) {
 
 	if req.Options == nil {
 		req.Options = &analyze.AnalyzeOptions{
-			CheckOSInfo:         a.cfg.Analyze.CheckOSInfo,
-			CheckPythonPackages: a.cfg.Analyze.CheckPythonPackages,
-			CheckCommonTools:    a.cfg.Analyze.CheckCommonTools,
-			SpecificCommands:    a.cfg.Analyze.SpecificCommands,
+			CheckOSInfo:            a.cfg.Analyze.CheckOSInfo,
+			CheckPythonPackages:    a.cfg.Analyze.CheckPythonPackages,
+			CheckPythonEnvs:        a.cfg.Analyze.CheckPythonEnvs,
+			CheckPythonDeps:        a.cfg.Analyze.CheckPythonDeps,
+			CheckConda:             a.cfg.Analyze.CheckConda,
+			VerifyPythonRecords:    a.cfg.Analyze.VerifyPythonRecords,
+			VerifyOSPackages:       a.cfg.Analyze.VerifyOSPackages,
+			CheckNode:              a.cfg.Analyze.CheckNode,
+			CheckGoBinaries:        a.cfg.Analyze.CheckGoBinaries,
+			CheckJava:              a.cfg.Analyze.CheckJava,
+			CheckPackageEcosystems: a.cfg.Analyze.CheckPackageEcosystems,
+			CheckELF:               a.cfg.Analyze.CheckELF,
+			CheckCUDA:              a.cfg.Analyze.CheckCUDA,
+			CheckModels:            a.cfg.Analyze.CheckModels,
+			CheckRuntimes:          a.cfg.Analyze.CheckRuntimes,
+			CheckSecrets:           a.cfg.Analyze.CheckSecrets,
+			SecretRuleFiles:        a.cfg.Analyze.SecretRuleFiles,
+			SecretAllowPaths:       a.cfg.Analyze.SecretAllowPaths,
+			SecretAllowRegexes:     a.cfg.Analyze.SecretAllowRegexes,
+			CheckMalware:           a.cfg.Analyze.CheckMalware,
+			MalwareRuleFiles:       a.cfg.Analyze.MalwareRuleFiles,
+			MalwareHashLists:       a.cfg.Analyze.MalwareHashLists,
+			MalwareMaxFileSize:     a.cfg.Analyze.MalwareMaxFileSize,
+			CheckLicenses:          a.cfg.Analyze.CheckLicenses,
+			LicenseAllow:           a.cfg.Analyze.LicenseAllow,
+			LicenseDeny:            a.cfg.Analyze.LicenseDeny,
+			CheckCertificates:      a.cfg.Analyze.CheckCertificates,
+			RequiredCAs:            a.cfg.Analyze.RequiredCAs,
+			CheckAccounts:          a.cfg.Analyze.CheckAccounts,
+			CheckPrivileges:        a.cfg.Analyze.CheckPrivileges,
+			CheckStartup:           a.cfg.Analyze.CheckStartup,
+			CheckHardening:         a.cfg.Analyze.CheckHardening,
+			VulnDB:                 a.cfg.Analyze.VulnDB,
+			VulnDBMaxAge:           a.cfg.Analyze.VulnDBMaxAge,
+			AllowStaleDB:           a.cfg.Analyze.AllowStaleDB,
+			IntegrityWorkers:       a.cfg.Analyze.IntegrityWorkers,
+			CheckCommonTools:       a.cfg.Analyze.CheckCommonTools,
+			CommonTools:            a.cfg.Analyze.CommonTools,
+			SpecificCommands:       a.cfg.Analyze.SpecificCommands,
 		}
 	}
+	// 以下选项指向服务器上的文件、决定漏洞库的时效策略或限制服务器的资源占用，只能由服务端配置决定，忽略请求中的取值
+	req.Options.SecretRuleFiles = a.cfg.Analyze.SecretRuleFiles
+	req.Options.MalwareRuleFiles = a.cfg.Analyze.MalwareRuleFiles
+	req.Options.MalwareHashLists = a.cfg.Analyze.MalwareHashLists
+	req.Options.MalwareMaxFileSize = a.cfg.Analyze.MalwareMaxFileSize
+	req.Options.IntegrityWorkers = a.cfg.Analyze.IntegrityWorkers
+	req.Options.VulnDB = a.cfg.Analyze.VulnDB
+	req.Options.VulnDBMaxAge = a.cfg.Analyze.VulnDBMaxAge
+	req.Options.AllowStaleDB = a.cfg.Analyze.AllowStaleDB
 
 	ctx := context.Background()
-	layersDir, imgCfg, err := imageutil.PullAndExtract(ctx, req.ImageRef, a.cfg.GetUnpackDir())
+	layersDir, imgCfg, extract, err := imageutil.PullAndExtract(ctx, req.ImageRef, a.cfg.GetUnpackDir())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("提取镜像失败: %v", err)})
 		return
@@ -54,21 +98,7 @@ func (a *AnalyzeImage) HandleAnalyze(c *gin.Context) {
 		}
 	}(layersDir)
 
-	summary := analyze.Summary{
-		Architecture: imgCfg.Architecture,
-		OS:           imgCfg.OS,
-		Env:          imgCfg.Config.Env,
-	}
-
-	if req.Options.CheckOSInfo {
-		summary.OSInfo = analyze.CheckOSInfo(layersDir)
-	}
-	if req.Options.CheckPythonPackages {
-		summary.PythonPackages = analyze.ListPythonPackages(layersDir)
-	}
-	if req.Options.CheckCommonTools {
-		summary.Tools = analyze.CheckCommonTools(layersDir)
-	}
+	summary := analyze.Analyze(layersDir, imgCfg, extract, req.Options)
 
 	var response []byte
 	var marshalErr error
@@ -4,10 +4,14 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"image-analyzer-go/pkg/logger"
@@ -22,13 +26,13 @@ import (
 )
 
 // PullAndExtract 从指定的镜像引用中提取镜像层
-// 返回提取的目录路径和镜像配置，如果发生错误则返回错误
-func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.Image, error) {
+// 返回提取的目录路径、镜像配置和解压过程中记录的文件来源，如果发生错误则返回错误
+func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.Image, *utils.ExtractInfo, error) {
 	// 确保 unpackDir 是绝对路径
 	var err error
 	unpackDir, err = utils.EnsureAbsPath(unpackDir)
 	if err != nil {
-		return "", nil, utils.WrapError(err, "转换为绝对路径失败")
+		return "", nil, nil, utils.WrapError(err, "转换为绝对路径失败")
 	}
 
 	sys := &types.SystemContext{
@@ -44,7 +48,7 @@ func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.
 	// 创建临时目录用于存储镜像
 	tmpDir, err := utils.CreateTempDir("image-layers")
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 
 	// 最后清理临时目录
@@ -57,13 +61,13 @@ func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.
 	// 创建源镜像引用
 	srcRef, err := docker.ParseReference("//" + refStr)
 	if err != nil {
-		return "", nil, utils.WrapError(err, "解析源镜像引用失败")
+		return "", nil, nil, utils.WrapError(err, "解析源镜像引用失败")
 	}
 
 	// 创建目标 OCI 布局引用
 	destRef, err := layout.NewReference(tmpDir, "latest")
 	if err != nil {
-		return "", nil, utils.WrapError(err, "创建目标 OCI 布局引用失败")
+		return "", nil, nil, utils.WrapError(err, "创建目标 OCI 布局引用失败")
 	}
 
 	// 创建策略上下文
@@ -74,7 +78,7 @@ func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.
 	}
 	policyContext, err := signature.NewPolicyContext(policy)
 	if err != nil {
-		return "", nil, utils.WrapError(err, "创建策略上下文失败")
+		return "", nil, nil, utils.WrapError(err, "创建策略上下文失败")
 	}
 	defer policyContext.Destroy()
 
@@ -93,21 +97,25 @@ func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.
 		Progress:         progress,
 	})
 	if err != nil {
-		return "", nil, utils.WrapError(err, "复制镜像到本地 OCI 布局失败")
+		return "", nil, nil, utils.WrapError(err, "复制镜像到本地 OCI 布局失败")
 	}
 	logger.Info("镜像下载完成")
 
 	// 打开目标镜像
 	destImg, err := destRef.NewImage(ctx, sys)
 	if err != nil {
-		return "", nil, utils.WrapError(err, "打开目标镜像失败")
+		return "", nil, nil, utils.WrapError(err, "打开目标镜像失败")
 	}
 	defer destImg.Close()
 
 	// 获取镜像配置
 	ociCfg, err := destImg.OCIConfig(ctx)
 	if err != nil {
-		return "", nil, utils.WrapError(err, "获取OCI配置失败")
+		return "", nil, nil, utils.WrapError(err, "获取OCI配置失败")
+	}
+	info := utils.NewExtractInfo()
+	if blob, err := destImg.ConfigBlob(ctx); err == nil {
+		info.Healthcheck = parseHealthcheck(blob)
 	}
 
 	// 从引用中提取镜像名称
@@ -115,7 +123,7 @@ func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.
 	// 创建统一的文件系统目录，使用unpack目录，并将镜像名作为子目录
 	fsDir := filepath.Join(unpackDir, imageName)
 	if err := os.MkdirAll(fsDir, 0755); err != nil {
-		return "", nil, utils.WrapError(err, "创建文件系统目录失败")
+		return "", nil, nil, utils.WrapError(err, "创建文件系统目录失败")
 	}
 
 	// 获取镜像层
@@ -129,20 +137,20 @@ func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.
 		// 获取层内容
 		blob, err := destRef.NewImageSource(ctx, sys)
 		if err != nil {
-			return "", nil, utils.WrapError(err, fmt.Sprintf("创建层 %d 源失败", i))
+			return "", nil, nil, utils.WrapError(err, fmt.Sprintf("创建层 %d 源失败", i))
 		}
 		defer blob.Close()
 
 		// 读取层数据
 		reader, _, err := blob.GetBlob(ctx, layer, nil)
 		if err != nil {
-			return "", nil, utils.WrapError(err, fmt.Sprintf("获取层 %d 数据失败", i))
+			return "", nil, nil, utils.WrapError(err, fmt.Sprintf("获取层 %d 数据失败", i))
 		}
 		defer reader.Close()
 
 		// 解压并提取层内容到统一文件系统目录
-		if err := decompressAndUntar(reader, fsDir); err != nil {
-			return "", nil, utils.WrapError(err, fmt.Sprintf("解压层 %d 失败", i))
+		if err := decompressAndUntar(reader, fsDir, i, info); err != nil {
+			return "", nil, nil, utils.WrapError(err, fmt.Sprintf("解压层 %d 失败", i))
 		}
 		logger.Info("层提取完成",
 			logger.WithInt("current", i+1),
@@ -150,11 +158,11 @@ func PullAndExtract(ctx context.Context, refStr, unpackDir string) (string, *v1.
 	}
 	logger.Info("所有镜像层提取完成，文件系统已完整解析", logger.WithString("fs_path", fsDir))
 
-	return fsDir, ociCfg, nil
+	return fsDir, ociCfg, info, nil
 }
 
-// decompressAndUntar 解压并提取 tar 文件内容
-func decompressAndUntar(r io.Reader, dest string) error {
+// decompressAndUntar 解压并提取 tar 文件内容，并在 info 中记录每个路径来自第几层
+func decompressAndUntar(r io.Reader, dest string, layer int, info *utils.ExtractInfo) error {
 	// 创建 gzip 读取器
 	gz, err := gzip.NewReader(r)
 	if err != nil {
@@ -173,18 +181,65 @@ func decompressAndUntar(r io.Reader, dest string) error {
 			return fmt.Errorf("读取 tar 头失败: %w", err)
 		}
 
-		// 构建目标路径
-		path := filepath.Join(dest, hdr.Name)
-		if hdr.Typeflag == tar.TypeDir {
-			// 创建目录
-			if err := os.MkdirAll(path, 0755); err != nil {
+		// 构建目标路径：父目录在 dest 内解析符号链接，防止通过链接写到 dest 之外
+		name := filepath.Join("/", hdr.Name)
+		if name == "/" {
+			continue
+		}
+		parent, err := utils.SecureJoin(dest, filepath.Dir(name))
+		if err != nil {
+			logger.Warn("解析路径失败，跳过该条目", logger.WithString("path", hdr.Name), logger.WithError(err))
+			continue
+		}
+		base := filepath.Base(name)
+		path := filepath.Join(parent, base)
+
+		// whiteout 删除下层中的路径，自身不写入文件系统
+		if base == whiteoutOpaque {
+			removeLowerEntries(parent, filepath.Dir(name), layer, info)
+			continue
+		}
+		if target, ok := strings.CutPrefix(base, whiteoutPrefix); ok {
+			_ = os.RemoveAll(filepath.Join(parent, target))
+			forgetPath(info, filepath.Join(filepath.Dir(name), target))
+			continue
+		}
+
+		// 除了目录覆盖目录，已存在的同名文件、链接或目录都先整体删除，避免通过符号链接写入其他位置
+		if hdr.Typeflag != tar.TypeDir {
+			if err := os.MkdirAll(parent, 0755); err != nil {
+				return fmt.Errorf("创建父目录失败: %w", err)
+			}
+			if fi, err := os.Lstat(path); err == nil && fi.IsDir() {
+				forgetPath(info, name)
+			}
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("删除已存在的 %s 失败: %w", hdr.Name, err)
+			}
+		}
+		info.Layers[name] = layer
+		recordFileAttrs(info, name, hdr)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// 创建目录，已存在的同名符号链接同样在 dest 内解析
+			dir, err := utils.SecureJoin(dest, name)
+			if err != nil {
+				logger.Warn("解析目录失败，跳过该条目", logger.WithString("path", hdr.Name), logger.WithError(err))
+				continue
+			}
+			if fi, err := os.Lstat(dir); err == nil && !fi.IsDir() {
+				_ = os.RemoveAll(dir)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
 				return fmt.Errorf("创建目录失败: %w", err)
 			}
-		} else if hdr.Typeflag == tar.TypeReg {
-			// 创建文件
-			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-				return fmt.Errorf("创建父目录失败: %w", err)
+			// 保留目录权限，但始终允许当前用户读写，以便继续解压和清理
+			if err := os.Chmod(dir, diskFileMode(hdr)|0700); err != nil {
+				return fmt.Errorf("设置目录权限失败: %w", err)
 			}
+		case tar.TypeReg:
+			// 创建文件
 			f, err := os.Create(path)
 			if err != nil {
 				return fmt.Errorf("创建文件失败: %w", err)
@@ -194,7 +249,204 @@ func decompressAndUntar(r io.Reader, dest string) error {
 				return fmt.Errorf("复制文件内容失败: %w", err)
 			}
 			f.Close()
+			// 保留可执行位，分析时按 PATH 解析命令需要用到，始终允许当前用户读取
+			if err := os.Chmod(path, diskFileMode(hdr)|0400); err != nil {
+				return fmt.Errorf("设置文件权限失败: %w", err)
+			}
+		case tar.TypeSymlink:
+			// 链接目标改写为不越过 dest 的相对路径：镜像中指向绝对路径或用 .. 越过根目录的链接
+			// 在宿主机上会指向 dest 之外，分析时直接打开这类链接会读到宿主机上的文件
+			rel, err := filepath.Rel(dest, parent)
+			if err != nil {
+				return fmt.Errorf("解析路径 %s 失败: %w", hdr.Name, err)
+			}
+			if err := os.Symlink(confineLinkTarget(filepath.Join("/", rel), hdr.Linkname), path); err != nil {
+				logger.Warn("创建符号链接失败，跳过该条目", logger.WithString("path", hdr.Name), logger.WithError(err))
+				forgetPath(info, name)
+			}
+		case tar.TypeLink:
+			// 硬链接目标的父目录同样在 dest 内解析，目标本身是符号链接时链接到符号链接
+			targetName := filepath.Join("/", hdr.Linkname)
+			targetDir, err := utils.SecureJoin(dest, filepath.Dir(targetName))
+			if err == nil {
+				err = os.Link(filepath.Join(targetDir, filepath.Base(targetName)), path)
+			}
+			if err != nil {
+				logger.Warn("创建硬链接失败，跳过该条目", logger.WithString("path", hdr.Name),
+					logger.WithString("target", hdr.Linkname), logger.WithError(err))
+				forgetPath(info, name)
+			}
 		}
 	}
 	return nil
 }
+
+// OCI 镜像层中的 whiteout 文件名：.wh.<name> 删除下层的同名路径，.wh..wh..opq 清空下层中的目录内容
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// removeLowerEntries 处理不透明 whiteout，删除目录 dir（宿主机路径，镜像内为 name）中来自下层的条目，
+// 保留当前层已经写入的条目
+func removeLowerEntries(dir, name string, layer int, info *utils.ExtractInfo) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		child := filepath.Join(name, e.Name())
+		if l, ok := info.Layers[child]; ok && l == layer {
+			continue
+		}
+		_ = os.RemoveAll(filepath.Join(dir, e.Name()))
+		forgetPath(info, child)
+	}
+}
+
+// forgetPath 删除被 whiteout 删除的路径及其子路径在 info 中的记录
+func forgetPath(info *utils.ExtractInfo, name string) {
+	prefix := name + "/"
+	match := func(p string) bool { return p == name || strings.HasPrefix(p, prefix) }
+	for p := range info.Layers {
+		if match(p) {
+			delete(info.Layers, p)
+		}
+	}
+	for p := range info.Owners {
+		if match(p) {
+			delete(info.Owners, p)
+		}
+	}
+	for p := range info.Xattrs {
+		if match(p) {
+			delete(info.Xattrs, p)
+		}
+	}
+	for p := range info.Modes {
+		if match(p) {
+			delete(info.Modes, p)
+		}
+	}
+}
+
+// confineLinkTarget 将符号链接目标改写为相对于链接所在目录 dir（镜像内已解析的路径）的路径。
+// 目标先按镜像根目录规整，.. 不会越过根目录，因此在宿主机上解析时也停留在解压目录内
+func confineLinkTarget(dir, target string) string {
+	if target == "" {
+		return target
+	}
+	abs := target
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(dir, abs)
+	}
+	rel, err := filepath.Rel(dir, filepath.Join("/", abs))
+	if err != nil {
+		return "."
+	}
+	return rel
+}
+
+// recordFileAttrs 记录解压时不会保存到文件系统的属主、扩展属性和特殊权限位，后面的层覆盖同一路径时替换之前的记录。
+// 硬链接条目通常不带扩展属性，沿用链接目标的记录；硬链接与目标共用同一 inode，权限位同样沿用目标的记录
+func recordFileAttrs(info *utils.ExtractInfo, name string, hdr *tar.Header) {
+	xattrs := tarXattrs(hdr)
+	owner := utils.Owner{UID: hdr.Uid, GID: hdr.Gid}
+	mode, hasMode := tarFileMode(hdr)|hdr.FileInfo().Mode().Type(), false
+	// 硬链接沿用了目标的属主记录时保留该记录，硬链接条目的头中未必带有 setuid/setgid 位
+	linkedOwner := false
+	switch hdr.Typeflag {
+	case tar.TypeDir, tar.TypeReg:
+		// 带扩展属性的文件同样记录，文件能力报告需要镜像中的原始权限位
+		hasMode = tarFileMode(hdr) != diskFileMode(hdr) || len(xattrs) > 0
+	case tar.TypeLink:
+		target := filepath.Join("/", hdr.Linkname)
+		if len(xattrs) == 0 {
+			xattrs = info.Xattrs[target]
+			if o, ok := info.Owners[target]; ok {
+				owner, linkedOwner = o, true
+			}
+		}
+		if m, ok := info.Modes[target]; ok {
+			mode, hasMode = m, true
+		} else {
+			hasMode = len(xattrs) > 0
+		}
+	}
+	if hasMode {
+		info.Modes[name] = mode
+	} else {
+		delete(info.Modes, name)
+	}
+	if len(xattrs) > 0 {
+		info.Xattrs[name] = xattrs
+	} else {
+		delete(info.Xattrs, name)
+	}
+	if hdr.Typeflag == tar.TypeDir || len(xattrs) > 0 || linkedOwner || hdr.Mode&(cISUID|cISGID) != 0 {
+		info.Owners[name] = owner
+	} else {
+		delete(info.Owners, name)
+	}
+}
+
+// tar 头中 setuid 和 setgid 位的取值
+const (
+	cISUID = 04000
+	cISGID = 02000
+)
+
+// tarXattrs 读取 PAX 头中的扩展属性：SCHILY.xattr.* 为原始值，
+// libarchive 使用的 LIBARCHIVE.xattr.* 属性名经过 URL 编码，值为 base64 编码
+func tarXattrs(hdr *tar.Header) map[string]string {
+	var xattrs map[string]string
+	for key, value := range hdr.PAXRecords {
+		var name string
+		if n, ok := strings.CutPrefix(key, "SCHILY.xattr."); ok {
+			name = n
+		} else if n, ok := strings.CutPrefix(key, "LIBARCHIVE.xattr."); ok {
+			// libarchive 写入时省略了末尾的 =
+			decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				continue
+			}
+			if name, err = url.PathUnescape(n); err != nil {
+				continue
+			}
+			value = string(decoded)
+		} else {
+			continue
+		}
+		if xattrs == nil {
+			xattrs = make(map[string]string)
+		}
+		xattrs[name] = value
+	}
+	return xattrs
+}
+
+// tarFileMode 返回 tar 条目中的权限位，包括 setuid、setgid 和 sticky
+func tarFileMode(hdr *tar.Header) os.FileMode {
+	return hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// diskFileMode 返回解压到宿主机时使用的权限位：去掉 setuid、setgid、sticky 位和其他用户的写权限，
+// 镜像内容不可信，不能在宿主机上留下可被提权或被其他用户改写的文件
+func diskFileMode(hdr *tar.Header) os.FileMode {
+	return tarFileMode(hdr) &^ (os.ModeSetuid | os.ModeSetgid | os.ModeSticky | 0002)
+}
+
+// parseHealthcheck 从原始的镜像配置中读取 Docker 格式的 HEALTHCHECK
+func parseHealthcheck(blob []byte) []string {
+	var cfg struct {
+		Config struct {
+			Healthcheck *struct {
+				Test []string `json:"Test"`
+			} `json:"Healthcheck"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(blob, &cfg); err != nil || cfg.Config.Healthcheck == nil {
+		return nil
+	}
+	return cfg.Config.Healthcheck.Test
+}
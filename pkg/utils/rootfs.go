@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinkDepth 是解析路径时允许跟随的最大符号链接数量，与 Linux 的 MAXSYMLINKS 保持一致
+const maxSymlinkDepth = 40
+
+// ResolveInRoot 以 root 作为根目录解析镜像内的路径，跟随其中所有的符号链接，
+// 绝对路径的链接目标同样相对于 root 解析，结果不会逃逸出 root。
+// 返回解析后的镜像内绝对路径，以及解析过程中依次经过的符号链接（镜像内路径）。
+// 路径中不存在的部分按字面拼接，不视为错误。
+func ResolveInRoot(root, path string) (string, []string, error) {
+	var links []string
+	resolved := "/"
+	unsafe := path
+	for unsafe != "" {
+		var part string
+		if i := strings.IndexByte(unsafe, '/'); i >= 0 {
+			part, unsafe = unsafe[:i], unsafe[i+1:]
+		} else {
+			part, unsafe = unsafe, ""
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if len(links) >= maxSymlinkDepth {
+			return "", links, fmt.Errorf("解析 %s 时符号链接层数过多", path)
+		}
+		links = append(links, next)
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", links, fmt.Errorf("读取符号链接 %s 失败: %w", next, err)
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		if unsafe != "" {
+			target += "/" + unsafe
+		}
+		unsafe = target
+	}
+	return resolved, links, nil
+}
+
+// SecureJoin 将镜像内路径解析为 root 下的宿主机路径，保证结果不会逃逸出 root
+func SecureJoin(root, path string) (string, error) {
+	resolved, _, err := ResolveInRoot(root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+// ExtractInfo 记录解压镜像层时得到、但没有保存在文件系统中的信息
+type ExtractInfo struct {
+	// Layers 为每个镜像内路径最后一次被写入时所在的层序号，从 0 开始
+	Layers map[string]int
+	// Healthcheck 为 Docker 格式镜像配置中 HEALTHCHECK 的 Test 命令，OCI 配置中没有该字段。
+	// ["NONE"] 表示显式禁用，nil 表示未设置
+	Healthcheck []string
+	// Owners 为 tar 条目中记录的属主。解压时不保留属主，为控制内存只记录目录、
+	// 设置了 setuid/setgid 位的文件和带扩展属性的文件
+	Owners map[string]Owner
+	// Xattrs 为 tar 的 PAX 头中记录的扩展属性，例如 security.capability，解压时不写入文件系统
+	Xattrs map[string]map[string]string
+	// Modes 为 tar 条目中带 setuid/setgid/sticky 位、其他用户可写或带扩展属性的目录和普通文件的模式，包括类型位。
+	// 解压时不把特殊权限位写到宿主机上，只记录在这里
+	Modes map[string]os.FileMode
+}
+
+// Owner 是 tar 条目中记录的属主
+type Owner struct {
+	UID int `json:"uid"`
+	GID int `json:"gid"`
+}
+
+// NewExtractInfo 创建空的 ExtractInfo
+func NewExtractInfo() *ExtractInfo {
+	return &ExtractInfo{Layers: make(map[string]int), Owners: make(map[string]Owner), Xattrs: make(map[string]map[string]string), Modes: make(map[string]os.FileMode)}
+}
+
+// Layer 返回镜像内路径最后一次被写入时所在的层序号，没有记录时返回 false
+func (e *ExtractInfo) Layer(path string) (int, bool) {
+	if e == nil {
+		return 0, false
+	}
+	layer, ok := e.Layers[path]
+	return layer, ok
+}
+
+// FileOwner 返回镜像内路径的属主，没有记录时返回 false
+func (e *ExtractInfo) FileOwner(path string) (Owner, bool) {
+	if e == nil {
+		return Owner{}, false
+	}
+	owner, ok := e.Owners[path]
+	return owner, ok
+}
+
+// Xattr 返回镜像内路径的扩展属性，没有记录时返回 false
+func (e *ExtractInfo) Xattr(path, name string) ([]byte, bool) {
+	if e == nil {
+		return nil, false
+	}
+	value, ok := e.Xattrs[path][name]
+	return []byte(value), ok
+}
+
+// FileMode 返回镜像内路径在 tar 条目中的权限位，只记录带特殊位、其他用户可写或带扩展属性的路径，没有记录时返回 false
+func (e *ExtractInfo) FileMode(path string) (os.FileMode, bool) {
+	if e == nil {
+		return 0, false
+	}
+	mode, ok := e.Modes[path]
+	return mode, ok
+}